@@ -0,0 +1,474 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// settings of the whole service. Each value comes from, by increasing priority, its default,
+// the YAML file named by -config or CONFIG_FILE, its environment variable and its flag
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	GitHub    GitHubConfig    `yaml:"github"`
+	GitLab    GitLabConfig    `yaml:"gitlab"`
+	Bitbucket BitbucketConfig `yaml:"bitbucket"`
+	Scanner   ScannerConfig   `yaml:"scanner"`
+	Workers   WorkersConfig   `yaml:"workers"`
+	Timeouts  TimeoutsConfig  `yaml:"timeouts"`
+	Templates TemplatesConfig `yaml:"templates"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Logging   LoggingConfig   `yaml:"logging"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Shutdown  ShutdownConfig  `yaml:"shutdown"`
+	Auth      AuthConfig      `yaml:"auth"`
+}
+
+type ServerConfig struct {
+	ListenAddress string        `yaml:"listen_address"`
+	PublicDir     string        `yaml:"public_dir"`
+	TLS           TLSConfig     `yaml:"tls"`
+	ReadTimeout   time.Duration `yaml:"read_timeout"`
+	WriteTimeout  time.Duration `yaml:"write_timeout"`
+	IdleTimeout   time.Duration `yaml:"idle_timeout"`
+}
+
+// the server listens with TLS when both files are set
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+func (tls TLSConfig) Enabled() bool {
+	return tls.CertFile != "" && tls.KeyFile != ""
+}
+
+type GitHubConfig struct {
+	Token         string                      `yaml:"token"`
+	BaseURL       string                      `yaml:"base_url"`
+	UploadURL     string                      `yaml:"upload_url"`
+	GitHost       string                      `yaml:"git_host"`
+	WebhookSecret string                      `yaml:"webhook_secret"`
+	Hosts         map[string]utils.GitHubHost `yaml:"hosts"`
+	RateLimit     RateLimitConfig             `yaml:"rate_limit"`
+}
+
+// api and git endpoints of the GitHub instance used when a request names no host
+func (github GitHubConfig) DefaultHost() utils.GitHubHost {
+	return utils.GitHubHost{BaseURL: github.BaseURL, UploadURL: github.UploadURL, GitHost: github.GitHost, Token: github.Token}
+}
+
+type RateLimitConfig struct {
+	Mode           string `yaml:"mode"`
+	MaxWaitSeconds int    `yaml:"max_wait_seconds"`
+	JobReserve     int    `yaml:"job_reserve"`
+}
+
+type GitLabConfig struct {
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"`
+}
+
+type BitbucketConfig struct {
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Token    string `yaml:"token"`
+}
+
+type ScannerConfig struct {
+	Binary          string `yaml:"binary"`
+	SecretsFileName string `yaml:"secrets_file_name"`
+	WorkDir         string `yaml:"work_dir"`
+	MinFreeDiskMB   int    `yaml:"min_free_disk_mb"`
+}
+
+type WorkersConfig struct {
+	JobQueueSize          int `yaml:"job_queue_size"`
+	JobWorkers            int `yaml:"job_workers"`
+	OrgRolloutConcurrency int `yaml:"org_rollout_concurrency"`
+}
+
+type TimeoutsConfig struct {
+	Readiness               time.Duration `yaml:"readiness"`
+	ForkPollInterval        time.Duration `yaml:"fork_poll_interval"`
+	ForkPollAttempts        int           `yaml:"fork_poll_attempts"`
+	ForkReadyTimeout        time.Duration `yaml:"fork_ready_timeout"`
+	RetryAttempts           int           `yaml:"retry_attempts"`
+	RetryBaseDelay          time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay           time.Duration `yaml:"retry_max_delay"`
+	IdempotencyRetentionHrs int           `yaml:"idempotency_retention_hours"`
+	CheckpointRetentionHrs  int           `yaml:"checkpoint_retention_hours"`
+	RequestLease            time.Duration `yaml:"request_lease"`
+}
+
+type TemplatesConfig struct {
+	Default utils.NamingTemplate            `yaml:"default"`
+	Orgs    map[string]utils.NamingTemplate `yaml:"orgs"`
+}
+
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
+}
+
+type LoggingConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter"`
+	ServiceName string `yaml:"service_name"`
+}
+
+// Timeout bounds the wait for the running requests and jobs, the jobs that did not finish
+// are saved to PendingJobsFile and resumed on the next start
+type ShutdownConfig struct {
+	Timeout         time.Duration `yaml:"timeout"`
+	PendingJobsFile string        `yaml:"pending_jobs_file"`
+}
+
+// the API requires an API key or an OIDC bearer token, it only serves without either when
+// Disabled is set
+type AuthConfig struct {
+	Disabled bool               `yaml:"disabled"`
+	APIKeys  []utils.APIKey     `yaml:"api_keys"`
+	OIDC     utils.OIDCProvider `yaml:"oidc"`
+}
+
+func Defaults() *Config {
+	return &Config{
+		Server: ServerConfig{
+			ListenAddress: ":3000",
+			PublicDir:     "./public",
+			ReadTimeout:   30 * time.Second,
+			IdleTimeout:   2 * time.Minute,
+		},
+		GitHub: GitHubConfig{
+			GitHost: "github.com",
+			Hosts:   map[string]utils.GitHubHost{},
+			RateLimit: RateLimitConfig{
+				Mode:           utils.RateLimitWait,
+				MaxWaitSeconds: 60,
+				JobReserve:     100,
+			},
+		},
+		GitLab: GitLabConfig{URL: "https://gitlab.com"},
+		Scanner: ScannerConfig{
+			Binary:          "detect-secrets",
+			SecretsFileName: ".secrets.baseline",
+			WorkDir:         os.TempDir(),
+			MinFreeDiskMB:   1024,
+		},
+		Workers: WorkersConfig{
+			JobQueueSize:          100,
+			JobWorkers:            2,
+			OrgRolloutConcurrency: 4,
+		},
+		Timeouts: TimeoutsConfig{
+			Readiness:               5 * time.Second,
+			ForkPollInterval:        2 * time.Second,
+			ForkPollAttempts:        30,
+			ForkReadyTimeout:        2 * time.Minute,
+			RetryAttempts:           3,
+			RetryBaseDelay:          500 * time.Millisecond,
+			RetryMaxDelay:           10 * time.Second,
+			IdempotencyRetentionHrs: 24,
+			CheckpointRetentionHrs:  24,
+			RequestLease:            30 * time.Minute,
+		},
+		Templates: TemplatesConfig{
+			Default: utils.NamingTemplate{
+				Branch: "secret_scanner_api/{{.Repo}}/{{.Action}}/{{.RequestID}}/secrets_baseline_file",
+				Commit: "chore: {{.Action}} secret baseline file",
+			},
+			Orgs: map[string]utils.NamingTemplate{},
+		},
+		Database: DatabaseConfig{Driver: "sqlite3", DSN: "secret-scanner.db"},
+		Logging:  LoggingConfig{Level: "info", Format: utils.LogFormatJSON},
+		Tracing:  TracingConfig{Exporter: utils.TracingExporterNone, ServiceName: "secret-scanner"},
+		Shutdown: ShutdownConfig{Timeout: 30 * time.Second, PendingJobsFile: "pending-jobs.json"},
+		Auth: AuthConfig{
+			OIDC: utils.OIDCProvider{UsernameClaim: "email", GroupsClaim: "groups", Groups: map[string]utils.RoleGrant{}},
+		},
+	}
+}
+
+// a setting that can be overridden by an environment variable and, when flag is set, by a
+// command line flag
+type binding struct {
+	env   string
+	flag  string
+	usage string
+	value interface{}
+}
+
+func (config *Config) bindings() []binding {
+	return []binding{
+		{"LISTEN_ADDRESS", "listen", "address the API listens on", &config.Server.ListenAddress},
+		{"PUBLIC_DIR", "public-dir", "directory of the static files", &config.Server.PublicDir},
+		{"TLS_CERT_FILE", "tls-cert", "certificate of the TLS listener", &config.Server.TLS.CertFile},
+		{"TLS_KEY_FILE", "tls-key", "private key of the TLS listener", &config.Server.TLS.KeyFile},
+		{"SERVER_READ_TIMEOUT", "read-timeout", "time allowed to read a request", &config.Server.ReadTimeout},
+		{"SERVER_WRITE_TIMEOUT", "write-timeout", "time allowed to write a response, 0 for none", &config.Server.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", "idle-timeout", "time keep-alive connections are kept idle", &config.Server.IdleTimeout},
+		{"GITHUB_TOKEN", "", "", &config.GitHub.Token},
+		{"GITHUB_BASE_URL", "github-base-url", "API url of GitHub Enterprise Server", &config.GitHub.BaseURL},
+		{"GITHUB_UPLOAD_URL", "github-upload-url", "upload url of GitHub Enterprise Server", &config.GitHub.UploadURL},
+		{"GITHUB_GIT_HOST", "github-git-host", "git host of the default GitHub instance", &config.GitHub.GitHost},
+		{"GITHUB_WEBHOOK_SECRET", "", "", &config.GitHub.WebhookSecret},
+		{"GITHUB_HOSTS", "", "", &config.GitHub.Hosts},
+		{"GITHUB_RATE_LIMIT_MODE", "github-rate-limit-mode", "wait or fail when the GitHub quota runs out", &config.GitHub.RateLimit.Mode},
+		{"GITHUB_RATE_LIMIT_MAX_WAIT_SECONDS", "github-rate-limit-max-wait", "longest wait for the GitHub quota in seconds", &config.GitHub.RateLimit.MaxWaitSeconds},
+		{"GITHUB_RATE_LIMIT_JOB_RESERVE", "github-rate-limit-job-reserve", "GitHub calls kept for the API requests", &config.GitHub.RateLimit.JobReserve},
+		{"GITLAB_URL", "gitlab-url", "url of the GitLab instance", &config.GitLab.URL},
+		{"GITLAB_TOKEN", "", "", &config.GitLab.Token},
+		{"BITBUCKET_URL", "bitbucket-url", "url of the Bitbucket Server instance", &config.Bitbucket.URL},
+		{"BITBUCKET_USERNAME", "bitbucket-username", "user of the Bitbucket token", &config.Bitbucket.Username},
+		{"BITBUCKET_TOKEN", "", "", &config.Bitbucket.Token},
+		{"DETECT_SECRETS_BINARY", "detect-secrets", "path of the detect-secrets binary", &config.Scanner.Binary},
+		{"SECRETS_FILE_NAME", "secrets-file", "name of the baseline file in the repos", &config.Scanner.SecretsFileName},
+		{"WORK_DIR", "work-dir", "directory the repos are cloned into", &config.Scanner.WorkDir},
+		{"MIN_FREE_DISK_MB", "min-free-disk", "free MB of the work directory below which the service is not ready", &config.Scanner.MinFreeDiskMB},
+		{"JOB_QUEUE_SIZE", "job-queue-size", "background jobs waiting at most", &config.Workers.JobQueueSize},
+		{"JOB_WORKERS", "job-workers", "background jobs run at a time", &config.Workers.JobWorkers},
+		{"ORG_ROLLOUT_CONCURRENCY", "org-rollout-concurrency", "repos of an org rollout processed at a time", &config.Workers.OrgRolloutConcurrency},
+		{"READINESS_TIMEOUT", "readiness-timeout", "time allowed to the readiness checks", &config.Timeouts.Readiness},
+		{"FORK_POLL_INTERVAL", "fork-poll-interval", "wait between checks of a GitLab or Bitbucket fork", &config.Timeouts.ForkPollInterval},
+		{"FORK_POLL_ATTEMPTS", "fork-poll-attempts", "checks of a GitLab or Bitbucket fork before giving up", &config.Timeouts.ForkPollAttempts},
+		{"FORK_READY_TIMEOUT", "fork-ready-timeout", "longest wait for a GitHub fork to answer", &config.Timeouts.ForkReadyTimeout},
+		{"RETRY_ATTEMPTS", "retry-attempts", "attempts of the fork, push and pull request calls", &config.Timeouts.RetryAttempts},
+		{"RETRY_BASE_DELAY", "retry-base-delay", "first backoff between attempts", &config.Timeouts.RetryBaseDelay},
+		{"RETRY_MAX_DELAY", "retry-max-delay", "longest backoff between attempts", &config.Timeouts.RetryMaxDelay},
+		{"IDEMPOTENCY_RETENTION_HOURS", "idempotency-retention", "hours the idempotency keys are kept", &config.Timeouts.IdempotencyRetentionHrs},
+		{"CHECKPOINT_RETENTION_HOURS", "checkpoint-retention", "hours the checkpoint of a failed create or update is kept", &config.Timeouts.CheckpointRetentionHrs},
+		{"REQUEST_LEASE", "request-lease", "time a running create or update holds its checkpoint and idempotency key before a retry may take them over", &config.Timeouts.RequestLease},
+		{"BRANCH_NAME_TEMPLATE", "branch-template", "template of the baseline branch names", &config.Templates.Default.Branch},
+		{"COMMIT_MESSAGE_TEMPLATE", "commit-template", "template of the baseline commit messages", &config.Templates.Default.Commit},
+		{"ORG_NAMING_TEMPLATES", "", "", &config.Templates.Orgs},
+		{"DATABASE_DRIVER", "database-driver", "sqlite3 or postgres", &config.Database.Driver},
+		{"DATABASE_DSN", "database-dsn", "data source of the history database", &config.Database.DSN},
+		{"LOG_LEVEL", "log-level", "trace, debug, info, warn or error", &config.Logging.Level},
+		{"LOG_FORMAT", "log-format", "json or console", &config.Logging.Format},
+		{"OTEL_TRACES_EXPORTER", "traces-exporter", "none, stdout or otlp", &config.Tracing.Exporter},
+		{"OTEL_SERVICE_NAME", "service-name", "service name of the spans", &config.Tracing.ServiceName},
+		{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "wait for the running requests and jobs at shutdown", &config.Shutdown.Timeout},
+		{"PENDING_JOBS_FILE", "pending-jobs-file", "file the unfinished jobs are saved to at shutdown", &config.Shutdown.PendingJobsFile},
+		{"AUTH_DISABLED", "auth-disabled", "serve without authentication when no API key nor issuer is set", &config.Auth.Disabled},
+		{"API_KEYS", "", "", &config.Auth.APIKeys},
+		{"OIDC_ISSUER", "oidc-issuer", "issuer of the accepted bearer tokens", &config.Auth.OIDC.Issuer},
+		{"OIDC_AUDIENCE", "oidc-audience", "audience the bearer tokens must be issued for", &config.Auth.OIDC.Audience},
+		{"OIDC_USERNAME_CLAIM", "oidc-username-claim", "claim naming the caller", &config.Auth.OIDC.UsernameClaim},
+		{"OIDC_GROUPS_CLAIM", "oidc-groups-claim", "claim listing the groups of the caller", &config.Auth.OIDC.GroupsClaim},
+		{"OIDC_GROUPS", "", "", &config.Auth.OIDC.Groups},
+	}
+}
+
+// sets the value binding points to from its text form, maps and lists are read as json
+func (b binding) set(raw string) error {
+	var err error
+	switch value := b.value.(type) {
+	case *string:
+		*value = raw
+	case *int:
+		*value, err = strconv.Atoi(raw)
+	case *time.Duration:
+		*value, err = time.ParseDuration(raw)
+	default:
+		err = json.Unmarshal([]byte(raw), value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", b.env, err)
+	}
+	return nil
+}
+
+// reads the configuration from the defaults, the YAML file, the environment and args, the
+// command line flags without the program name
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("secret-scanner", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file")
+	config := Defaults()
+	bindings := config.bindings()
+	flagValues := map[string]*string{}
+	for _, b := range bindings {
+		if b.flag != "" {
+			flagValues[b.flag] = flags.String(b.flag, "", fmt.Sprintf("%s (%s)", b.usage, b.env))
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		content, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(content, config); err != nil {
+			return nil, fmt.Errorf("%s: %v", *configFile, err)
+		}
+	}
+	for _, b := range bindings {
+		if raw, ok := os.LookupEnv(b.env); ok && raw != "" {
+			if err := b.set(raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var err error
+	flags.Visit(func(set *flag.Flag) {
+		for _, b := range bindings {
+			if err == nil && b.flag == set.Name {
+				err = b.set(*flagValues[b.flag])
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	config.fillHostDefaults()
+	return config, config.Validate()
+}
+
+// git host and token of the extra GitHub hosts default to their key and the main token
+func (config *Config) fillHostDefaults() {
+	for name, host := range config.GitHub.Hosts {
+		if host.GitHost == "" {
+			host.GitHost = name
+		}
+		if host.Token == "" {
+			host.Token = config.GitHub.Token
+		}
+		if host.UploadURL == "" {
+			host.UploadURL = host.BaseURL
+		}
+		config.GitHub.Hosts[name] = host
+	}
+}
+
+// lists every invalid setting in a single error
+func (config *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	_, _, err := net.SplitHostPort(config.Server.ListenAddress)
+	check(err == nil, "server.listen_address %q is not host:port", config.Server.ListenAddress)
+	tls := config.Server.TLS
+	check((tls.CertFile == "") == (tls.KeyFile == ""), "server.tls needs both cert_file and key_file")
+	for _, file := range []string{tls.CertFile, tls.KeyFile} {
+		if file != "" {
+			_, err := os.Stat(file)
+			check(err == nil, "server.tls file %s cannot be read", file)
+		}
+	}
+	check(config.Server.ReadTimeout >= 0 && config.Server.WriteTimeout >= 0 && config.Server.IdleTimeout >= 0, "server timeouts cannot be negative")
+
+	mode := config.GitHub.RateLimit.Mode
+	check(mode == utils.RateLimitWait || mode == utils.RateLimitFailFast, "github.rate_limit.mode must be %s or %s", utils.RateLimitWait, utils.RateLimitFailFast)
+	check(config.GitHub.RateLimit.MaxWaitSeconds >= 0, "github.rate_limit.max_wait_seconds cannot be negative")
+	check(config.GitHub.RateLimit.JobReserve >= 0, "github.rate_limit.job_reserve cannot be negative")
+	check(config.GitHub.GitHost != "", "github.git_host is required")
+	for name, host := range config.GitHub.Hosts {
+		check(host.BaseURL != "", "github.hosts.%s.base_url is required", name)
+	}
+
+	check(config.Scanner.Binary != "", "scanner.binary is required")
+	name := config.Scanner.SecretsFileName
+	check(name != "" && !strings.ContainsAny(name, `/\`), "scanner.secrets_file_name %q must be a file name", name)
+	info, err := os.Stat(config.Scanner.WorkDir)
+	check(err == nil && info.IsDir(), "scanner.work_dir %s is not a directory", config.Scanner.WorkDir)
+	check(config.Scanner.MinFreeDiskMB >= 0, "scanner.min_free_disk_mb cannot be negative")
+
+	check(config.Workers.JobQueueSize > 0, "workers.job_queue_size must be positive")
+	check(config.Workers.JobWorkers > 0, "workers.job_workers must be positive")
+	check(config.Workers.OrgRolloutConcurrency > 0, "workers.org_rollout_concurrency must be positive")
+
+	timeouts := config.Timeouts
+	check(timeouts.Readiness > 0, "timeouts.readiness must be positive")
+	check(timeouts.ForkPollInterval > 0 && timeouts.ForkPollAttempts > 0, "timeouts.fork_poll_interval and fork_poll_attempts must be positive")
+	check(timeouts.ForkReadyTimeout > 0, "timeouts.fork_ready_timeout must be positive")
+	check(timeouts.RetryAttempts > 0, "timeouts.retry_attempts must be positive")
+	check(timeouts.RetryBaseDelay > 0 && timeouts.RetryMaxDelay >= timeouts.RetryBaseDelay, "timeouts.retry_max_delay must be at least retry_base_delay")
+	check(timeouts.IdempotencyRetentionHrs > 0, "timeouts.idempotency_retention_hours must be positive")
+	check(timeouts.CheckpointRetentionHrs > 0, "timeouts.checkpoint_retention_hours must be positive")
+	check(timeouts.RequestLease > 0, "timeouts.request_lease must be positive")
+
+	check(config.Templates.Default.Branch != "" && config.Templates.Default.Commit != "", "templates.default needs branch and commit")
+	templates := map[string]utils.NamingTemplate{"default": config.Templates.Default}
+	for org, naming := range config.Templates.Orgs {
+		templates[org] = naming
+	}
+	for org, naming := range templates {
+		for kind, source := range map[string]string{"branch": naming.Branch, "commit": naming.Commit} {
+			if source != "" {
+				check(validTemplate(source), "templates %s %s %q is not a valid template", org, kind, source)
+			}
+		}
+	}
+
+	check(config.Database.Driver == "sqlite3" || config.Database.Driver == "postgres", "database.driver must be sqlite3 or postgres")
+	_, err = zerolog.ParseLevel(strings.ToLower(config.Logging.Level))
+	check(err == nil, "logging.level %q is unknown", config.Logging.Level)
+	format := config.Logging.Format
+	check(format == utils.LogFormatJSON || format == utils.LogFormatConsole, "logging.format must be %s or %s", utils.LogFormatJSON, utils.LogFormatConsole)
+	switch config.Tracing.Exporter {
+	case utils.TracingExporterNone, utils.TracingExporterStdout, utils.TracingExporterOTLP:
+	default:
+		check(false, "tracing.exporter must be %s, %s or %s", utils.TracingExporterNone, utils.TracingExporterStdout, utils.TracingExporterOTLP)
+	}
+
+	check(config.Shutdown.Timeout > 0, "shutdown.timeout must be positive")
+	check(config.Shutdown.PendingJobsFile != "", "shutdown.pending_jobs_file is required")
+
+	roles := strings.Join([]string{utils.RoleViewer, utils.RoleAuditor, utils.RoleAdmin}, ", ")
+	names := map[string]bool{}
+	for i, key := range config.Auth.APIKeys {
+		check(key.Name != "" && !names[key.Name], "auth.api_keys[%d] needs a unique name", i)
+		names[key.Name] = true
+		check(len(key.Key) >= 16, "auth.api_keys %s key must have at least 16 characters", key.Name)
+		check(utils.ValidRole(key.Role), "auth.api_keys %s role must be one of %s", key.Name, roles)
+		check(len(key.Orgs) > 0, "auth.api_keys %s needs orgs, %q allows every org", key.Name, utils.AllOrgs)
+	}
+	oidc := config.Auth.OIDC
+	configured := len(config.Auth.APIKeys) > 0 || oidc.Issuer != ""
+	check(configured || config.Auth.Disabled, "auth needs api_keys or an oidc issuer, set auth.disabled to serve without authentication")
+	check(!configured || !config.Auth.Disabled, "auth.disabled cannot be set with api_keys or an oidc issuer")
+	if oidc.Issuer != "" {
+		check(strings.HasPrefix(oidc.Issuer, "https://"), "auth.oidc.issuer must be an https url")
+		check(oidc.Audience != "", "auth.oidc.audience is required with an issuer")
+		check(oidc.UsernameClaim != "" && oidc.GroupsClaim != "", "auth.oidc needs username_claim and groups_claim")
+		check(len(oidc.Groups) > 0, "auth.oidc.groups must grant a role to at least one group")
+	}
+	for group, grant := range oidc.Groups {
+		check(utils.ValidRole(grant.Role), "auth.oidc.groups %s role must be one of %s", group, roles)
+		check(len(grant.Orgs) > 0, "auth.oidc.groups %s needs orgs, %q allows every org", group, utils.AllOrgs)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validTemplate(source string) bool {
+	tmpl, err := template.New("naming").Option("missingkey=error").Parse(source)
+	if err != nil {
+		return false
+	}
+	params := struct{ Owner, Repo, Action, RequestID string }{"owner", "repo", "create", "00000000"}
+	return tmpl.Execute(ioutil.Discard, params) == nil
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"github.com/eliezer-borde-globant/EBGoProject/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+var _ = Describe("Config", func() {
+	var file string
+
+	writeConfig := func(content string) {
+		out, err := ioutil.TempFile("", "config-*.yaml")
+		Expect(err).To(BeNil())
+		_, err = out.WriteString(content)
+		Expect(err).To(BeNil())
+		Expect(out.Close()).To(Succeed())
+		file = out.Name()
+	}
+
+	BeforeEach(func() {
+		os.Setenv("AUTH_DISABLED", "true")
+	})
+
+	AfterEach(func() {
+		if file != "" {
+			os.Remove(file)
+			file = ""
+		}
+		for _, env := range []string{"JOB_WORKERS", "GITHUB_HOSTS", "RETRY_BASE_DELAY", "CONFIG_FILE", "API_KEYS", "OIDC_ISSUER", "AUTH_DISABLED"} {
+			os.Unsetenv(env)
+		}
+	})
+
+	It("loads valid defaults", func() {
+		config, err := Load(nil)
+		Expect(err).To(BeNil())
+		Expect(config.Server.ListenAddress).To(Equal(":3000"))
+		Expect(config.Scanner.SecretsFileName).To(Equal(".secrets.baseline"))
+		Expect(config.Server.TLS.Enabled()).To(BeFalse())
+	})
+
+	It("lets the environment override the file and the flags override both", func() {
+		writeConfig(`
+server:
+  listen_address: ":8080"
+workers:
+  job_workers: 3
+  job_queue_size: 10
+timeouts:
+  retry_base_delay: 2s
+  retry_max_delay: 20s
+templates:
+  orgs:
+    acme:
+      branch: "secrets/{{.Repo}}/{{.RequestID}}"
+`)
+		os.Setenv("JOB_WORKERS", "5")
+		os.Setenv("RETRY_BASE_DELAY", "1s")
+		config, err := Load([]string{"-config", file, "-job-workers", "7", "-log-format", "console"})
+		Expect(err).To(BeNil())
+		Expect(config.Server.ListenAddress).To(Equal(":8080"))
+		Expect(config.Workers.JobQueueSize).To(Equal(10))
+		Expect(config.Workers.JobWorkers).To(Equal(7))
+		Expect(config.Timeouts.RetryBaseDelay).To(Equal(time.Second))
+		Expect(config.Timeouts.RetryMaxDelay).To(Equal(20 * time.Second))
+		Expect(config.Logging.Format).To(Equal(utils.LogFormatConsole))
+		Expect(config.Templates.Orgs["acme"].Branch).To(Equal("secrets/{{.Repo}}/{{.RequestID}}"))
+	})
+
+	It("reads the extra GitHub hosts as json and fills their defaults", func() {
+		os.Setenv("GITHUB_HOSTS", `{"ghe.acme.com": {"base_url": "https://ghe.acme.com/api/v3/"}}`)
+		config, err := Load([]string{"-config", "", "-listen", "127.0.0.1:9000"})
+		Expect(err).To(BeNil())
+		Expect(config.GitHub.Hosts["ghe.acme.com"].GitHost).To(Equal("ghe.acme.com"))
+		Expect(config.GitHub.Hosts["ghe.acme.com"].UploadURL).To(Equal("https://ghe.acme.com/api/v3/"))
+	})
+
+	It("returns a new default configuration every time", func() {
+		first := Defaults()
+		first.GitHub.Hosts["ghe.acme.com"] = utils.GitHubHost{GitHost: "ghe.acme.com"}
+		first.GitHub.Token = "token"
+		Expect(Defaults().GitHub.Hosts).To(BeEmpty())
+		Expect(first.GitHub.DefaultHost()).To(Equal(utils.GitHubHost{GitHost: "github.com", Token: "token"}))
+	})
+
+	It("reads the API keys as json and checks the auth settings", func() {
+		os.Unsetenv("AUTH_DISABLED")
+		os.Setenv("API_KEYS", `[{"name": "ci", "key": "0123456789abcdef", "role": "admin", "orgs": ["*"]}]`)
+		config, err := Load([]string{"-config", ""})
+		Expect(err).To(BeNil())
+		Expect(config.Auth.APIKeys[0].Name).To(Equal("ci"))
+		Expect(config.Auth.APIKeys[0].Role).To(Equal("admin"))
+
+		os.Setenv("API_KEYS", `[{"name": "ci", "key": "short", "role": "owner", "orgs": []}]`)
+		os.Setenv("OIDC_ISSUER", "http://issuer.example")
+		_, err = Load([]string{"-config", ""})
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("auth.api_keys ci key"))
+		Expect(err.Error()).To(ContainSubstring("auth.api_keys ci role"))
+		Expect(err.Error()).To(ContainSubstring("auth.api_keys ci needs orgs"))
+		Expect(err.Error()).To(ContainSubstring("auth.oidc.issuer"))
+		Expect(err.Error()).To(ContainSubstring("auth.oidc.audience"))
+	})
+
+	It("refuses to serve without authentication unless it is disabled", func() {
+		os.Unsetenv("AUTH_DISABLED")
+		_, err := Load(nil)
+		Expect(err).To(MatchError(ContainSubstring("set auth.disabled")))
+
+		os.Setenv("API_KEYS", `[{"name": "ci", "key": "0123456789abcdef", "role": "admin", "orgs": ["*"]}]`)
+		config, err := Load(nil)
+		Expect(err).To(BeNil())
+		Expect(config.Auth.Disabled).To(BeFalse())
+
+		os.Setenv("AUTH_DISABLED", "true")
+		_, err = Load(nil)
+		Expect(err).To(MatchError(ContainSubstring("auth.disabled cannot be set")))
+	})
+
+	It("rejects unknown keys in the file", func() {
+		writeConfig("server:\n  listen: \":8080\"\n")
+		_, err := Load([]string{"-config", file})
+		Expect(err).To(MatchError(ContainSubstring("listen")))
+	})
+
+	It("lists every invalid setting", func() {
+		writeConfig(`
+server:
+  listen_address: "3000"
+  tls:
+    cert_file: server.crt
+workers:
+  job_workers: 0
+templates:
+  default:
+    branch: "{{.Missing}}"
+    commit: "chore: add"
+logging:
+  level: loud
+`)
+		_, err := Load([]string{"-config", file})
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("server.listen_address"))
+		Expect(err.Error()).To(ContainSubstring("server.tls needs both"))
+		Expect(err.Error()).To(ContainSubstring("workers.job_workers"))
+		Expect(err.Error()).To(ContainSubstring("templates default branch"))
+		Expect(err.Error()).To(ContainSubstring("logging.level"))
+	})
+
+	It("rejects malformed environment values", func() {
+		os.Setenv("JOB_WORKERS", "many")
+		_, err := Load(nil)
+		Expect(err).To(MatchError(ContainSubstring("JOB_WORKERS")))
+	})
+
+	It("accepts the example file", func() {
+		_, err := Load([]string{"-config", "../config.example.yaml"})
+		Expect(err).To(BeNil())
+	})
+})
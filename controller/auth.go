@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// authenticates the request with its X-API-Key header or its OIDC bearer token and lets it
+// through when the identity has role. Every request goes through while no API key nor issuer
+// is configured
+func RequireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !Auth.Enabled() {
+			return c.Next()
+		}
+		ctx := requestContext(c)
+		identity, err := Auth.Authenticate(c.Get(apiKeyHeader), bearerToken(c.Get(fiber.HeaderAuthorization)))
+		if err != nil {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			statusCode, msg := failure(ctx, "", ErrCodeUnauthorized, "Authentication required", err)
+			return send(c, statusCode, msg)
+		}
+		ctx = WithLogFields(WithIdentity(ctx, identity), "user", identity.Name, "role", identity.Role)
+		c.Locals(requestContextKey, ctx)
+		if !identity.HasRole(role) {
+			statusCode, msg := failure(ctx, "", ErrCodeForbidden, fmt.Sprintf("The %s role is required", role), nil)
+			return send(c, statusCode, msg)
+		}
+		return c.Next()
+	}
+}
+
+func bearerToken(authorization string) string {
+	const prefix = "bearer "
+	if len(authorization) > len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return strings.TrimSpace(authorization[len(prefix):])
+	}
+	return ""
+}
+
+// forbids org to the identities whose allow-list does not have it, requests without an
+// identity come from background jobs or run with authentication off
+func authorizeOrg(ctx context.Context, org string) error {
+	identity, ok := IdentityFrom(ctx)
+	if !ok || identity.AllowsOrg(org) {
+		return nil
+	}
+	return NewServiceError(ErrCodeForbidden, fmt.Sprintf("%s is not allowed to access %s", identity.Name, org), nil)
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	"github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/go-git/go-git/v5"
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+)
+
+var _ = Describe("Auth", func() {
+	var previousAuth = services.Auth
+	var app *fiber.App
+
+	get := func(path string, apiKey string) int {
+		request := httptest.NewRequest("GET", path, nil)
+		if apiKey != "" {
+			request.Header.Set(apiKeyHeader, apiKey)
+		}
+		response, err := app.Test(request)
+		Expect(err).To(BeNil())
+		return response.StatusCode
+	}
+
+	BeforeEach(func() {
+		previousAuth = services.Auth
+		services.Auth = services.NewAuthenticator([]utils.APIKey{
+			{Name: "reader", Key: "reader-key-0123456789", RoleGrant: utils.RoleGrant{Role: utils.RoleViewer, Orgs: []string{"acme"}}},
+			{Name: "ops", Key: "ops-key-0123456789", RoleGrant: utils.RoleGrant{Role: utils.RoleAdmin, Orgs: []string{utils.AllOrgs}}},
+		}, utils.OIDCProvider{})
+		app = fiber.New()
+		app.Use(RequestIDMiddleware)
+		app.Get("/read", RequireRole(utils.RoleViewer), func(c *fiber.Ctx) error {
+			identity, _ := services.IdentityFrom(requestContext(c))
+			return c.SendString(identity.Name)
+		})
+		app.Get("/admin", RequireRole(utils.RoleAdmin), func(c *fiber.Ctx) error { return c.SendString("ok") })
+	})
+
+	AfterEach(func() {
+		services.Auth = previousAuth
+	})
+
+	It("should require credentials once an API key is configured", func() {
+		Expect(get("/read", "")).To(Equal(401))
+		Expect(get("/read", "wrong")).To(Equal(401))
+		Expect(get("/read", "reader-key-0123456789")).To(Equal(200))
+	})
+
+	It("should enforce the role of each route", func() {
+		Expect(get("/admin", "reader-key-0123456789")).To(Equal(403))
+		Expect(get("/admin", "ops-key-0123456789")).To(Equal(200))
+		Expect(get("/read", "ops-key-0123456789")).To(Equal(200))
+	})
+
+	It("should let every request through while authentication is off", func() {
+		services.Auth = services.NewAuthenticator(nil, utils.OIDCProvider{})
+		Expect(get("/admin", "")).To(Equal(200))
+	})
+
+	It("should reject the orgs missing from the allow-list before checking the repo access", func() {
+		checked := false
+		services.GitServiceObject = gitServiceMock{
+			CheckUserAccessRepoHandler: func(string, string) error {
+				checked = true
+				return nil
+			},
+			CloneRepoHandler: func(string, string) (*git.Repository, string, error) {
+				Fail("a forbidden org must not be cloned")
+				return nil, "", nil
+			},
+		}
+		identity := services.Identity{Name: "reader", Role: utils.RoleAdmin, Orgs: []string{"acme"}}
+		context := contextMock{
+			LocalValues: map[string]interface{}{requestContextKey: services.WithIdentity(requestContext(contextMock{}), identity)},
+			BodyParserCreateHandler: func(data *createParams) error {
+				data.Owner, data.Repo, data.Content = "globex", "repo", "{}"
+				return nil
+			},
+		}
+		statusCode, msg := ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(403))
+		Expect(msg).To(ContainSubstring("reader is not allowed to access globex"))
+		Expect(checked).To(BeFalse())
+
+		history, err := services.History.RepoHistory("globex", "repo")
+		Expect(err).To(BeNil())
+		Expect(history.Requests).To(HaveLen(1))
+		Expect(history.Requests[0].RequestedBy).To(Equal("reader"))
+	})
+})
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"strconv"
+	"strings"
+)
+
+const (
+	secretsCommandPrefix = "/secrets"
+	secretsCommandUsage  = "usage: `/secrets false-positive path/to/file:line [justification]` or `/secrets verify <hash> [justification]`"
+)
+
+// an audit requested from a pull request comment, false-positive targets the secret on
+// Filename:Line and verify every occurrence of Hash
+type secretsCommand struct {
+	Action        string
+	Filename      string
+	Line          int
+	Hash          string
+	Justification string
+}
+
+// parses the /secrets lines of a comment, the other lines are ignored. The words after the
+// target are the justification of the decision, auditFromComment fills it in when missing
+func parseSecretsCommands(body string) ([]secretsCommand, error) {
+	var commands []secretsCommand
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != secretsCommandPrefix {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%q is missing the command or its target", strings.TrimSpace(line))
+		}
+		justification := strings.Join(fields[3:], " ")
+		switch fields[1] {
+		case "false-positive":
+			separator := strings.LastIndex(fields[2], ":")
+			if separator <= 0 {
+				return nil, fmt.Errorf("%s is not a path/to/file:line location", fields[2])
+			}
+			number, err := strconv.Atoi(fields[2][separator+1:])
+			if err != nil || number <= 0 {
+				return nil, fmt.Errorf("%s is not a path/to/file:line location", fields[2])
+			}
+			commands = append(commands, secretsCommand{Action: fields[1], Filename: fields[2][:separator], Line: number, Justification: justification})
+		case "verify":
+			commands = append(commands, secretsCommand{Action: fields[1], Hash: fields[2], Justification: justification})
+		default:
+			return nil, fmt.Errorf("unknown command %s %s", secretsCommandPrefix, fields[1])
+		}
+	}
+	return commands, nil
+}
+
+func issueCommentJob(ctx context.Context, body []byte, host string) (*Job, int, string) {
+	event := new(issueCommentEventParams)
+	if err := json.Unmarshal(body, event); err != nil {
+		statusCode, msg := failure(ctx, "", ErrCodeInvalidInput, "Error in data, please review input data", err)
+		return nil, statusCode, msg
+	}
+	if event.Action != "created" || event.Issue.PullRequest == nil {
+		return nil, 202, "Comment is not a new pull request comment, ignored"
+	}
+	owner := event.Repository.Owner.Login
+	repo := event.Repository.Name
+	number := event.Issue.Number
+	user := event.Comment.User.Login
+	commands, err := parseSecretsCommands(event.Comment.Body)
+	if err != nil {
+		// the commenter only reads the pull request, the error goes there with the usage
+		Logger(ctx).Info().Msgf("Secrets command of %s on #%d rejected: %v", user, number, err)
+		job := newJob(jobKindAuditComment, repoJobParams{Host: host, Owner: owner, Repo: repo, Number: number, User: user, Error: err.Error()})
+		job.Name = fmt.Sprintf("reply to the secrets command of %s on %s/%s#%d", user, owner, repo, number)
+		job.Host = host
+		return &job, 202, "Secrets command rejected, usage replied"
+	}
+	if len(commands) == 0 {
+		return nil, 202, "Comment has no secrets commands, ignored"
+	}
+	job := newJob(jobKindAuditComment, repoJobParams{Host: host, Owner: owner, Repo: repo, Number: number, User: user, Commands: commands})
+	job.Name = fmt.Sprintf("audit secrets of %s/%s requested by %s on #%d", owner, repo, user, number)
+	job.Host = host
+	return &job, 202, "Secrets audit enqueued"
+}
+
+// matches the commands against the baseline of the pull request head and builds the changes for EditSecretFile
+func auditChanges(baseline []byte, commands []secretsCommand) (SecretUpdateMap, error) {
+	results, err := BaselineSecrets(baseline)
+	if err != nil {
+		return nil, err
+	}
+	changes := SecretUpdateMap{}
+	for _, command := range commands {
+		found := false
+		for filename, secrets := range results {
+			for _, secret := range secrets {
+				matches := command.Action == "verify" && fmt.Sprintf("%v", secret["hashed_secret"]) == command.Hash
+				if command.Action == "false-positive" {
+					line, _ := secret["line_number"].(float64)
+					matches = filename == command.Filename && int(line) == command.Line
+				}
+				if !matches {
+					continue
+				}
+				found = true
+				changes[filename] = append(changes[filename], map[string]interface{}{
+					"hashed_secret": secret["hashed_secret"],
+					"line_number":   secret["line_number"],
+					"is_secret":     command.Action == "verify",
+					"justification": command.Justification,
+				})
+			}
+		}
+		if !found {
+			if command.Action == "verify" {
+				return nil, fmt.Errorf("no secret with hash %s in %s", command.Hash, Config.Scanner.SecretsFileName)
+			}
+			return nil, fmt.Errorf("no secret on %s:%d in %s", command.Filename, command.Line, Config.Scanner.SecretsFileName)
+		}
+	}
+	return changes, nil
+}
+
+// answers a comment whose commands could not be parsed with the error and the usage
+func replyCommandError(ctx context.Context, host string, owner string, repo string, number int, user string, message string) error {
+	gitService, err := GitHubHostService(ctx, host)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("@%s the %s command was not understood: %s\n\n%s", user, secretsCommandPrefix, message, secretsCommandUsage)
+	return gitService.CreateIssueComment(owner, repo, number, body)
+}
+
+// applies the commands to the baseline PR they were commented on through the update flow,
+// which checks that the commenter can push to the repo, and replies with the outcome
+func auditFromComment(ctx context.Context, host string, owner string, repo string, number int, user string, commands []secretsCommand) error {
+	// the webhook signature vouches for the commenter, the access check of the update flow
+	// requires their write permission
+	ctx = WithIdentity(ctx, Identity{Name: user, Method: AuthMethodGitHub, Role: RoleAuditor, Orgs: []string{owner}})
+	gitService, err := GitHubHostService(ctx, host)
+	if err != nil {
+		return err
+	}
+	reply := func(err error) error {
+		body := fmt.Sprintf("@%s the %s changes were sent.", user, Config.Scanner.SecretsFileName)
+		if err != nil {
+			body = fmt.Sprintf("@%s the %s changes were not applied: %v", user, Config.Scanner.SecretsFileName, err)
+		}
+		if commentErr := gitService.CreateIssueComment(owner, repo, number, body); commentErr != nil {
+			return commentErr
+		}
+		return err
+	}
+
+	pull, found, err := gitService.OpenBaselinePullRequest(owner, repo)
+	if err != nil {
+		return reply(err)
+	}
+	if !found || pull.Number != number {
+		return reply(fmt.Errorf("#%d is not the open pull request of %s", number, Config.Scanner.SecretsFileName))
+	}
+	baseline, err := gitService.FileContent(owner, repo, Config.Scanner.SecretsFileName, fmt.Sprintf("refs/pull/%d/head", number))
+	if err != nil {
+		return reply(err)
+	}
+	if baseline == nil {
+		return reply(fmt.Errorf("#%d has no %s", number, Config.Scanner.SecretsFileName))
+	}
+	for i := range commands {
+		if commands[i].Justification == "" {
+			commands[i].Justification = fmt.Sprintf("%s by @%s on #%d", commands[i].Action, user, number)
+		}
+	}
+	changes, err := auditChanges(baseline, commands)
+	if err != nil {
+		return reply(err)
+	}
+	description := fmt.Sprintf("Updated %s file, %s audited the secrets from a comment on #%d.", Config.Scanner.SecretsFileName, user, number)
+	data := &updateParams{Repo: repo, Owner: owner, Changes: changes, Provider: GitHubProvider, Host: host, AuditInPR: true, RequestedBy: user}
+	reusePullRequest(ctx, pipelineKey("Update", data.RequestedBy, data), pull)
+	status, msg := updateBaseline(ctx, data, description)
+	if status >= 300 {
+		return reply(failureError(msg))
+	}
+	return reply(nil)
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/go-git/go-git/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+const secretsComment = `{"action": "created", "issue": {"number": 7, "pull_request": {}}, "comment": {"body": "looks good\n/secrets false-positive config.py:3 test fixture", "user": {"login": "reviewer"}}, "repository": {"name": "repo", "owner": {"login": "john"}}}`
+
+var _ = Describe("Secrets commands", func() {
+	It("parses false-positive and verify commands, ignoring other lines", func() {
+		commands, err := parseSecretsCommands("thanks!\n/secrets false-positive src/a:b.py:42 sample key from the docs\n/secrets verify abc123 live token, rotating")
+		Expect(err).To(BeNil())
+		Expect(commands).To(Equal([]secretsCommand{
+			{Action: "false-positive", Filename: "src/a:b.py", Line: 42, Justification: "sample key from the docs"},
+			{Action: "verify", Hash: "abc123", Justification: "live token, rotating"},
+		}))
+	})
+
+	It("accepts commands without a justification", func() {
+		commands, err := parseSecretsCommands("/secrets false-positive path/to/file:42\n/secrets verify abc123")
+		Expect(err).To(BeNil())
+		Expect(commands).To(Equal([]secretsCommand{
+			{Action: "false-positive", Filename: "path/to/file", Line: 42},
+			{Action: "verify", Hash: "abc123"},
+		}))
+	})
+
+	It("rejects malformed commands", func() {
+		for _, body := range []string{"/secrets false-positive config.py why", "/secrets false-positive config.py:x why", "/secrets ignore abc why",
+			"/secrets verify", "/secrets"} {
+			_, err := parseSecretsCommands(body)
+			Expect(err).NotTo(BeNil(), body)
+		}
+	})
+
+	Context("the issue comment webhook is received", func() {
+		var enqueued []services.Job
+
+		BeforeEach(func() {
+			enqueued = nil
+			services.Config.GitHub.WebhookSecret = "webhook-secret"
+			services.WebhookDeliveries = services.NewDeliveryStore(time.Hour)
+			services.JobQueue = jobQueueMock{EnqueueHandler: func(job services.Job) error {
+				enqueued = append(enqueued, job)
+				return nil
+			}}
+		})
+
+		It("should enqueue an audit for a pull request comment with commands", func() {
+			statusCode, msg := ControllerObject.GitHubWebhook(signedWebhook("issue_comment", "delivery-1", secretsComment))
+			Expect(statusCode).To(Equal(202))
+			Expect(msg).To(Equal("Secrets audit enqueued"))
+			Expect(enqueued).To(HaveLen(1))
+			Expect(enqueued[0].Name).To(ContainSubstring("requested by reviewer on #7"))
+		})
+
+		It("should ignore issue comments and comments without commands", func() {
+			body := `{"action": "created", "issue": {"number": 7}, "comment": {"body": "/secrets verify abc real"}, "repository": {"name": "repo", "owner": {"login": "john"}}}`
+			statusCode, _ := ControllerObject.GitHubWebhook(signedWebhook("issue_comment", "delivery-1", body))
+			Expect(statusCode).To(Equal(202))
+			body = `{"action": "created", "issue": {"number": 7, "pull_request": {}}, "comment": {"body": "nice"}, "repository": {"name": "repo", "owner": {"login": "john"}}}`
+			statusCode, _ = ControllerObject.GitHubWebhook(signedWebhook("issue_comment", "delivery-2", body))
+			Expect(statusCode).To(Equal(202))
+			Expect(enqueued).To(BeEmpty())
+		})
+
+		It("should reply to malformed commands with the usage", func() {
+			body := `{"action": "created", "issue": {"number": 7, "pull_request": {}}, "comment": {"body": "/secrets ignore abc why", "user": {"login": "reviewer"}}, "repository": {"name": "repo", "owner": {"login": "john"}}}`
+			statusCode, msg := ControllerObject.GitHubWebhook(signedWebhook("issue_comment", "delivery-1", body))
+			Expect(statusCode).To(Equal(202))
+			Expect(msg).To(Equal("Secrets command rejected, usage replied"))
+			Expect(enqueued).To(HaveLen(1))
+
+			var reply string
+			services.GitServiceObject = gitServiceMock{CreateIssueCommentHandler: func(owner string, repo string, number int, body string) error {
+				Expect(owner + "/" + repo).To(Equal("john/repo"))
+				Expect(number).To(Equal(7))
+				reply = body
+				return nil
+			}}
+			Expect(enqueued[0].Run(context.Background())).To(Succeed())
+			Expect(reply).To(HavePrefix("@reviewer"))
+			Expect(reply).To(ContainSubstring("unknown command /secrets ignore"))
+			Expect(reply).To(ContainSubstring(secretsCommandUsage))
+		})
+	})
+
+	Context("the audit job runs", func() {
+		var changes SecretUpdateMap
+		var replies []string
+		var description string
+		var checkedOut string
+		gitService := gitServiceMock{}
+		baseline := `{"results": {"config.py": [{"hashed_secret": "aaa", "line_number": 3}, {"hashed_secret": "bbb", "line_number": 8}], "other.py": [{"hashed_secret": "bbb", "line_number": 1}]}}`
+
+		BeforeEach(func() {
+			changes, replies, checkedOut = nil, nil, ""
+			gitService.OpenBaselinePullRequestHandler = func(string, string) (services.BaselinePullRequest, bool, error) {
+				return services.BaselinePullRequest{Number: 7, HeadOwner: "bot", HeadBranch: "secrets", BaseBranch: "main"}, true, nil
+			}
+			gitService.FileContentHandler = func(_ string, _ string, _ string, ref string) ([]byte, error) {
+				Expect(ref).To(Equal("refs/pull/7/head"))
+				return []byte(baseline), nil
+			}
+			gitService.CreateIssueCommentHandler = func(_ string, _ string, number int, body string) error {
+				Expect(number).To(Equal(7))
+				replies = append(replies, body)
+				return nil
+			}
+			gitService.CheckUserAccessRepoHandler = func(string, string) error { return nil }
+			gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
+				return "bot", "http://github.com/bot/repo", nil
+			}
+			gitService.CheckForkedRepoHandler = func(string, string) error { return nil }
+			gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
+				return new(git.Repository), "path", nil
+			}
+			gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
+				Fail("the audit must go to the branch of the baseline PR")
+				return "", "", nil
+			}
+			gitService.CheckoutBranchHandler = func(_ *git.Repository, branch string) error {
+				checkedOut = branch
+				return nil
+			}
+			gitService.EditSecretFileHandler = func(_ string, secretChanges SecretUpdateMap) error {
+				changes = secretChanges
+				return nil
+			}
+			gitService.CommitAndPushHandler = func(string, string, string, string, string, *git.Repository) error {
+				return nil
+			}
+			gitService.OpenPullRequestHandler = func(_ string, _ string, _ string, _ string, _ string, _ string, body string) (int, error) {
+				description = body
+				return 7, services.ErrPullRequestUpdated
+			}
+			services.GitServiceObject = gitService
+		})
+
+		It("should send the audit through the update flow and reply", func() {
+			commands := []secretsCommand{{Action: "false-positive", Filename: "config.py", Line: 3, Justification: "fixture"}, {Action: "verify", Hash: "bbb", Justification: "live"}}
+			Expect(auditFromComment(context.Background(), "", "john", "repo", 7, "reviewer", commands)).To(Succeed())
+			Expect(changes["config.py"]).To(ConsistOf(
+				map[string]interface{}{"hashed_secret": "aaa", "line_number": float64(3), "is_secret": false, "justification": "fixture"},
+				map[string]interface{}{"hashed_secret": "bbb", "line_number": float64(8), "is_secret": true, "justification": "live"},
+			))
+			Expect(description).To(ContainSubstring("| config.py | aaa | false | reviewer | fixture |"))
+			Expect(checkedOut).To(Equal("secrets"))
+			Expect(changes["other.py"]).To(HaveLen(1))
+			Expect(replies).To(HaveLen(1))
+			Expect(replies[0]).To(ContainSubstring("changes were sent"))
+		})
+
+		It("should fill in the justification of a command without one", func() {
+			commands := []secretsCommand{{Action: "false-positive", Filename: "config.py", Line: 3}}
+			Expect(auditFromComment(context.Background(), "", "john", "repo", 7, "reviewer", commands)).To(Succeed())
+			Expect(changes["config.py"]).To(ConsistOf(
+				map[string]interface{}{"hashed_secret": "aaa", "line_number": float64(3), "is_secret": false, "justification": "false-positive by @reviewer on #7"},
+			))
+			Expect(replies[0]).To(ContainSubstring("changes were sent"))
+		})
+
+		It("should refuse commenters without write permission", func() {
+			gitService.CheckUserAccessRepoHandler = func(string, string) error {
+				return errors.New("reviewer has read permission on john/repo, write is required")
+			}
+			services.GitServiceObject = gitService
+			err := auditFromComment(context.Background(), "", "john", "repo", 7, "reviewer", []secretsCommand{{Action: "verify", Hash: "aaa", Justification: "live"}})
+			Expect(err).NotTo(BeNil())
+			Expect(changes).To(BeNil())
+			Expect(replies[0]).To(ContainSubstring("write is required"))
+		})
+
+		It("should only audit the open baseline PR", func() {
+			gitService.OpenBaselinePullRequestHandler = func(string, string) (services.BaselinePullRequest, bool, error) {
+				return services.BaselinePullRequest{Number: 9, HeadOwner: "bot", HeadBranch: "secrets", BaseBranch: "main"}, true, nil
+			}
+			services.GitServiceObject = gitService
+			err := auditFromComment(context.Background(), "", "john", "repo", 7, "reviewer", []secretsCommand{{Action: "verify", Hash: "aaa", Justification: "live"}})
+			Expect(err).NotTo(BeNil())
+			Expect(changes).To(BeNil())
+			Expect(replies[0]).To(ContainSubstring("#7 is not the open pull request"))
+		})
+
+		It("should reply when the secret is not in the baseline", func() {
+			err := auditFromComment(context.Background(), "", "john", "repo", 7, "reviewer", []secretsCommand{{Action: "false-positive", Filename: "config.py", Line: 4, Justification: "fixture"}})
+			Expect(err).NotTo(BeNil())
+			Expect(replies[0]).To(ContainSubstring("no secret on config.py:4"))
+		})
+	})
+})
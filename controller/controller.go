@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	. "github.com/eliezer-borde-globant/EBGoProject/services"
 	. "github.com/eliezer-borde-globant/EBGoProject/utils"
@@ -14,142 +15,176 @@ var (
 type controllerInterface interface {
 	UpdateSecretFile(updateInterface contextInterface) (int, string)
 	CreateSecretFile(createInterface contextInterface) (int, string)
+	GitHubWebhook(webhookInterface webhookContextInterface) (int, string)
+	CreateOrgSecretFiles(createInterface contextInterface) (int, string)
+	OrgRolloutReport(reportInterface contextInterface) (int, string)
+	RepoHistory(historyInterface contextInterface) (int, string)
+	AuditReport(reportInterface contextInterface) (int, string)
 }
 
 type contextInterface interface {
 	BodyParserCreate(data *createParams) error
 	BodyParserUpdate(data *updateParams) error
+	BodyParserOrgCreate(data *orgCreateParams) error
+	Get(key string, defaultValue ...string) string
+	Query(key string, defaultValue ...string) string
+	Params(key string, defaultValue ...string) string
+	Locals(key string, value ...interface{}) interface{}
 	Status(code int) *fiber.Ctx
 }
 
+type webhookContextInterface interface {
+	Body() []byte
+	Get(key string, defaultValue ...string) string
+	Locals(key string, value ...interface{}) interface{}
+}
+
 type controllerImplementation struct { }
 
 
 func (controller controllerImplementation) CreateSecretFile(c contextInterface) (int, string) {
 	data := new(createParams)
 	if err := c.BodyParserCreate(data); err != nil {
-		ZeroLogger.Error().Msgf("contents not parsed correctly: %v", err)
-		return 400, fmt.Sprintf("Error in data, please review input data: %s", err)
+		return failure(requestContext(c), "", ErrCodeInvalidInput, "Error in data, please review input data", err)
 	}
+	data.RequestedBy = requester(c)
+	var description = "Created and added .secrets.baseline file, the bot ran the scan on the whole repo " +
+		"and found all the secrets and placed them in .secrets.baseline file."
+	return createBaseline(requestContext(c), data, "Create", description)
+}
+
+// forks the repo and opens a PR that writes data.Content as the secrets file
+func createBaseline(ctx context.Context, data *createParams, action string, description string) (statusCode int, msg string) {
+	checkpoint := PipelineCheckpoint{PipelineID: NewRequestID()}
+	if !data.DryRun {
+		var claimed bool
+		checkpoint, claimed = claimCheckpoint(ctx, pipelineKey(action, data.RequestedBy, data))
+		if !claimed {
+			return failure(ctx, "", ErrCodeConflict, "The same request is already running", nil)
+		}
+		defer func() {
+			finishCheckpoint(ctx, checkpoint, statusCode)
+		}()
+	}
+	requestID := checkpoint.PipelineID
+	ctx = WithLogFields(ctx, "pipeline_id", requestID, "owner", data.Owner, "repo", data.Repo, "action", action)
+	record := newRequestRecord(requestID, action, data.Provider, data.Host, data.Owner, data.Repo, data.RequestedBy)
+	defer func() {
+		if !data.DryRun {
+			saveRequest(record, statusCode, msg)
+		}
+	}()
 	originalRepoURL := data.Repo
 	originalOwner := data.Owner
-	ZeroLogger.Info().Msgf("REPO: %s", originalRepoURL)
-	ZeroLogger.Info().Msgf("OWNER: %s", originalOwner)
-
-	_, err := GitServiceObject.CheckUserAccessRepo(originalOwner, originalRepoURL)
+	gitService, err := GitProvider(ctx, data.Provider, data.Host)
 	if err != nil {
-		ZeroLogger.Error().Msgf("access denied: %v", err)
-		return 403, "You do not have access to the repo"
+		return failure(ctx, requestID, ErrCodeInvalidInput, "Error in data, please review input data", err)
 	}
-
-	_forkOwner, _, err := GitServiceObject.ForkRepo(originalOwner, originalRepoURL)
+	err = authorizeOrg(ctx, originalOwner)
 	if err != nil {
-		ZeroLogger.Error().Msgf("Fork Error: %v", err)
-		return 400, fmt.Sprintf("Error Forking Repo: %v", err)
+		return failure(ctx, requestID, ErrCodeForbidden, "You do not have access to the repo", err)
 	}
-
-	getURL := fmt.Sprintf("https://%s@api.github.com/repos/%s/%s", GitHubToken, _forkOwner, originalRepoURL)
-
-	err = GitServiceObject.CheckForkedRepo(getURL)
-
+	err = gitService.CheckUserAccessRepo(originalOwner, originalRepoURL)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Repo didn't fork properly: %v", err)
-		ZeroLogger.Error().Msg(errorMsg)
-		return 400, errorMsg
+		return failure(ctx, requestID, ErrCodeForbidden, "You do not have access to the repo", err)
 	}
-
-	forkOwner := fmt.Sprintf("%v", _forkOwner)
-	ZeroLogger.Info().Msgf("Owner who forked the repo: %s", forkOwner)
-
-	forkedRepoURL, path, err := GitServiceObject.CloneRepo(forkOwner, originalRepoURL)
-	if err != nil {
-		ZeroLogger.Error().Msgf("Error: %v", err)
-		return 400, fmt.Sprintf("Error Cloning Repo: %v", err)
+	writeFile := func(path string) error {
+		return gitService.CreateSecretFile(path, data.Content)
 	}
-
-	currentBranch, headBranch, err := GitServiceObject.CreateBranchRepo(forkedRepoURL, originalRepoURL, "create")
-	if err != nil {
-		ZeroLogger.Error().Msgf("Branch not created: %v", err)
-		return 400, fmt.Sprintf("Error Creating Branch: %s", err)
+	if data.DryRun {
+		return previewBaseline(ctx, requestID, data.Provider, data.Host, originalOwner, originalRepoURL, action, description, writeFile)
 	}
 
-	err = GitServiceObject.CreateSecretFile(path, data.Content)
-	if err != nil {
-		ZeroLogger.Error().Msgf("Secrets file not created: %v", err)
-		return 400, fmt.Sprintf("Error creating %s file: %v", SecretsFileName, err)
+	steps := pipeline{
+		checkpoint:   checkpoint,
+		record:       &record,
+		action:       action,
+		description:  description,
+		write:        writeFile,
+		writeCode:    ErrCodeInternal,
+		writeMessage: fmt.Sprintf("Error creating %s file", Config.Scanner.SecretsFileName),
 	}
-
-	var description = "Created and added .secrets.baseline file, the bot ran the scan on the whole repo " +
-		"and found all the secrets and placed them in .secrets.baseline file."
-	err = GitServiceObject.CreateCommitAndPr(forkOwner, originalOwner, originalRepoURL, currentBranch, headBranch, "Create", description, forkedRepoURL)
-	if err != nil {
-		ZeroLogger.Info().Msg("Updated the existing PR")
-		return 200, fmt.Sprintf("PR was Updated !")
-	}
-	ZeroLogger.Info().Msg("PR was Created Successfully!")
-	return 200, "PR was Created !"
-
+	return steps.run(ctx, gitService)
 }
 
 func (controller controllerImplementation) UpdateSecretFile(c contextInterface) (int, string) {
 	data := new(updateParams)
 	if err := c.BodyParserUpdate(data); err != nil {
-		ZeroLogger.Error().Msgf("contents not parsed correctly: %v", err)
-		return 400, fmt.Sprintf("Error in data, please review input data: %s", err)
+		return failure(requestContext(c), "", ErrCodeInvalidInput, "Error in data, please review input data", err)
 	}
+	data.RequestedBy = requester(c)
+	var description = "Updated .secrets.baseline file, the user marked the secrets as false positive and " +
+		"sent those changes to the repo."
+	return updateBaseline(requestContext(c), data, description)
+}
+
+// forks the repo and opens a PR applying the audit changes of data to the secrets file
+func updateBaseline(ctx context.Context, data *updateParams, description string) (statusCode int, msg string) {
+	checkpoint := PipelineCheckpoint{PipelineID: NewRequestID()}
+	if !data.DryRun {
+		var claimed bool
+		checkpoint, claimed = claimCheckpoint(ctx, pipelineKey("Update", data.RequestedBy, data))
+		if !claimed {
+			return failure(ctx, "", ErrCodeConflict, "The same request is already running", nil)
+		}
+		defer func() {
+			finishCheckpoint(ctx, checkpoint, statusCode)
+		}()
+	}
+	requestID := checkpoint.PipelineID
+	ctx = WithLogFields(ctx, "pipeline_id", requestID, "owner", data.Owner, "repo", data.Repo, "action", "Update")
+	record := newRequestRecord(requestID, "Update", data.Provider, data.Host, data.Owner, data.Repo, data.RequestedBy)
+	defer func() {
+		if !data.DryRun {
+			saveRequest(record, statusCode, msg)
+		}
+	}()
 	originalRepoURL := data.Repo
 	originalOwner := data.Owner
-	_, err := GitServiceObject.CheckUserAccessRepo(originalOwner, originalRepoURL)
-	if err != nil {
-		ZeroLogger.Error().Msgf("access denied: %v", err)
-		return 403, "You do not have access to the repo"
-	}
-
-	_forkOwner, _, err := GitServiceObject.ForkRepo(originalOwner, originalRepoURL)
-	if err != nil {
-		ZeroLogger.Error().Msgf("Fork Error: %v", err)
-		return 400, fmt.Sprintf("Error Forking Repo: %v", err)
-	}
-
-	getURL := fmt.Sprintf("https://%s@api.github.com/repos/%s/%s", GitHubToken, _forkOwner, originalRepoURL)
-
-	err = GitServiceObject.CheckForkedRepo(getURL)
-
+	gitService, err := GitProvider(ctx, data.Provider, data.Host)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Repo didn't fork properly: %v", err)
-		ZeroLogger.Error().Msg(errorMsg)
-		return 400, errorMsg
+		return failure(ctx, requestID, ErrCodeInvalidInput, "Error in data, please review input data", err)
 	}
-
-	forkOwner := fmt.Sprintf("%v", _forkOwner)
-	ZeroLogger.Info().Msgf("Owner who forked the repo: %s", forkOwner)
-
-	forkedRepoURL, path, err := GitServiceObject.CloneRepo(forkOwner, originalRepoURL)
+	decisions, err := auditDecisions(ctx, record, data.Changes)
 	if err != nil {
-		ZeroLogger.Error().Msgf("Error: %v", err)
-		return 400, fmt.Sprintf("Error Cloning Repo: %v", err)
+		return failure(ctx, requestID, ErrCodeInvalidInput, "Error in data, please review input data", err)
 	}
-
-	currentBranch, headBranch, err := GitServiceObject.CreateBranchRepo(forkedRepoURL, originalRepoURL, "create")
-	if err != nil {
-		ZeroLogger.Error().Msgf("Branch not created: %v", err)
-		return 400, fmt.Sprintf("Error Creating Branch: %s", err)
+	if data.AuditInPR {
+		description += auditTable(decisions)
 	}
-
-	err = GitServiceObject.EditSecretFile(path, data.Changes)
+	err = authorizeOrg(ctx, originalOwner)
 	if err != nil {
-		ZeroLogger.Error().Msgf("Error editing the %s file: %v", SecretsFileName, err)
-		return 400, fmt.Sprintf("Cannot edit %s file", SecretsFileName)
+		return failure(ctx, requestID, ErrCodeForbidden, "You do not have access to the repo", err)
 	}
-
-	var description = "Updated .secrets.baseline file, the user marked the secrets as false positive and " +
-		"sent those changes to the repo."
-	err = GitServiceObject.CreateCommitAndPr(forkOwner, originalOwner, originalRepoURL, currentBranch, headBranch, "Update", description, forkedRepoURL)
+	err = gitService.CheckUserAccessRepo(originalOwner, originalRepoURL)
 	if err != nil {
-		ZeroLogger.Info().Msg("Updated the existing PR")
-		return 200, fmt.Sprintf("PR was Updated !")
-	}
-	ZeroLogger.Info().Msg("PR was Created Successfully!")
-	return 200, "PR was Created !"
-
+		return failure(ctx, requestID, ErrCodeForbidden, "You do not have access to the repo", err)
+	}
+	applyChanges := func(path string) error {
+		if err := gitService.EditSecretFile(path, data.Changes); err != nil {
+			return err
+		}
+		if data.AuditFile {
+			return gitService.WriteAuditFile(path, decisions)
+		}
+		return nil
+	}
+	if data.DryRun {
+		return previewBaseline(ctx, requestID, data.Provider, data.Host, originalOwner, originalRepoURL, "Update", description, applyChanges)
+	}
+
+	steps := pipeline{
+		checkpoint:   checkpoint,
+		record:       &record,
+		action:       "Update",
+		description:  description,
+		write:        applyChanges,
+		writeCode:    ErrCodeInvalidInput,
+		writeMessage: fmt.Sprintf("Cannot edit %s file", Config.Scanner.SecretsFileName),
+	}
+	statusCode, msg = steps.run(ctx, gitService)
+	if statusCode == 200 {
+		saveAuditDecisions(decisions)
+	}
+	return statusCode, msg
 }
\ No newline at end of file
@@ -5,10 +5,83 @@ type updateParams struct {
 	Repo    string                              `json:"repo" xml:"repo" form:"repo"`
 	Owner   string                              `json:"owner" xml:"owner" form:"owner"`
 	Changes map[string][]map[string]interface{} `json:"changes" xml:"changes" form:"changes"`
+	Provider string                             `json:"provider" xml:"provider" form:"provider"`
+	Host     string                             `json:"host" xml:"host" form:"host"`
+	DryRun   bool                               `json:"dry_run" xml:"dry_run" form:"dry_run"`
+	AuditInPR bool                              `json:"audit_in_pr" xml:"audit_in_pr" form:"audit_in_pr"`
+	AuditFile bool                              `json:"audit_file" xml:"audit_file" form:"audit_file"`
+	RequestedBy string                          `json:"-" xml:"-" form:"-"`
 }
 
 type createParams struct {
 	Repo    string `json:"repo" xml:"repo" form:"repo"`
 	Owner   string `json:"owner" xml:"owner" form:"owner"`
 	Content string `json:"content" xml:"content" form:"content"`
+	Provider string `json:"provider" xml:"provider" form:"provider"`
+	Host     string `json:"host" xml:"host" form:"host"`
+	DryRun   bool   `json:"dry_run" xml:"dry_run" form:"dry_run"`
+	RequestedBy string `json:"-" xml:"-" form:"-"`
+}
+
+type orgCreateParams struct {
+	Languages       []string `json:"languages" xml:"languages" form:"languages"`
+	Topics          []string `json:"topics" xml:"topics" form:"topics"`
+	NameRegex       string   `json:"name_regex" xml:"name_regex" form:"name_regex"`
+	IncludeArchived bool     `json:"include_archived" xml:"include_archived" form:"include_archived"`
+	Concurrency     int      `json:"concurrency" xml:"concurrency" form:"concurrency"`
+	Host            string   `json:"host" xml:"host" form:"host"`
+}
+
+type pushEventParams struct {
+	Ref        string `json:"ref"`
+	After      string `json:"after"`
+	Deleted    bool   `json:"deleted"`
+	Repository struct {
+		Name          string `json:"name"`
+		DefaultBranch string `json:"default_branch"`
+		Owner         struct {
+			Login string `json:"login"`
+			Name  string `json:"name"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+type pullRequestEventParams struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			SHA string `json:"sha"`
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+type issueCommentEventParams struct {
+	Action string `json:"action"`
+	Issue  struct {
+		Number      int         `json:"number"`
+		PullRequest interface{} `json:"pull_request"`
+	} `json:"issue"`
+	Comment struct {
+		Body string `json:"body"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"comment"`
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
 }
@@ -0,0 +1,24 @@
+package controller_test
+
+import (
+	"testing"
+
+	"github.com/eliezer-borde-globant/EBGoProject/config"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// every spec starts with the default configuration and an empty history, the checkpoint left
+// by a pipeline that failed in one spec must not be resumed by the next one
+var _ = BeforeEach(func() {
+	services.Config = config.Defaults()
+	history, err := services.OpenHistory("sqlite3", ":memory:")
+	Expect(err).To(BeNil())
+	services.History = history
+})
+
+func TestController(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Controller Suite")
+}
@@ -23,8 +23,8 @@ var _ = Describe("Controller", func() {
 			gitService.GetGitHubClientHandler = func() *github.Client {
 				return new(github.Client)
 			}
-			gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-				return new(github.Repository), nil
+			gitService.CheckUserAccessRepoHandler = func(string, string) error {
+				return nil
 			}
 			gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 				return "username", "http://github.com/username/test", nil
@@ -32,16 +32,19 @@ var _ = Describe("Controller", func() {
 			gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
 				return new(git.Repository), "path", nil
 			}
-			gitService.CreateBranchRepoHandler = func(*git.Repository, string, string) (string, string, error) {
+			gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
 				return "branch", "headBranch", nil
 			}
 			gitService.CreateSecretFileHandler = func(string, string) error {
 				return nil
 			}
-			gitService.CreateCommitAndPrHandler = func(string, string, string, string, string, string, string, *git.Repository) error {
+			gitService.CommitAndPushHandler = func(string, string, string, string, string, *git.Repository) error {
 				return nil
 			}
-			gitService.CheckForkedRepoHandler = func(string) error {
+			gitService.OpenPullRequestHandler = func(string, string, string, string, string, string, string) (int, error) {
+				return 1, nil
+			}
+			gitService.CheckForkedRepoHandler = func(string, string) error {
 				return nil
 			}
 			context.BodyParserCreateHandler = func(*createParams) error {
@@ -57,10 +60,24 @@ var _ = Describe("Controller", func() {
 				Expect(msg).To(Equal("PR was Created !"))
 			})
 
+			Context("the provider is not supported", func() {
+				It("should return the input data error",  func() {
+					unsupported := context
+					unsupported.BodyParserCreateHandler = func(data *createParams) error {
+						data.Provider = "svn"
+						return nil
+					}
+					services.GitServiceObject = gitService
+					statusCode, msg := ControllerObject.CreateSecretFile(unsupported)
+					Expect(statusCode).To(Equal(400))
+					Expect(strings.Contains(msg, "unsupported git provider")).To(BeTrue())
+				})
+			})
+
 			Context("user doesn't have access to the repo", func() {
 				It("should return the user access error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return nil , errors.New("error in checkUserAccess service")
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return errors.New("error in checkUserAccess service")
 					}
 					services.GitServiceObject = gitService
 					statusCode, msg := ControllerObject.CreateSecretFile(context)
@@ -71,46 +88,46 @@ var _ = Describe("Controller", func() {
 
 			Context("problem occurs while forking the repo", func() {
 				It("should return the fork error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "", "", errors.New("error in forkRepo service")
 					}
 					services.GitServiceObject = gitService
 					status, msg := ControllerObject.CreateSecretFile(context)
-					Expect(status).To(Equal(400))
+					Expect(status).To(Equal(502))
 					Expect(strings.Contains(msg, "Error Forking Repo")).To(BeTrue())
 				})
 			})
 
 			Context("check for forked repo fails", func() {
 				It("should return the error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "username", "http://github.com/username/test", nil
 					}
-					gitService.CheckForkedRepoHandler = func(string) error {
+					gitService.CheckForkedRepoHandler = func(string, string) error {
 						return errors.New("error in checkRepo service")
 					}
 					services.GitServiceObject = gitService
 					status, msg := ControllerObject.CreateSecretFile(context)
-					Expect(status).To(Equal(400))
+					Expect(status).To(Equal(502))
 					Expect(strings.Contains(msg, "Repo didn't fork properly")).To(BeTrue())
 				})
 			})
 
 			Context("there is problem in cloning the repo", func() {
 				It("should return the clone error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "username", "http://github.com/username/test", nil
 					}
-					gitService.CheckForkedRepoHandler = func(string) error {
+					gitService.CheckForkedRepoHandler = func(string, string) error {
 						return nil
 					}
 					gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
@@ -118,48 +135,48 @@ var _ = Describe("Controller", func() {
 					}
 					services.GitServiceObject = gitService
 					statusCode, msg := ControllerObject.CreateSecretFile(context)
-					Expect(statusCode).To(Equal(400))
+					Expect(statusCode).To(Equal(502))
 					Expect(strings.Contains(msg, "Error Cloning Repo")).To(BeTrue())
 				})
 			})
 			Context("problem occurs in creating branch", func() {
 				It("should return error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "username", "http://github.com/username/test", nil
 					}
-					gitService.CheckForkedRepoHandler = func(string) error {
+					gitService.CheckForkedRepoHandler = func(string, string) error {
 						return nil
 					}
 					gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
 						return new(git.Repository), "path", nil
 					}
-					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string) (string, string, error) {
+					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
 						return "", "", errors.New("error in createBranch service")
 					}
 					services.GitServiceObject = gitService
 					statusCode, msg := ControllerObject.CreateSecretFile(context)
-					Expect(statusCode).To(Equal(400))
+					Expect(statusCode).To(Equal(502))
 					Expect(strings.Contains(msg, "Error Creating Branch")).To(BeTrue())
 				})
 			})
 			Context("problem occurs in creating secrets file", func() {
 				It("should return error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "username", "http://github.com/username/test", nil
 					}
-					gitService.CheckForkedRepoHandler = func(string) error {
+					gitService.CheckForkedRepoHandler = func(string, string) error {
 						return nil
 					}
 					gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
 						return new(git.Repository), "path", nil
 					}
-					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string) (string, string, error) {
+					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
 						return "branch", "headBranch", nil
 					}
 					gitService.CreateSecretFileHandler = func(string, string) error {
@@ -167,37 +184,48 @@ var _ = Describe("Controller", func() {
 					}
 					services.GitServiceObject = gitService
 					statusCode, msg := ControllerObject.CreateSecretFile(context)
-					Expect(statusCode).To(Equal(400))
-					Expect(strings.Contains(msg, fmt.Sprintf("Error creating %s file", SecretsFileName))).To(BeTrue())
+					Expect(statusCode).To(Equal(500))
+					Expect(strings.Contains(msg, fmt.Sprintf("Error creating %s file", services.Config.Scanner.SecretsFileName))).To(BeTrue())
 				})
 			})
 			Context("creating of PR fails", func() {
 				It("should return error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "username", "http://github.com/username/test", nil
 					}
-					gitService.CheckForkedRepoHandler = func(string) error {
+					gitService.CheckForkedRepoHandler = func(string, string) error {
 						return nil
 					}
 					gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
 						return new(git.Repository), "path", nil
 					}
-					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string) (string, string, error) {
+					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
 						return "branch", "headBranch", nil
 					}
 					gitService.CreateSecretFileHandler = func(string, string) error {
 						return nil
 					}
-					gitService.CreateCommitAndPrHandler = func(string, string, string, string, string, string, string, *git.Repository) error {
-						return errors.New("error in creating PR service")
+					gitService.OpenPullRequestHandler = func(string, string, string, string, string, string, string) (int, error) {
+						return 0, errors.New("error in creating PR service")
+					}
+					services.GitServiceObject = gitService
+					statusCode, msg := ControllerObject.CreateSecretFile(context)
+					Expect(statusCode).To(Equal(502))
+					Expect(strings.Contains(msg, "Error Opening Pull Request")).To(BeTrue())
+				})
+			})
+			Context("the PR already exists", func() {
+				It("should report the existing PR as updated", func() {
+					gitService.OpenPullRequestHandler = func(string, string, string, string, string, string, string) (int, error) {
+						return 12, services.ErrPullRequestUpdated
 					}
 					services.GitServiceObject = gitService
 					statusCode, msg := ControllerObject.CreateSecretFile(context)
 					Expect(statusCode).To(Equal(200))
-					Expect(strings.Contains(msg, "PR was Updated !")).To(BeTrue())
+					Expect(msg).To(Equal("PR was Updated !"))
 				})
 			})
 		})
@@ -210,8 +238,8 @@ var _ = Describe("Controller", func() {
 			gitService.GetGitHubClientHandler = func() *github.Client {
 				return new(github.Client)
 			}
-			gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-				return new(github.Repository), nil
+			gitService.CheckUserAccessRepoHandler = func(string, string) error {
+				return nil
 			}
 			gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 				return "username", "http://github.com/username/test", nil
@@ -219,16 +247,19 @@ var _ = Describe("Controller", func() {
 			gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
 				return new(git.Repository), "path", nil
 			}
-			gitService.CreateBranchRepoHandler = func(*git.Repository, string, string) (string, string, error) {
+			gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
 				return "branch", "headBranch", nil
 			}
 			gitService.EditSecretFileHandler = func(string, SecretUpdateMap) error {
 				return nil
 			}
-			gitService.CreateCommitAndPrHandler = func(string, string, string, string, string, string, string, *git.Repository) error {
+			gitService.CommitAndPushHandler = func(string, string, string, string, string, *git.Repository) error {
 				return nil
 			}
-			gitService.CheckForkedRepoHandler = func(string) error {
+			gitService.OpenPullRequestHandler = func(string, string, string, string, string, string, string) (int, error) {
+				return 1, nil
+			}
+			gitService.CheckForkedRepoHandler = func(string, string) error {
 				return nil
 			}
 			context.BodyParserUpdateHandler = func(*updateParams) error {
@@ -246,8 +277,8 @@ var _ = Describe("Controller", func() {
 
 			Context("user doesn't have access to the repo", func() {
 				It("should return the user access error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return nil , errors.New("error in checkUserAccess service")
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return errors.New("error in checkUserAccess service")
 					}
 					services.GitServiceObject = gitService
 					statusCode, msg := ControllerObject.UpdateSecretFile(context)
@@ -258,46 +289,46 @@ var _ = Describe("Controller", func() {
 
 			Context("problem occurs while forking the repo", func() {
 				It("should return the fork error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "", "", errors.New("error in forkRepo service")
 					}
 					services.GitServiceObject = gitService
 					status, msg := ControllerObject.UpdateSecretFile(context)
-					Expect(status).To(Equal(400))
+					Expect(status).To(Equal(502))
 					Expect(strings.Contains(msg, "Error Forking Repo")).To(BeTrue())
 				})
 			})
 
 			Context("check for forked repo fails", func() {
 				It("should return the error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "username", "http://github.com/username/test", nil
 					}
-					gitService.CheckForkedRepoHandler = func(string) error {
+					gitService.CheckForkedRepoHandler = func(string, string) error {
 						return errors.New("error in checkRepo service")
 					}
 					services.GitServiceObject = gitService
 					status, msg := ControllerObject.UpdateSecretFile(context)
-					Expect(status).To(Equal(400))
+					Expect(status).To(Equal(502))
 					Expect(strings.Contains(msg, "Repo didn't fork properly")).To(BeTrue())
 				})
 			})
 
 			Context("there is problem in cloning the repo", func() {
 				It("should return the clone error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "username", "http://github.com/username/test", nil
 					}
-					gitService.CheckForkedRepoHandler = func(string) error {
+					gitService.CheckForkedRepoHandler = func(string, string) error {
 						return nil
 					}
 					gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
@@ -305,48 +336,48 @@ var _ = Describe("Controller", func() {
 					}
 					services.GitServiceObject = gitService
 					statusCode, msg := ControllerObject.UpdateSecretFile(context)
-					Expect(statusCode).To(Equal(400))
+					Expect(statusCode).To(Equal(502))
 					Expect(strings.Contains(msg, "Error Cloning Repo")).To(BeTrue())
 				})
 			})
 			Context("problem occurs in creating branch", func() {
 				It("should return error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "username", "http://github.com/username/test", nil
 					}
-					gitService.CheckForkedRepoHandler = func(string) error {
+					gitService.CheckForkedRepoHandler = func(string, string) error {
 						return nil
 					}
 					gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
 						return new(git.Repository), "path", nil
 					}
-					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string) (string, string, error) {
+					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
 						return "", "", errors.New("error in createBranch service")
 					}
 					services.GitServiceObject = gitService
 					statusCode, msg := ControllerObject.UpdateSecretFile(context)
-					Expect(statusCode).To(Equal(400))
+					Expect(statusCode).To(Equal(502))
 					Expect(strings.Contains(msg, "Error Creating Branch")).To(BeTrue())
 				})
 			})
 			Context("problem occurs in editing secrets file", func() {
 				It("should return error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "username", "http://github.com/username/test", nil
 					}
-					gitService.CheckForkedRepoHandler = func(string) error {
+					gitService.CheckForkedRepoHandler = func(string, string) error {
 						return nil
 					}
 					gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
 						return new(git.Repository), "path", nil
 					}
-					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string) (string, string, error) {
+					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
 						return "branch", "headBranch", nil
 					}
 					gitService.EditSecretFileHandler = func(string, SecretUpdateMap) error {
@@ -355,36 +386,47 @@ var _ = Describe("Controller", func() {
 					services.GitServiceObject = gitService
 					statusCode, msg := ControllerObject.UpdateSecretFile(context)
 					Expect(statusCode).To(Equal(400))
-					Expect(strings.Contains(msg, fmt.Sprintf("Cannot edit %s file", SecretsFileName))).To(BeTrue())
+					Expect(strings.Contains(msg, fmt.Sprintf("Cannot edit %s file", services.Config.Scanner.SecretsFileName))).To(BeTrue())
 				})
 			})
 			Context("creating of PR fails", func() {
 				It("should return error",  func() {
-					gitService.CheckUserAccessRepoHandler = func(string, string) (*github.Repository, error) {
-						return new(github.Repository), nil
+					gitService.CheckUserAccessRepoHandler = func(string, string) error {
+						return nil
 					}
 					gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
 						return "username", "http://github.com/username/test", nil
 					}
-					gitService.CheckForkedRepoHandler = func(string) error {
+					gitService.CheckForkedRepoHandler = func(string, string) error {
 						return nil
 					}
 					gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
 						return new(git.Repository), "path", nil
 					}
-					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string) (string, string, error) {
+					gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
 						return "branch", "headBranch", nil
 					}
 					gitService.EditSecretFileHandler = func(string, SecretUpdateMap) error {
 						return nil
 					}
-					gitService.CreateCommitAndPrHandler = func(string, string, string, string, string, string, string, *git.Repository) error {
-						return errors.New("error in creating PR service")
+					gitService.OpenPullRequestHandler = func(string, string, string, string, string, string, string) (int, error) {
+						return 0, errors.New("error in creating PR service")
+					}
+					services.GitServiceObject = gitService
+					statusCode, msg := ControllerObject.UpdateSecretFile(context)
+					Expect(statusCode).To(Equal(502))
+					Expect(strings.Contains(msg, "Error Opening Pull Request")).To(BeTrue())
+				})
+			})
+			Context("the PR already exists", func() {
+				It("should report the existing PR as updated", func() {
+					gitService.OpenPullRequestHandler = func(string, string, string, string, string, string, string) (int, error) {
+						return 12, services.ErrPullRequestUpdated
 					}
 					services.GitServiceObject = gitService
 					statusCode, msg := ControllerObject.UpdateSecretFile(context)
 					Expect(statusCode).To(Equal(200))
-					Expect(strings.Contains(msg, "PR was Updated !")).To(BeTrue())
+					Expect(msg).To(Equal("PR was Updated !"))
 				})
 			})
 		})
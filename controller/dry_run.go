@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"strings"
+)
+
+type plannedPullRequest struct {
+	Title         string `json:"title"`
+	Body          string `json:"body"`
+	Head          string `json:"head"`
+	Base          string `json:"base"`
+	CommitMessage string `json:"commit_message"`
+}
+
+type dryRunResult struct {
+	DryRun      bool               `json:"dry_run"`
+	Content     string             `json:"content"`
+	Diff        BaselineDiff       `json:"diff"`
+	PullRequest plannedPullRequest `json:"pull_request"`
+}
+
+// runs the create or update flow up to the file change in a scratch clone of the original
+// repo, nothing is forked, pushed or opened, and reports what the PR would contain
+func previewBaseline(ctx context.Context, requestID string, provider string, host string, owner string, repo string, action string, description string, apply func(path string) error) (int, string) {
+	gitService, err := GitProvider(ctx, provider, host)
+	if err != nil {
+		return failure(ctx, requestID, ErrCodeInvalidInput, "Error in data, please review input data", err)
+	}
+	repoGit, path, err := gitService.CloneRepo(owner, repo)
+	if err != nil {
+		return failure(ctx, requestID, ErrCodeUpstream, "Error Cloning Repo", err)
+	}
+	defer removeClone(ctx, path)
+
+	currentBranch, headBranch, err := gitService.CreateBranchRepo(repoGit, owner, repo, strings.ToLower(action), requestID)
+	if err != nil {
+		return failure(ctx, requestID, ErrCodeUpstream, "Error Creating Branch", err)
+	}
+	before, err := ReadBaseline(path)
+	if err != nil {
+		return failure(ctx, requestID, ErrCodeInvalidInput, fmt.Sprintf("Cannot read %s file", Config.Scanner.SecretsFileName), err)
+	}
+	if err := apply(path); err != nil {
+		return failure(ctx, requestID, ErrCodeInvalidInput, fmt.Sprintf("Cannot write %s file", Config.Scanner.SecretsFileName), err)
+	}
+	after, err := ReadBaseline(path)
+	if err != nil {
+		return failure(ctx, requestID, ErrCodeInvalidInput, fmt.Sprintf("Cannot read %s file", Config.Scanner.SecretsFileName), err)
+	}
+	diff, err := DiffBaselines(before, after)
+	if err != nil {
+		return failure(ctx, requestID, ErrCodeInvalidInput, fmt.Sprintf("Cannot compare %s files", Config.Scanner.SecretsFileName), err)
+	}
+	commitMessage, err := CommitMessage(owner, repo, action, requestID)
+	if err != nil {
+		return failure(ctx, requestID, ErrCodeInvalidInput, "Error building commit message", err)
+	}
+
+	result, err := json.Marshal(dryRunResult{
+		DryRun:  true,
+		Content: string(after),
+		Diff:    diff,
+		PullRequest: plannedPullRequest{
+			Title:         PullRequestTitle(action),
+			Body:          description,
+			Head:          currentBranch,
+			Base:          headBranch,
+			CommitMessage: commitMessage,
+		},
+	})
+	if err != nil {
+		return failure(ctx, requestID, ErrCodeInternal, "Cannot encode the dry run result", err)
+	}
+	Logger(ctx).Info().Msgf("Dry run of %s on %s/%s finished", action, owner, repo)
+	return 200, string(result)
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/go-git/go-git/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+)
+
+var _ = Describe("Dry run", func() {
+	var path string
+	var cloned string
+	gitService := gitServiceMock{}
+
+	BeforeEach(func() {
+		var err error
+		path, err = ioutil.TempDir("", "dry-run")
+		Expect(err).To(BeNil())
+		Expect(ioutil.WriteFile(path+"/"+services.Config.Scanner.SecretsFileName, []byte(`{"results": {"a.py": [{"hashed_secret": "aaa", "line_number": 1}]}}`), 0644)).To(Succeed())
+		cloned = ""
+		gitService.CheckUserAccessRepoHandler = func(string, string) error { return nil }
+		gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
+			Fail("a dry run must not fork")
+			return nil, nil, nil
+		}
+		gitService.CommitAndPushHandler = func(string, string, string, string, string, *git.Repository) error {
+			Fail("a dry run must not push nor open a PR")
+			return nil
+		}
+		gitService.OpenPullRequestHandler = func(string, string, string, string, string, string, string) (int, error) {
+			Fail("a dry run must not push nor open a PR")
+			return 0, nil
+		}
+		gitService.CloneRepoHandler = func(owner string, _ string) (*git.Repository, string, error) {
+			cloned = owner
+			services.Clones.Add(path)
+			return new(git.Repository), path, nil
+		}
+		gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
+			return "branch", "main", nil
+		}
+		gitService.CreateSecretFileHandler = func(path string, content string) error {
+			return ioutil.WriteFile(path+"/"+services.Config.Scanner.SecretsFileName, []byte(content), 0644)
+		}
+		gitService.EditSecretFileHandler = func(path string, _ SecretUpdateMap) error {
+			return ioutil.WriteFile(path+"/"+services.Config.Scanner.SecretsFileName, []byte(`{"results": {"a.py": [{"hashed_secret": "aaa", "line_number": 1, "is_secret": false}]}}`), 0644)
+		}
+		services.GitServiceObject = gitService
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(path)
+	})
+
+	It("should preview a create without forking and remove the scratch clone", func() {
+		context := contextMock{BodyParserCreateHandler: func(data *createParams) error {
+			*data = createParams{Owner: "john", Repo: "repo", DryRun: true,
+				Content: `{"results": {"a.py": [{"hashed_secret": "aaa", "line_number": 1}], "b.py": [{"hashed_secret": "bbb", "line_number": 2}]}}`}
+			return nil
+		}}
+		statusCode, msg := ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(200))
+		Expect(cloned).To(Equal("john"))
+		var result dryRunResult
+		Expect(json.Unmarshal([]byte(msg), &result)).To(Succeed())
+		Expect(result.DryRun).To(BeTrue())
+		Expect(result.Content).To(ContainSubstring("bbb"))
+		Expect(result.Diff.Added).To(HaveKey("b.py"))
+		Expect(result.Diff.Removed).To(BeEmpty())
+		Expect(result.PullRequest.Title).To(Equal("[Detect Secrets] Create Secret BaseLine File"))
+		Expect(result.PullRequest.Head).To(Equal("branch"))
+		Expect(result.PullRequest.Base).To(Equal("main"))
+		Expect(result.PullRequest.CommitMessage).To(Equal("chore: Create secret baseline file"))
+		_, err := os.Stat(path)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should leave alone the directories it did not clone", func() {
+		gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
+			return new(git.Repository), path, nil
+		}
+		services.GitServiceObject = gitService
+		context := contextMock{BodyParserCreateHandler: func(data *createParams) error {
+			*data = createParams{Owner: "john", Repo: "repo", DryRun: true, Content: `{"results": {}}`}
+			return nil
+		}}
+		statusCode, _ := ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(200))
+		Expect(path).To(BeADirectory())
+	})
+
+	It("should preview the audit changes of an update", func() {
+		context := contextMock{BodyParserUpdateHandler: func(data *updateParams) error {
+			*data = updateParams{Owner: "john", Repo: "repo", DryRun: true}
+			return nil
+		}}
+		statusCode, msg := ControllerObject.UpdateSecretFile(context)
+		Expect(statusCode).To(Equal(200))
+		var result dryRunResult
+		Expect(json.Unmarshal([]byte(msg), &result)).To(Succeed())
+		Expect(result.Diff.Changed).To(HaveLen(1))
+		Expect(result.Diff.Changed[0].Field).To(Equal("is_secret"))
+		Expect(result.PullRequest.Title).To(Equal("[Detect Secrets] Update Secret BaseLine File"))
+	})
+})
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+)
+
+// body of every error answered by the API, details is the underlying error without its
+// credentials or the list of fields that did not match the schema
+type errorResponse struct {
+	Code      ErrorCode   `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id"`
+}
+
+func errorBody(requestID string, code ErrorCode, message string, details interface{}) string {
+	content, _ := json.Marshal(errorResponse{Code: code, Message: message, Details: details, RequestID: requestID})
+	return string(content)
+}
+
+// status and body of a failed request, the code comes from err when it is a typed error and
+// from fallback otherwise. An empty requestID answers the id of the request in ctx
+func failure(ctx context.Context, requestID string, fallback ErrorCode, message string, err error) (int, string) {
+	if requestID == "" {
+		requestID = RequestID(ctx)
+	}
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+	code := fallback
+	var details interface{}
+	if err != nil {
+		code = ClassifyError(err, fallback)
+		details = RedactError(err)
+	}
+	event := Logger(ctx).Error().Str("code", string(code))
+	if requestID != RequestID(ctx) {
+		event = event.Str("error_id", requestID)
+	}
+	event.Msgf("%s: %v", message, details)
+	return code.HTTPStatus(), errorBody(requestID, code, message, details)
+}
+
+// turns the body of a failed request back into an error, for the flows that call the
+// controller from background jobs
+func failureError(body string) error {
+	response := errorResponse{Code: ErrCodeInternal, Message: body}
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		return NewServiceError(ErrCodeInternal, body, nil)
+	}
+	if details, ok := response.Details.(string); ok && details != "" {
+		return NewServiceError(response.Code, fmt.Sprintf("%s: %s", response.Message, details), nil)
+	}
+	return NewServiceError(response.Code, response.Message, nil)
+}
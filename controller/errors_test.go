@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	"github.com/google/go-github/v33/github"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+var _ = Describe("Errors", func() {
+	It("should answer a JSON body with the code, message, details and request id", func() {
+		statusCode, msg := failure(context.Background(), "a1b2c3d4", services.ErrCodeUpstream, "Error Forking Repo", errors.New("connection reset"))
+		Expect(statusCode).To(Equal(502))
+		var body errorResponse
+		Expect(json.Unmarshal([]byte(msg), &body)).To(Succeed())
+		Expect(body).To(Equal(errorResponse{Code: services.ErrCodeUpstream, Message: "Error Forking Repo", Details: "connection reset", RequestID: "a1b2c3d4"}))
+	})
+
+	It("should keep the tokens out of the details and the logs", func() {
+		services.Config.GitHub.Token = "ghp_secret"
+		err := &url.Error{Op: "Post", URL: "https://ghp_secret@api.github.com/repos/john/repo/forks", Err: errors.New("connection reset")}
+		_, msg := failure(context.Background(), "a1b2c3d4", services.ErrCodeUpstream, "Error Forking Repo", fmt.Errorf("push with ghp_secret: %w", err))
+		Expect(msg).NotTo(ContainSubstring("ghp_secret"))
+		Expect(msg).To(ContainSubstring("https://api.github.com/repos/john/repo/forks"))
+	})
+
+	It("should map typed service errors to their status", func() {
+		gitService := gitServiceMock{}
+		gitService.CheckUserAccessRepoHandler = func(string, string) error { return nil }
+		gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
+			return nil, nil, &github.RateLimitError{Response: &http.Response{StatusCode: 403, Request: httptest.NewRequest("POST", "/repos/john/repo/forks", nil)}, Message: "API rate limit exceeded"}
+		}
+		services.GitServiceObject = gitService
+		context := contextMock{BodyParserCreateHandler: func(data *createParams) error {
+			*data = createParams{Owner: "john", Repo: "repo", Content: "{}"}
+			return nil
+		}}
+		statusCode, msg := ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(429))
+		Expect(failureError(msg).Error()).To(ContainSubstring("API rate limit exceeded"))
+		Expect(services.ClassifyError(failureError(msg), services.ErrCodeInternal)).To(Equal(services.ErrCodeRateLimited))
+	})
+})
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// adapts the fiber context to the contextInterface used by the controller
+type fiberContext struct {
+	*fiber.Ctx
+}
+
+func (c fiberContext) BodyParserCreate(data *createParams) error {
+	return c.BodyParser(data)
+}
+
+func (c fiberContext) BodyParserUpdate(data *updateParams) error {
+	return c.BodyParser(data)
+}
+
+func (c fiberContext) BodyParserOrgCreate(data *orgCreateParams) error {
+	return c.BodyParser(data)
+}
+
+// error bodies are always JSON, the successful ones keep the content type of the route
+func send(c *fiber.Ctx, statusCode int, msg string) error {
+	if statusCode >= 400 {
+		c.Type("json")
+	}
+	return c.Status(statusCode).SendString(msg)
+}
+
+func CreateSecretFileHandler(c *fiber.Ctx) error {
+	statusCode, msg := ControllerObject.CreateSecretFile(fiberContext{c})
+	return send(c, statusCode, msg)
+}
+
+func UpdateSecretFileHandler(c *fiber.Ctx) error {
+	statusCode, msg := ControllerObject.UpdateSecretFile(fiberContext{c})
+	return send(c, statusCode, msg)
+}
+
+func GitHubWebhookHandler(c *fiber.Ctx) error {
+	statusCode, msg := ControllerObject.GitHubWebhook(c)
+	return send(c, statusCode, msg)
+}
+
+func CreateOrgSecretFilesHandler(c *fiber.Ctx) error {
+	statusCode, msg := ControllerObject.CreateOrgSecretFiles(fiberContext{c})
+	c.Type("json")
+	return c.Status(statusCode).SendString(msg)
+}
+
+func OrgRolloutReportHandler(c *fiber.Ctx) error {
+	statusCode, msg := ControllerObject.OrgRolloutReport(fiberContext{c})
+	c.Type("json")
+	return c.Status(statusCode).SendString(msg)
+}
+
+func RepoHistoryHandler(c *fiber.Ctx) error {
+	statusCode, msg := ControllerObject.RepoHistory(fiberContext{c})
+	c.Type("json")
+	return c.Status(statusCode).SendString(msg)
+}
+
+func AuditReportHandler(c *fiber.Ctx) error {
+	statusCode, msg := ControllerObject.AuditReport(fiberContext{c})
+	c.Type("json")
+	return c.Status(statusCode).SendString(msg)
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"encoding/json"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// liveness probe, answers as long as the process serves requests
+func HealthzHandler(c *fiber.Ctx) error {
+	c.Type("json")
+	return c.SendString(`{"status":"ok"}`)
+}
+
+// readiness probe, 503 with the failing checks while the service cannot process requests
+func ReadyzHandler(c *fiber.Ctx) error {
+	report := Health.Ready(requestContext(c))
+	content, _ := json.Marshal(report)
+	status := fiber.StatusOK
+	if report.Status != HealthOK {
+		Logger(requestContext(c)).Warn().Msgf("Readiness checks failing: %s", content)
+		status = fiber.StatusServiceUnavailable
+	}
+	c.Type("json")
+	return c.Status(status).SendString(string(content))
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http/httptest"
+)
+
+var _ = Describe("Health", func() {
+	var app *fiber.App
+
+	BeforeEach(func() {
+		app = fiber.New()
+		app.Get("/healthz", HealthzHandler)
+		app.Get("/readyz", ReadyzHandler)
+	})
+
+	It("should answer the liveness probe", func() {
+		response, err := app.Test(httptest.NewRequest("GET", "/healthz", nil))
+		Expect(err).To(BeNil())
+		Expect(response.StatusCode).To(Equal(200))
+	})
+
+	It("should answer 503 with the breakdown of the checks when one fails", func() {
+		services.Health = healthCheckerMock{ReadyHandler: func(ctx context.Context) services.ReadinessReport {
+			return services.ReadinessReport{Status: services.HealthFailing, Checks: []services.HealthCheck{
+				{Name: "github", Status: services.HealthFailing, Message: "401 Bad credentials"},
+				{Name: "job_queue", Status: services.HealthOK},
+			}}
+		}}
+		response, err := app.Test(httptest.NewRequest("GET", "/readyz", nil))
+		Expect(err).To(BeNil())
+		Expect(response.StatusCode).To(Equal(503))
+		content, _ := ioutil.ReadAll(response.Body)
+		var report services.ReadinessReport
+		Expect(json.Unmarshal(content, &report)).To(Succeed())
+		Expect(report.Checks).To(HaveLen(2))
+		Expect(report.Checks[0].Message).To(Equal("401 Bad credentials"))
+	})
+
+	It("should answer 200 when every check passes", func() {
+		services.Health = healthCheckerMock{ReadyHandler: func(ctx context.Context) services.ReadinessReport {
+			return services.ReadinessReport{Status: services.HealthOK}
+		}}
+		response, err := app.Test(httptest.NewRequest("GET", "/readyz", nil))
+		Expect(err).To(BeNil())
+		Expect(response.StatusCode).To(Equal(200))
+	})
+})
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+)
+
+// requester of the requests sent while authentication is off
+const anonymousRequester = "anonymous"
+
+// who sent the request, recorded in the history. Only the authenticated identity is trusted,
+// a name sent by the client could be anyone's
+func requester(c contextInterface) string {
+	if identity, ok := IdentityFrom(requestContext(c)); ok {
+		return identity.Name
+	}
+	return anonymousRequester
+}
+
+func newRequestRecord(requestID string, action string, provider string, host string, owner string, repo string, requestedBy string) RequestRecord {
+	if provider == "" {
+		provider = GitHubProvider
+	}
+	return RequestRecord{
+		RequestID: requestID, Action: action, Provider: provider, Host: host,
+		Owner: owner, Repo: repo, RequestedBy: requestedBy,
+	}
+}
+
+// history failures are logged by the store and do not fail the request
+func saveRequest(record RequestRecord, statusCode int, msg string) {
+	record.StatusCode, record.Message = statusCode, msg
+	_ = History.SaveRequest(record)
+}
+
+// one decision per change of the update, every change must say why its is_secret value was chosen.
+// The decisions are marked unauthenticated when ctx has no identity
+func auditDecisions(ctx context.Context, record RequestRecord, changes SecretUpdateMap) ([]AuditDecision, error) {
+	_, authenticated := IdentityFrom(ctx)
+	filenames := make([]string, 0, len(changes))
+	for filename := range changes {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	decidedAt := time.Now().UTC()
+	var decisions []AuditDecision
+	for _, filename := range filenames {
+		for _, secret := range changes[filename] {
+			justification, _ := secret["justification"].(string)
+			if strings.TrimSpace(justification) == "" {
+				return nil, fmt.Errorf("justification is required for secret %v in %s", secret["hashed_secret"], filename)
+			}
+			isSecret, ok := secret["is_secret"].(bool)
+			if !ok {
+				return nil, fmt.Errorf("is_secret must be true or false for secret %v in %s", secret["hashed_secret"], filename)
+			}
+			decisions = append(decisions, AuditDecision{
+				RequestID: record.RequestID, Owner: record.Owner, Repo: record.Repo, Filename: filename,
+				HashedSecret: fmt.Sprintf("%v", secret["hashed_secret"]), IsSecret: isSecret,
+				DecidedBy: record.RequestedBy, Authenticated: authenticated, Justification: justification, CreatedAt: decidedAt,
+			})
+		}
+	}
+	return decisions, nil
+}
+
+func saveAuditDecisions(decisions []AuditDecision) {
+	for _, decision := range decisions {
+		_ = History.SaveAuditDecision(decision)
+	}
+}
+
+// markdown table of the decisions added to the PR body
+func auditTable(decisions []AuditDecision) string {
+	table := "\n\n| File | Secret | is_secret | Decided by | Justification |\n| --- | --- | --- | --- | --- |"
+	for _, decision := range decisions {
+		justification := strings.ReplaceAll(strings.ReplaceAll(decision.Justification, "|", "\\|"), "\n", " ")
+		decidedBy := decision.DecidedBy
+		if !decision.Authenticated {
+			decidedBy += " (unauthenticated)"
+		}
+		table += fmt.Sprintf("\n| %s | %s | %t | %s | %s |", decision.Filename, decision.HashedSecret, decision.IsSecret, decidedBy, justification)
+	}
+	return table
+}
+
+func (controller controllerImplementation) RepoHistory(c contextInterface) (int, string) {
+	owner, repo := c.Params("owner"), c.Params("repo")
+	if err := authorizeOrg(requestContext(c), owner); err != nil {
+		return failure(requestContext(c), "", ErrCodeForbidden, fmt.Sprintf("You do not have access to %s/%s", owner, repo), err)
+	}
+	history, err := History.RepoHistory(owner, repo)
+	if err != nil {
+		return failure(requestContext(c), "", ErrCodeInternal, fmt.Sprintf("Cannot read the history of %s/%s", owner, repo), err)
+	}
+	content, err := json.Marshal(history)
+	if err != nil {
+		return failure(requestContext(c), "", ErrCodeInternal, "Cannot encode the history", err)
+	}
+	return 200, string(content)
+}
+
+// audit decisions across repos for compliance reports, filtered by owner, repo, decided_by and
+// an RFC 3339 since/until range
+func (controller controllerImplementation) AuditReport(c contextInterface) (int, string) {
+	filter := AuditFilter{Owner: c.Query("owner"), Repo: c.Query("repo"), DecidedBy: c.Query("decided_by")}
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return failure(requestContext(c), "", ErrCodeInvalidInput, fmt.Sprintf("Error in data, please review input data: %s is not an RFC 3339 time", name), err)
+		}
+		*target = parsed
+	}
+	if identity, ok := IdentityFrom(requestContext(c)); ok && !identity.AllowsOrg(AllOrgs) && filter.Owner == "" {
+		return failure(requestContext(c), "", ErrCodeForbidden, "owner is required for the identities limited to some orgs", nil)
+	}
+	if err := authorizeOrg(requestContext(c), filter.Owner); err != nil {
+		return failure(requestContext(c), "", ErrCodeForbidden, fmt.Sprintf("You do not have access to %s", filter.Owner), err)
+	}
+	decisions, err := History.AuditDecisions(filter)
+	if err != nil {
+		return failure(requestContext(c), "", ErrCodeInternal, "Cannot read the audit decisions", err)
+	}
+	content, err := json.Marshal(map[string]interface{}{"audits": decisions})
+	if err != nil {
+		return failure(requestContext(c), "", ErrCodeInternal, "Cannot encode the audit decisions", err)
+	}
+	return 200, string(content)
+}
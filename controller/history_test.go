@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/go-git/go-git/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("History", func() {
+	gitService := gitServiceMock{}
+
+	BeforeEach(func() {
+		history, err := services.OpenHistory("sqlite3", ":memory:")
+		Expect(err).To(BeNil())
+		services.History = history
+		gitService.CheckUserAccessRepoHandler = func(string, string) error { return nil }
+		gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
+			return "bot", "http://github.com/bot/repo", nil
+		}
+		gitService.CheckForkedRepoHandler = func(string, string) error { return nil }
+		gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
+			return new(git.Repository), "path", nil
+		}
+		gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
+			return "branch", "main", nil
+		}
+		gitService.EditSecretFileHandler = func(string, SecretUpdateMap) error { return nil }
+		gitService.CommitAndPushHandler = func(string, string, string, string, string, *git.Repository) error {
+			return nil
+		}
+		gitService.OpenPullRequestHandler = func(string, string, string, string, string, string, string) (int, error) {
+			return 1, nil
+		}
+		services.GitServiceObject = gitService
+	})
+
+	It("should record the update request, its PR and the audit decisions", func() {
+		identity := services.Identity{Name: "alice", Method: services.AuthMethodAPIKey, Role: RoleAuditor, Orgs: []string{AllOrgs}}
+		context := contextMock{
+			LocalValues: map[string]interface{}{requestContextKey: services.WithIdentity(requestContext(contextMock{}), identity)},
+			BodyParserUpdateHandler: func(data *updateParams) error {
+				*data = updateParams{Owner: "john", Repo: "repo", Changes: SecretUpdateMap{
+					"a.py": {{"hashed_secret": "aaa", "line_number": 1, "is_secret": false, "justification": "test fixture"}},
+				}}
+				return nil
+			},
+		}
+		statusCode, _ := ControllerObject.UpdateSecretFile(context)
+		Expect(statusCode).To(Equal(200))
+
+		statusCode, msg := ControllerObject.RepoHistory(contextMock{ParamsHandler: func(key string) string {
+			return map[string]string{"owner": "john", "repo": "repo"}[key]
+		}})
+		Expect(statusCode).To(Equal(200))
+		var history services.RepoHistory
+		Expect(json.Unmarshal([]byte(msg), &history)).To(Succeed())
+		Expect(history.Requests).To(HaveLen(1))
+		Expect(history.Requests[0].Action).To(Equal("Update"))
+		Expect(history.Requests[0].Provider).To(Equal("github"))
+		Expect(history.Requests[0].RequestedBy).To(Equal("alice"))
+		Expect(history.Requests[0].StatusCode).To(Equal(200))
+		Expect(history.Requests[0].PullHead).To(Equal("bot:branch"))
+		Expect(history.Requests[0].PullNumber).To(Equal(1))
+		Expect(history.Requests[0].PullURL).To(Equal("https://github.com/john/repo/pull/1"))
+		Expect(history.AuditDecisions).To(HaveLen(1))
+		Expect(history.AuditDecisions[0].HashedSecret).To(Equal("aaa"))
+		Expect(history.AuditDecisions[0].DecidedBy).To(Equal("alice"))
+		Expect(history.AuditDecisions[0].Authenticated).To(BeTrue())
+		Expect(history.AuditDecisions[0].Justification).To(Equal("test fixture"))
+	})
+
+	It("should reject changes without a justification", func() {
+		context := contextMock{BodyParserUpdateHandler: func(data *updateParams) error {
+			*data = updateParams{Owner: "john", Repo: "repo", Changes: SecretUpdateMap{
+				"a.py": {{"hashed_secret": "aaa", "line_number": 1, "is_secret": false, "justification": " "}},
+			}}
+			return nil
+		}}
+		statusCode, msg := ControllerObject.UpdateSecretFile(context)
+		Expect(statusCode).To(Equal(400))
+		Expect(msg).To(ContainSubstring("justification is required for secret aaa in a.py"))
+	})
+
+	It("should write the sidecar audit file when asked", func() {
+		var written []services.AuditDecision
+		gitService.WriteAuditFileHandler = func(_ string, decisions []services.AuditDecision) error {
+			written = decisions
+			return nil
+		}
+		services.GitServiceObject = gitService
+		var description string
+		gitService.OpenPullRequestHandler = func(_ string, _ string, _ string, _ string, _ string, _ string, body string) (int, error) {
+			description = body
+			return 1, nil
+		}
+		services.GitServiceObject = gitService
+		// a name sent by the client is not an identity
+		context := contextMock{Headers: map[string]string{"X-Requested-By": "alice"}, BodyParserUpdateHandler: func(data *updateParams) error {
+			*data = updateParams{Owner: "john", Repo: "repo", AuditFile: true, AuditInPR: true, Changes: SecretUpdateMap{
+				"a.py": {{"hashed_secret": "aaa", "line_number": 1, "is_secret": true, "justification": "production key"}},
+			}}
+			return nil
+		}}
+		statusCode, _ := ControllerObject.UpdateSecretFile(context)
+		Expect(statusCode).To(Equal(200))
+		Expect(written).To(HaveLen(1))
+		Expect(written[0].IsSecret).To(BeTrue())
+		Expect(written[0].DecidedBy).To(Equal("anonymous"))
+		Expect(written[0].Authenticated).To(BeFalse())
+		Expect(description).To(ContainSubstring("| anonymous (unauthenticated) |"))
+	})
+
+	It("should report the audit decisions for compliance", func() {
+		Expect(services.History.SaveAuditDecision(services.AuditDecision{Owner: "john", Repo: "repo", HashedSecret: "aaa",
+			DecidedBy: "alice", Justification: "fixture", CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)})).To(Succeed())
+		Expect(services.History.SaveAuditDecision(services.AuditDecision{Owner: "john", Repo: "other", HashedSecret: "bbb",
+			DecidedBy: "bob", Justification: "fixture", CreatedAt: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)})).To(Succeed())
+
+		statusCode, msg := ControllerObject.AuditReport(contextMock{Queries: map[string]string{"owner": "john", "since": "2021-02-01T00:00:00Z"}})
+		Expect(statusCode).To(Equal(200))
+		var report struct {
+			Audits []services.AuditDecision `json:"audits"`
+		}
+		Expect(json.Unmarshal([]byte(msg), &report)).To(Succeed())
+		Expect(report.Audits).To(HaveLen(1))
+		Expect(report.Audits[0].DecidedBy).To(Equal("bob"))
+
+		statusCode, _ = ControllerObject.AuditReport(contextMock{Queries: map[string]string{"until": "yesterday"}})
+		Expect(statusCode).To(Equal(400))
+	})
+
+	It("should record failed requests as anonymous when no requester is sent", func() {
+		gitService.CheckUserAccessRepoHandler = func(string, string) error { return git.ErrRepositoryNotExists }
+		services.GitServiceObject = gitService
+		context := contextMock{BodyParserCreateHandler: func(data *createParams) error {
+			*data = createParams{Owner: "john", Repo: "repo"}
+			return nil
+		}}
+		statusCode, _ := ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(403))
+		history, err := services.History.RepoHistory("john", "repo")
+		Expect(err).To(BeNil())
+		Expect(history.Requests).To(HaveLen(1))
+		Expect(history.Requests[0].RequestedBy).To(Equal("anonymous"))
+		Expect(history.Requests[0].StatusCode).To(Equal(403))
+	})
+})
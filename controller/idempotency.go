@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// caller the idempotency keys belong to, a key sent by someone else must not replay their result
+func idempotencyScope(c *fiber.Ctx) string {
+	if identity, ok := IdentityFrom(requestContext(c)); ok {
+		return identity.Method + ":" + identity.Name
+	}
+	return anonymousRequester
+}
+
+// org the request acts on, from the route or the owner of the body
+func requestOrg(c *fiber.Ctx) string {
+	if org := c.Params("org"); org != "" {
+		return org
+	}
+	var body struct {
+		Owner string `json:"owner"`
+	}
+	_ = json.Unmarshal(c.Body(), &body)
+	return body.Owner
+}
+
+// replays the outcome of the first request sent with the same Idempotency-Key header instead of
+// forking and pushing again, server errors are not kept so those requests can be retried.
+// The org is authorized first so a replay never shows the result to a caller not allowed to see it
+func IdempotencyMiddleware(c *fiber.Ctx) error {
+	// fiber reuses the header memory once the handler returns, the stored key needs its own copy
+	key := utils.CopyString(c.Get(IdempotencyKeyHeader))
+	if key == "" {
+		return c.Next()
+	}
+	ctx := requestContext(c)
+	if err := authorizeOrg(ctx, requestOrg(c)); err != nil {
+		statusCode, msg := failure(ctx, "", ErrCodeForbidden, "Access denied", err)
+		c.Type("json")
+		return c.Status(statusCode).SendString(msg)
+	}
+	scope := idempotencyScope(c)
+	fingerprint := RequestFingerprint(c.Method(), c.Path(), c.Body())
+	record, exists, err := IdempotencyKeys.Begin(scope, key, fingerprint)
+	if err != nil {
+		statusCode, msg := failure(ctx, "", ErrCodeInternal, fmt.Sprintf("Idempotency key %s rejected", key), err)
+		c.Type("json")
+		return c.Status(statusCode).SendString(msg)
+	}
+	if exists {
+		c.Set("Idempotent-Replayed", "true")
+		if !record.Done {
+			Logger(ctx).Info().Msgf("Request with idempotency key %s is still running", key)
+			c.Type("json")
+			return c.Status(ErrCodeInProgress.HTTPStatus()).SendString(errorBody(RequestID(ctx), ErrCodeInProgress,
+				"A request with this Idempotency-Key is in progress", nil))
+		}
+		Logger(ctx).Info().Msgf("Replaying the result of idempotency key %s", key)
+		if record.ContentType != "" {
+			c.Set(fiber.HeaderContentType, record.ContentType)
+		}
+		return c.Status(record.StatusCode).SendString(record.Body)
+	}
+
+	if err := c.Next(); err != nil {
+		forgetIdempotencyKey(c, scope, key)
+		return err
+	}
+	statusCode := c.Response().StatusCode()
+	if statusCode >= 500 {
+		forgetIdempotencyKey(c, scope, key)
+		return nil
+	}
+	contentType := string(c.Response().Header.ContentType())
+	if err := IdempotencyKeys.Complete(scope, key, statusCode, contentType, string(c.Response().Body())); err != nil {
+		Logger(ctx).Error().Msgf("Error saving the result of idempotency key %s: %v", key, err)
+	}
+	return nil
+}
+
+func forgetIdempotencyKey(c *fiber.Ctx, scope string, key string) {
+	if err := IdempotencyKeys.Forget(scope, key); err != nil {
+		Logger(requestContext(c)).Error().Msgf("Error forgetting idempotency key %s: %v", key, err)
+	}
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	"github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+var _ = Describe("Idempotency", func() {
+	var app *fiber.App
+	var calls int
+	var statusCode int
+	var started, release chan struct{}
+
+	var identity *services.Identity
+
+	var contentType string
+
+	send := func(key string, body string) (int, string, string) {
+		request := httptest.NewRequest("POST", "/api/detectsecrets/create", strings.NewReader(body))
+		if key != "" {
+			request.Header.Set(IdempotencyKeyHeader, key)
+		}
+		response, err := app.Test(request, -1)
+		Expect(err).To(BeNil())
+		content, _ := ioutil.ReadAll(response.Body)
+		contentType = response.Header.Get(fiber.HeaderContentType)
+		return response.StatusCode, string(content), response.Header.Get("Idempotent-Replayed")
+	}
+
+	BeforeEach(func() {
+		calls, statusCode, started, release, identity = 0, 200, nil, nil, nil
+		history, err := services.OpenHistory("sqlite3", ":memory:")
+		Expect(err).To(BeNil())
+		services.History = history
+		services.IdempotencyKeys = services.NewIdempotencyStore(time.Hour, time.Hour)
+		app = fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			if identity != nil {
+				c.Locals(requestContextKey, services.WithIdentity(context.Background(), *identity))
+			}
+			return c.Next()
+		})
+		app.Post("/api/detectsecrets/create", IdempotencyMiddleware, func(c *fiber.Ctx) error {
+			calls++
+			if release != nil {
+				close(started)
+				<-release
+			}
+			c.Type("json")
+			return c.Status(statusCode).SendString(`"PR was Created !"`)
+		})
+	})
+
+	It("should run a repeated request once and replay its result", func() {
+		code, body, replayed := send("key-1", `{"repo": "repo"}`)
+		Expect(code).To(Equal(200))
+		Expect(replayed).To(Equal(""))
+		code, body, replayed = send("key-1", `{"repo": "repo"}`)
+		Expect(code).To(Equal(200))
+		Expect(body).To(Equal(`"PR was Created !"`))
+		Expect(contentType).To(HavePrefix("application/json"))
+		Expect(replayed).To(Equal("true"))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("should not replay the result of a key sent by another caller", func() {
+		identity = &services.Identity{Name: "ci", Method: services.AuthMethodAPIKey, Role: utils.RoleAdmin, Orgs: []string{utils.AllOrgs}}
+		send("key-1", `{"repo": "repo"}`)
+		identity = &services.Identity{Name: "alice", Method: services.AuthMethodOIDC, Role: utils.RoleAdmin, Orgs: []string{utils.AllOrgs}}
+		_, _, replayed := send("key-1", `{"repo": "repo"}`)
+		Expect(replayed).To(Equal(""))
+		Expect(calls).To(Equal(2))
+	})
+
+	It("should check the org of the caller before replaying", func() {
+		send("key-1", `{"repo": "repo", "owner": "john"}`)
+		identity = &services.Identity{Name: "ci", Method: services.AuthMethodAPIKey, Role: utils.RoleAdmin, Orgs: []string{"acme"}}
+		send("key-1", `{"repo": "repo", "owner": "john"}`)
+		code, body, replayed := send("key-1", `{"repo": "repo", "owner": "john"}`)
+		Expect(code).To(Equal(403))
+		Expect(body).To(ContainSubstring(`"code":"forbidden"`))
+		Expect(replayed).To(Equal(""))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("should reject a key reused with a different body", func() {
+		send("key-1", `{"repo": "repo"}`)
+		code, body, _ := send("key-1", `{"repo": "other"}`)
+		Expect(code).To(Equal(409))
+		Expect(body).To(ContainSubstring(`"code":"conflict"`))
+		Expect(body).To(ContainSubstring(services.ErrIdempotencyKeyReused.Error()))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("should report the request still in progress", func() {
+		started, release = make(chan struct{}), make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			send("key-1", `{}`)
+			close(done)
+		}()
+		<-started
+		code, body, replayed := send("key-1", `{}`)
+		Expect(code).To(Equal(202))
+		Expect(body).To(ContainSubstring(`"code":"in_progress"`))
+		Expect(contentType).To(HavePrefix("application/json"))
+		Expect(replayed).To(Equal("true"))
+		close(release)
+		<-done
+		Expect(calls).To(Equal(1))
+	})
+
+	It("should let requests without a key or failing with a server error run again", func() {
+		send("", `{}`)
+		send("", `{}`)
+		statusCode = 503
+		send("key-1", `{}`)
+		send("key-1", `{}`)
+		Expect(calls).To(Equal(4))
+	})
+})
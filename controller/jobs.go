@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+)
+
+const (
+	jobKindRefreshBaseline  = "refresh_baseline"
+	jobKindCheckPullRequest = "check_pull_request"
+	jobKindAuditComment     = "audit_comment"
+	jobKindOrgRollout       = "org_rollout"
+)
+
+// arguments of the jobs working on a single repo
+type repoJobParams struct {
+	Host     string           `json:"host,omitempty"`
+	Owner    string           `json:"owner"`
+	Repo     string           `json:"repo"`
+	Number   int              `json:"number,omitempty"`
+	HeadSHA  string           `json:"head_sha,omitempty"`
+	BaseSHA  string           `json:"base_sha,omitempty"`
+	User     string           `json:"user,omitempty"`
+	Commands []secretsCommand `json:"commands,omitempty"`
+	// parse error of the commands, the job replies with it instead of auditing
+	Error string `json:"error,omitempty"`
+}
+
+type rolloutJobParams struct {
+	ID          string   `json:"id"`
+	Host        string   `json:"host,omitempty"`
+	Org         string   `json:"org"`
+	Repos       []string `json:"repos"`
+	Concurrency int      `json:"concurrency"`
+}
+
+// job of kind with its params saved, so a job left unfinished at shutdown runs the same way
+// when it is resumed
+func newJob(kind string, params interface{}) Job {
+	content, _ := json.Marshal(params)
+	job := Job{Kind: kind, Params: content}
+	job.Run, _ = jobRunner(kind, content)
+	return job
+}
+
+// repos of the rollout still pending or interrupted while running, a resumed rollout leaves
+// the others alone so it does not open their PRs again
+func unfinishedRepos(report RolloutReport, repos []string) []string {
+	if len(report.Repos) == 0 {
+		return repos
+	}
+	var unfinished []string
+	for _, repo := range report.Repos {
+		if repo.Status == RolloutPending || repo.Status == RolloutRunning {
+			unfinished = append(unfinished, repo.Name)
+		}
+	}
+	return unfinished
+}
+
+func jobRunner(kind string, raw json.RawMessage) (func(ctx context.Context) error, error) {
+	if kind == jobKindOrgRollout {
+		var params rolloutJobParams
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error {
+			report, ok := OrgRollouts.Report(params.ID)
+			if !ok {
+				OrgRollouts.Start(params.ID, params.Org, params.Repos)
+				report, _ = OrgRollouts.Report(params.ID)
+			}
+			rollout(ctx, params.ID, params.Host, params.Org, unfinishedRepos(report, params.Repos), params.Concurrency)
+			return nil
+		}, nil
+	}
+	var params repoJobParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case jobKindRefreshBaseline:
+		return func(ctx context.Context) error {
+			return refreshBaseline(ctx, params.Host, params.Owner, params.Repo)
+		}, nil
+	case jobKindCheckPullRequest:
+		return func(ctx context.Context) error {
+			return checkPullRequest(ctx, params.Host, params.Owner, params.Repo, params.Number, params.HeadSHA, params.BaseSHA)
+		}, nil
+	case jobKindAuditComment:
+		return func(ctx context.Context) error {
+			if params.Error != "" {
+				return replyCommandError(ctx, params.Host, params.Owner, params.Repo, params.Number, params.User, params.Error)
+			}
+			return auditFromComment(ctx, params.Host, params.Owner, params.Repo, params.Number, params.User, params.Commands)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown job kind %q", kind)
+}
+
+// enqueues again the jobs saved at the last shutdown, returns the ones the queue refused so
+// they can be saved for the next start
+func ResumeJobs(jobs []Job) []Job {
+	var leftover []Job
+	for _, job := range jobs {
+		run, err := jobRunner(job.Kind, job.Params)
+		if err != nil {
+			ZeroLogger.Error().Msgf("Cannot resume job %s: %v", job.ID, err)
+			continue
+		}
+		job.Run = run
+		if err := JobQueue.Enqueue(job); err != nil {
+			ZeroLogger.Error().Msgf("Cannot resume job %s, it is kept for the next start: %v", job.ID, err)
+			leftover = append(leftover, job)
+			continue
+		}
+		ZeroLogger.Info().Msgf("Resumed job %s: %s", job.ID, job.Name)
+	}
+	return leftover
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Jobs", func() {
+	var enqueued []services.Job
+	var previousQueue = services.JobQueue
+
+	BeforeEach(func() {
+		enqueued = nil
+		previousQueue = services.JobQueue
+		services.JobQueue = jobQueueMock{
+			EnqueueHandler: func(job services.Job) error {
+				enqueued = append(enqueued, job)
+				return nil
+			},
+		}
+	})
+
+	AfterEach(func() {
+		services.JobQueue = previousQueue
+	})
+
+	It("saves the kind and params of a job", func() {
+		job := newJob(jobKindCheckPullRequest, repoJobParams{Owner: "john", Repo: "repo", Number: 7, HeadSHA: "abc"})
+		Expect(job.Kind).To(Equal(jobKindCheckPullRequest))
+		Expect(string(job.Params)).To(MatchJSON(`{"owner": "john", "repo": "repo", "number": 7, "head_sha": "abc"}`))
+		Expect(job.Run).NotTo(BeNil())
+	})
+
+	It("resumes the saved jobs with their runner rebuilt", func() {
+		ResumeJobs([]services.Job{
+			{ID: "1", Kind: jobKindRefreshBaseline, Params: json.RawMessage(`{"owner": "john", "repo": "repo"}`), Interrupted: true},
+			{ID: "2", Kind: jobKindOrgRollout, Params: json.RawMessage(`{"id": "r1", "org": "acme", "repos": ["a"], "concurrency": 1}`)},
+		})
+		Expect(enqueued).To(HaveLen(2))
+		Expect(enqueued[0].ID).To(Equal("1"))
+		Expect(enqueued[0].Run).NotTo(BeNil())
+		Expect(enqueued[1].ID).To(Equal("2"))
+		Expect(enqueued[1].Run).NotTo(BeNil())
+	})
+
+	It("returns the saved jobs the queue has no room for", func() {
+		services.JobQueue = jobQueueMock{EnqueueHandler: func(job services.Job) error {
+			if job.ID == "2" {
+				return services.ErrQueueFull
+			}
+			enqueued = append(enqueued, job)
+			return nil
+		}}
+		leftover := ResumeJobs([]services.Job{
+			{ID: "1", Kind: jobKindRefreshBaseline, Params: json.RawMessage(`{"owner": "john", "repo": "repo"}`)},
+			{ID: "2", Kind: jobKindRefreshBaseline, Params: json.RawMessage(`{"owner": "john", "repo": "other"}`)},
+		})
+		Expect(enqueued).To(HaveLen(1))
+		Expect(leftover).To(HaveLen(1))
+		Expect(leftover[0].ID).To(Equal("2"))
+		Expect(string(leftover[0].Params)).To(MatchJSON(`{"owner": "john", "repo": "other"}`))
+	})
+
+	It("skips the saved jobs it cannot rebuild", func() {
+		ResumeJobs([]services.Job{
+			{ID: "1", Kind: "unknown", Params: json.RawMessage(`{}`)},
+			{ID: "2", Kind: jobKindAuditComment, Params: json.RawMessage(`not json`)},
+		})
+		Expect(enqueued).To(BeEmpty())
+	})
+})
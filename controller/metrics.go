@@ -0,0 +1,35 @@
+package controller
+
+import (
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"strconv"
+	"time"
+)
+
+var metricsHandler = fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
+
+// counts every request and its latency by route, method and status, the route is the path
+// template so the owner and repo of a request do not become labels
+func MetricsMiddleware(c *fiber.Ctx) error {
+	started := time.Now()
+	err := c.Next()
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		if fiberError, ok := err.(*fiber.Error); ok {
+			status = fiberError.Code
+		}
+	}
+	labels := []string{c.Route().Path, c.Method(), strconv.Itoa(status)}
+	HTTPRequests.WithLabelValues(labels...).Inc()
+	HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(started).Seconds())
+	return err
+}
+
+func MetricsHandler(c *fiber.Ctx) error {
+	metricsHandler(c.Context())
+	return nil
+}
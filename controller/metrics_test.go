@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http/httptest"
+)
+
+var _ = Describe("Metrics", func() {
+	It("should expose the requests of each route template on /metrics", func() {
+		services.JobQueue = jobQueueMock{DepthHandler: func() int { return 3 }}
+		app := fiber.New()
+		app.Use(MetricsMiddleware)
+		app.Get("/metrics", MetricsHandler)
+		app.Get("/api/things/:id", func(c *fiber.Ctx) error {
+			return c.Status(404).SendString("missing")
+		})
+		_, err := app.Test(httptest.NewRequest("GET", "/api/things/42", nil))
+		Expect(err).To(BeNil())
+
+		response, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+		Expect(err).To(BeNil())
+		Expect(response.StatusCode).To(Equal(200))
+		content, _ := ioutil.ReadAll(response.Body)
+		Expect(string(content)).To(ContainSubstring(`secret_scanner_http_requests_total{method="GET",route="/api/things/:id",status="404"} 1`))
+		Expect(string(content)).To(ContainSubstring(`secret_scanner_http_request_duration_seconds_count{method="GET",route="/api/things/:id",status="404"} 1`))
+		Expect(string(content)).To(ContainSubstring("secret_scanner_job_queue_depth 3"))
+	})
+})
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"context"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
 	. "github.com/eliezer-borde-globant/EBGoProject/utils"
 	"github.com/go-git/go-git/v5"
 	"github.com/gofiber/fiber/v2"
@@ -9,19 +11,35 @@ import (
 
 type gitServiceMock struct {
 	GetGitHubClientHandler     func() *github.Client
-	CheckUserAccessRepoHandler func(string, string) (*github.Repository, error)
+	CheckUserAccessRepoHandler func(string, string) error
 	ForkRepoHandler            func(string, string) (interface{}, interface{}, error)
 	CloneRepoHandler           func(string, string) (*git.Repository, string, error)
-	CreateBranchRepoHandler    func(*git.Repository, string, string) (string, string, error)
+	CreateBranchRepoHandler    func(*git.Repository, string, string, string, string) (string, string, error)
 	CreateSecretFileHandler    func(string, string) error
-	CreateCommitAndPrHandler   func(string, string, string, string, string, string, string, *git.Repository) error
+	CommitAndPushHandler       func(string, string, string, string, string, *git.Repository) error
+	OpenPullRequestHandler     func(string, string, string, string, string, string, string) (int, error)
 	EditSecretFileHandler      func(string, SecretUpdateMap) error
-	CheckForkedRepoHandler	   func(string) error
+	WriteAuditFileHandler      func(string, []services.AuditDecision) error
+	CheckForkedRepoHandler	   func(string, string) error
+	PullRequestFilesHandler    func(string, string, int) ([]string, error)
+	CheckoutPullRequestHandler func(*git.Repository, int, string) error
+	CheckoutBranchHandler      func(*git.Repository, string) error
+	OpenBaselinePullRequestHandler func(string, string) (services.BaselinePullRequest, bool, error)
+	FileContentHandler         func(string, string, string, string) ([]byte, error)
+	CreateCheckRunHandler      func(string, string, string, SecretUpdateMap) error
+	SyncSecretReviewCommentsHandler func(string, string, int, string, SecretUpdateMap) error
+	CreateIssueCommentHandler  func(string, string, int, string) error
+	ListOrgReposHandler        func(string, services.RepoFilter) ([]string, error)
 }
 
 type contextMock struct {
 	BodyParserCreateHandler func(*createParams) error
 	BodyParserUpdateHandler func(params *updateParams) error
+	BodyParserOrgCreateHandler func(params *orgCreateParams) error
+	ParamsHandler     func(string) string
+	Headers           map[string]string
+	Queries           map[string]string
+	LocalValues       map[string]interface{}
 	StatusHandler     func(int) *fiber.Ctx
 }
 
@@ -33,6 +51,41 @@ func (mock contextMock) BodyParserUpdate(data *updateParams) error {
 	return mock.BodyParserUpdateHandler(data)
 }
 
+func (mock contextMock) BodyParserOrgCreate(data *orgCreateParams) error {
+	return mock.BodyParserOrgCreateHandler(data)
+}
+
+func (mock contextMock) Params(key string, defaultValue ...string) string {
+	return mock.ParamsHandler(key)
+}
+
+func (mock contextMock) Get(key string, defaultValue ...string) string {
+	if value, ok := mock.Headers[key]; ok {
+		return value
+	}
+	if len(defaultValue) > 0 {
+		return defaultValue[0]
+	}
+	return ""
+}
+
+func (mock contextMock) Query(key string, defaultValue ...string) string {
+	if value, ok := mock.Queries[key]; ok {
+		return value
+	}
+	if len(defaultValue) > 0 {
+		return defaultValue[0]
+	}
+	return ""
+}
+
+func (mock contextMock) Locals(key string, value ...interface{}) interface{} {
+	if len(value) > 0 && mock.LocalValues != nil {
+		mock.LocalValues[key] = value[0]
+	}
+	return mock.LocalValues[key]
+}
+
 func (mock contextMock) Status(code int) *fiber.Ctx {
 	return mock.StatusHandler(code)
 }
@@ -41,7 +94,7 @@ func (mock gitServiceMock) GetGitHubClient() *github.Client {
 	return mock.GetGitHubClientHandler()
 }
 
-func (mock gitServiceMock) CheckUserAccessRepo(owner string, repo string) (*github.Repository, error) {
+func (mock gitServiceMock) CheckUserAccessRepo(owner string, repo string) error {
 	return mock.CheckUserAccessRepoHandler(owner, repo)
 }
 
@@ -53,22 +106,130 @@ func (mock gitServiceMock) CloneRepo(owner string, repo string) (*git.Repository
 	return mock.CloneRepoHandler(owner, repo)
 }
 
-func (mock gitServiceMock) CreateBranchRepo(repoGit *git.Repository, repoName string, action string) (string, string, error) {
-	return mock.CreateBranchRepoHandler(repoGit, repoName, action)
+func (mock gitServiceMock) CreateBranchRepo(repoGit *git.Repository, owner string, repoName string, action string, requestID string) (string, string, error) {
+	return mock.CreateBranchRepoHandler(repoGit, owner, repoName, action, requestID)
 }
 
 func (mock gitServiceMock) CreateSecretFile(path string, secretFile string) error {
 	return mock.CreateSecretFileHandler(path, secretFile)
 }
 
-func (mock gitServiceMock) CreateCommitAndPr(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string, repoGit *git.Repository) error {
-	return mock.CreateCommitAndPrHandler(owner, originalOwner, repo, currentBranch, headBranch, action, description, repoGit)
+func (mock gitServiceMock) CommitAndPush(owner string, originalOwner string, repo string, action string, requestID string, repoGit *git.Repository) error {
+	return mock.CommitAndPushHandler(owner, originalOwner, repo, action, requestID, repoGit)
+}
+
+func (mock gitServiceMock) OpenPullRequest(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string) (int, error) {
+	return mock.OpenPullRequestHandler(owner, originalOwner, repo, currentBranch, headBranch, action, description)
 }
 
 func (mock gitServiceMock) EditSecretFile(path string, secretsChanges SecretUpdateMap) error {
 	return mock.EditSecretFileHandler(path, secretsChanges)
 }
 
-func (mock gitServiceMock) CheckForkedRepo(getURL string) error {
-	return mock.CheckForkedRepoHandler(getURL)
-}
\ No newline at end of file
+func (mock gitServiceMock) CheckForkedRepo(owner string, repo string) error {
+	return mock.CheckForkedRepoHandler(owner, repo)
+}
+func (mock gitServiceMock) PullRequestFiles(owner string, repo string, number int) ([]string, error) {
+	return mock.PullRequestFilesHandler(owner, repo, number)
+}
+
+func (mock gitServiceMock) CheckoutPullRequest(repoGit *git.Repository, number int, headSHA string) error {
+	return mock.CheckoutPullRequestHandler(repoGit, number, headSHA)
+}
+
+func (mock gitServiceMock) CheckoutBranch(repoGit *git.Repository, branch string) error {
+	return mock.CheckoutBranchHandler(repoGit, branch)
+}
+
+func (mock gitServiceMock) OpenBaselinePullRequest(owner string, repo string) (services.BaselinePullRequest, bool, error) {
+	return mock.OpenBaselinePullRequestHandler(owner, repo)
+}
+
+func (mock gitServiceMock) FileContent(owner string, repo string, path string, ref string) ([]byte, error) {
+	return mock.FileContentHandler(owner, repo, path, ref)
+}
+
+func (mock gitServiceMock) CreateCheckRun(owner string, repo string, headSHA string, unaudited SecretUpdateMap) error {
+	return mock.CreateCheckRunHandler(owner, repo, headSHA, unaudited)
+}
+
+func (mock gitServiceMock) SyncSecretReviewComments(owner string, repo string, number int, headSHA string, unaudited SecretUpdateMap) error {
+	return mock.SyncSecretReviewCommentsHandler(owner, repo, number, headSHA, unaudited)
+}
+
+func (mock gitServiceMock) CreateIssueComment(owner string, repo string, number int, body string) error {
+	return mock.CreateIssueCommentHandler(owner, repo, number, body)
+}
+
+func (mock gitServiceMock) ListOrgRepos(org string, filter services.RepoFilter) ([]string, error) {
+	return mock.ListOrgReposHandler(org, filter)
+}
+
+func (mock gitServiceMock) WriteAuditFile(path string, decisions []services.AuditDecision) error {
+	return mock.WriteAuditFileHandler(path, decisions)
+}
+
+type webhookContextMock struct {
+	BodyHandler func() []byte
+	Headers     map[string]string
+	LocalValues map[string]interface{}
+}
+
+func (mock webhookContextMock) Body() []byte {
+	return mock.BodyHandler()
+}
+
+func (mock webhookContextMock) Get(key string, defaultValue ...string) string {
+	return mock.Headers[key]
+}
+
+func (mock webhookContextMock) Locals(key string, value ...interface{}) interface{} {
+	if len(value) > 0 && mock.LocalValues != nil {
+		mock.LocalValues[key] = value[0]
+	}
+	return mock.LocalValues[key]
+}
+
+type jobQueueMock struct {
+	EnqueueHandler func(services.Job) error
+	DepthHandler    func() int
+	CapacityHandler func() int
+	ClosedHandler   func() bool
+	ShutdownHandler func(context.Context) ([]services.Job, error)
+}
+
+func (mock jobQueueMock) Enqueue(job services.Job) error {
+	return mock.EnqueueHandler(job)
+}
+
+func (mock jobQueueMock) Depth() int {
+	return mock.DepthHandler()
+}
+
+func (mock jobQueueMock) Capacity() int {
+	return mock.CapacityHandler()
+}
+
+func (mock jobQueueMock) Closed() bool {
+	return mock.ClosedHandler()
+}
+
+func (mock jobQueueMock) Shutdown(ctx context.Context) ([]services.Job, error) {
+	return mock.ShutdownHandler(ctx)
+}
+
+type scannerMock struct {
+	ScanHandler func(string, ...string) ([]byte, error)
+}
+
+func (mock scannerMock) Scan(path string, files ...string) ([]byte, error) {
+	return mock.ScanHandler(path, files...)
+}
+
+type healthCheckerMock struct {
+	ReadyHandler func(context.Context) services.ReadinessReport
+}
+
+func (mock healthCheckerMock) Ready(ctx context.Context) services.ReadinessReport {
+	return mock.ReadyHandler(ctx)
+}
@@ -0,0 +1,498 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/gofiber/fiber/v2"
+	"sort"
+	"strings"
+)
+
+// OpenAPI 3 description of the API, the request body schemas are also used to validate requests
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Secret Scanner API",
+    "description": "Creates and audits detect-secrets baseline files through pull requests.",
+    "version": "1.0.0"
+  },
+  "paths": {
+    "/api/detectsecrets/create": {
+      "post": {
+        "summary": "Open a PR adding the secrets file",
+        "description": "Requires the admin role and access to the org",
+        "security": [{"ApiKey": []}, {"OIDC": []}],
+        "parameters": [{"$ref": "#/components/parameters/IdempotencyKey"}, {"$ref": "#/components/parameters/RequestID"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CreateRequest"}}}},
+        "responses": {
+          "200": {"description": "PR created or updated, or the dry run result when dry_run is set"},
+          "202": {"description": "A request with the same Idempotency-Key is still running", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"description": "Idempotency-Key reused with a different body", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "415": {"description": "The body is not application/json", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "429": {"$ref": "#/components/responses/Error"},
+          "502": {"$ref": "#/components/responses/Error"},
+          "504": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/detectsecrets/update": {
+      "post": {
+        "summary": "Open a PR applying audit decisions to the secrets file",
+        "description": "Requires the auditor role or above and access to the org",
+        "security": [{"ApiKey": []}, {"OIDC": []}],
+        "parameters": [{"$ref": "#/components/parameters/IdempotencyKey"}, {"$ref": "#/components/parameters/RequestID"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/UpdateRequest"}}}},
+        "responses": {
+          "200": {"description": "PR created or updated, or the dry run result when dry_run is set"},
+          "202": {"description": "A request with the same Idempotency-Key is still running", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"description": "Idempotency-Key reused with a different body", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "415": {"description": "The body is not application/json", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "429": {"$ref": "#/components/responses/Error"},
+          "502": {"$ref": "#/components/responses/Error"},
+          "504": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/detectsecrets/orgs/{org}/create": {
+      "post": {
+        "summary": "Create the secrets file in every matching repo of an organization",
+        "description": "Requires the admin role and access to the org",
+        "security": [{"ApiKey": []}, {"OIDC": []}],
+        "parameters": [{"$ref": "#/components/parameters/Org"}, {"$ref": "#/components/parameters/IdempotencyKey"}, {"$ref": "#/components/parameters/RequestID"}],
+        "requestBody": {"required": false, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/OrgCreateRequest"}}}},
+        "responses": {
+          "202": {"description": "Rollout started", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RolloutReport"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "415": {"description": "The body is not application/json", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "502": {"$ref": "#/components/responses/Error"},
+          "503": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/detectsecrets/orgs/{org}/create/{id}": {
+      "get": {
+        "summary": "Progress of an organization rollout",
+        "description": "Requires the viewer role or above and access to the org",
+        "security": [{"ApiKey": []}, {"OIDC": []}],
+        "parameters": [
+          {"$ref": "#/components/parameters/Org"},
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "Rollout progress", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RolloutReport"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/detectsecrets/{owner}/{repo}/history": {
+      "get": {
+        "summary": "Requests and audit decisions recorded for a repo",
+        "description": "Requires the viewer role or above and access to the org",
+        "security": [{"ApiKey": []}, {"OIDC": []}],
+        "parameters": [
+          {"name": "owner", "in": "path", "required": true, "schema": {"type": "string"}},
+          {"name": "repo", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "Repo history"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/detectsecrets/audits": {
+      "get": {
+        "summary": "Audit decisions for compliance reporting",
+        "description": "Requires the auditor role or above and access to the org",
+        "security": [{"ApiKey": []}, {"OIDC": []}],
+        "parameters": [
+          {"name": "owner", "in": "query", "schema": {"type": "string"}},
+          {"name": "repo", "in": "query", "schema": {"type": "string"}},
+          {"name": "decided_by", "in": "query", "schema": {"type": "string"}},
+          {"name": "since", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "until", "in": "query", "schema": {"type": "string", "format": "date-time"}}
+        ],
+        "responses": {
+          "200": {"description": "Audit decisions"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/webhooks/github": {
+      "post": {
+        "summary": "GitHub webhook receiver for push, pull_request and issue_comment events",
+        "parameters": [
+          {"name": "X-GitHub-Event", "in": "header", "required": true, "schema": {"type": "string"}},
+          {"name": "X-GitHub-Delivery", "in": "header", "required": true, "schema": {"type": "string"}},
+          {"name": "X-Hub-Signature-256", "in": "header", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "Ping answered or delivery already processed"},
+          "202": {"description": "Job enqueued or event ignored"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "503": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/openapi.json": {
+      "get": {
+        "summary": "This document",
+        "description": "Requires the viewer role or above",
+        "security": [{"ApiKey": []}, {"OIDC": []}],
+        "responses": {
+          "200": {"description": "OpenAPI document"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/metrics": {
+      "get": {
+        "summary": "Prometheus metrics",
+        "description": "Requires the viewer role or above",
+        "security": [{"ApiKey": []}, {"OIDC": []}],
+        "responses": {
+          "200": {"description": "Metrics in the Prometheus text format"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/healthz": {
+      "get": {"summary": "Liveness probe", "responses": {"200": {"description": "The process is alive"}}}
+    },
+    "/readyz": {
+      "get": {
+        "summary": "Readiness probe checking the GitHub tokens, the work directory, the free disk and the job queue",
+        "responses": {
+          "200": {"description": "Every check passed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ReadinessReport"}}}},
+          "503": {"description": "At least one check failed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ReadinessReport"}}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "ApiKey": {"type": "apiKey", "in": "header", "name": "X-API-Key"},
+      "OIDC": {"type": "http", "scheme": "bearer", "bearerFormat": "JWT", "description": "RS256 token of the configured OIDC issuer"}
+    },
+    "parameters": {
+      "Org": {"name": "org", "in": "path", "required": true, "schema": {"type": "string"}},
+      "IdempotencyKey": {"name": "Idempotency-Key", "in": "header", "description": "Retries sent by the same caller with this key get the result of the first request, or 202 with the in_progress code while it runs", "schema": {"type": "string"}},
+      "RequestID": {"name": "X-Request-ID", "in": "header", "description": "Correlation id answered back and added to the logs, generated when missing", "schema": {"type": "string", "pattern": "^[A-Za-z0-9._:-]{1,128}$"}}
+    },
+    "responses": {
+      "BadRequest": {
+        "description": "The request does not match the schema, details lists the wrong fields",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      },
+      "Error": {
+        "description": "The request failed, code tells the kind of failure",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      }
+    },
+    "schemas": {
+      "Provider": {"type": "string", "enum": ["github", "gitlab", "bitbucket"]},
+      "ReadinessReport": {
+        "type": "object",
+        "properties": {
+          "status": {"type": "string", "enum": ["ok", "failing"]},
+          "checks": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "name": {"type": "string"},
+                "status": {"type": "string", "enum": ["ok", "failing"]},
+                "message": {"type": "string"},
+                "duration_ms": {"type": "integer"}
+              }
+            }
+          }
+        }
+      },
+      "CreateRequest": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["repo", "owner", "content"],
+        "properties": {
+          "repo": {"type": "string", "minLength": 1},
+          "owner": {"type": "string", "minLength": 1},
+          "content": {"type": "string", "minLength": 1},
+          "provider": {"$ref": "#/components/schemas/Provider"},
+          "host": {"type": "string"},
+          "dry_run": {"type": "boolean"}
+        }
+      },
+      "UpdateRequest": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["repo", "owner", "changes"],
+        "properties": {
+          "repo": {"type": "string", "minLength": 1},
+          "owner": {"type": "string", "minLength": 1},
+          "changes": {"type": "object", "additionalProperties": {"type": "array", "items": {"$ref": "#/components/schemas/SecretChange"}}},
+          "provider": {"$ref": "#/components/schemas/Provider"},
+          "host": {"type": "string"},
+          "dry_run": {"type": "boolean"},
+          "audit_in_pr": {"type": "boolean"},
+          "audit_file": {"type": "boolean"}
+        }
+      },
+      "SecretChange": {
+        "type": "object",
+        "required": ["hashed_secret", "line_number", "is_secret", "justification"],
+        "properties": {
+          "hashed_secret": {"type": "string", "minLength": 1},
+          "line_number": {"type": "integer", "minimum": 1},
+          "is_secret": {"type": "boolean"},
+          "justification": {"type": "string", "minLength": 1}
+        }
+      },
+      "OrgCreateRequest": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "languages": {"type": "array", "items": {"type": "string"}},
+          "topics": {"type": "array", "items": {"type": "string"}},
+          "name_regex": {"type": "string"},
+          "include_archived": {"type": "boolean"},
+          "concurrency": {"type": "integer", "minimum": 1},
+          "host": {"type": "string"}
+        }
+      },
+      "RolloutReport": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "org": {"type": "string"},
+          "status": {"type": "string", "enum": ["running", "done"]},
+          "started_at": {"type": "string", "format": "date-time"},
+          "total": {"type": "integer"},
+          "counts": {"type": "object", "additionalProperties": {"type": "integer"}},
+          "repos": {"type": "array", "items": {"type": "object", "properties": {
+            "name": {"type": "string"},
+            "status": {"type": "string", "enum": ["pending", "running", "created", "skipped", "failed"]},
+            "message": {"type": "string"}
+          }}}
+        }
+      },
+      "Error": {
+        "type": "object",
+        "required": ["code", "message", "request_id"],
+        "properties": {
+          "code": {"type": "string", "enum": ["in_progress", "invalid_input", "unsupported_media_type", "unauthorized", "forbidden", "not_found", "conflict", "rate_limited",
+            "upstream_failure", "timeout", "unavailable", "internal"]},
+          "message": {"type": "string"},
+          "details": {"oneOf": [
+            {"type": "string"},
+            {"type": "array", "items": {"type": "object", "properties": {"field": {"type": "string"}, "message": {"type": "string"}}}}
+          ]},
+          "request_id": {"type": "string"}
+        }
+      }
+    }
+  }
+}`
+
+// subset of JSON schema validated for the request bodies, the body schemas must not use other
+// keywords than the fields below and description
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *additionalProperties  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	OneOf                []*jsonSchema          `json:"oneOf"`
+	MinLength            *int                   `json:"minLength"`
+	Minimum              *float64               `json:"minimum"`
+}
+
+// false, or the schema of the properties missing from properties
+type additionalProperties struct {
+	allowed bool
+	schema  *jsonSchema
+}
+
+func (additional *additionalProperties) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &additional.allowed); err == nil {
+		return nil
+	}
+	additional.allowed = true
+	additional.schema = new(jsonSchema)
+	return json.Unmarshal(data, additional.schema)
+}
+
+type openAPIDocument struct {
+	Components struct {
+		Schemas map[string]*jsonSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+var openAPISchemas = loadOpenAPISchemas()
+
+func loadOpenAPISchemas() map[string]*jsonSchema {
+	var document openAPIDocument
+	if err := json.Unmarshal([]byte(openAPISpec), &document); err != nil {
+		ZeroLogger.Error().Msgf("OpenAPI spec is not valid json: %v", err)
+	}
+	return document.Components.Schemas
+}
+
+func fieldPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// collects every mismatch between the value and the schema, identified by its field path
+func validateSchema(schema *jsonSchema, value interface{}, path string) []fieldError {
+	if schema.Ref != "" {
+		return validateSchema(openAPISchemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")], value, path)
+	}
+	field := path
+	if field == "" {
+		field = "body"
+	}
+	if len(schema.Enum) > 0 {
+		for _, allowed := range schema.Enum {
+			if allowed == value {
+				return nil
+			}
+		}
+		return []fieldError{{Field: field, Message: fmt.Sprintf("must be one of %v", schema.Enum)}}
+	}
+	if len(schema.OneOf) > 0 {
+		matches := 0
+		for _, alternative := range schema.OneOf {
+			if len(validateSchema(alternative, value, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return []fieldError{{Field: field, Message: fmt.Sprintf("must match exactly one of %d schemas", len(schema.OneOf))}}
+		}
+		return nil
+	}
+	var errors []fieldError
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []fieldError{{Field: field, Message: "must be an object"}}
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				errors = append(errors, fieldError{Field: fieldPath(path, name), Message: "is required"})
+			}
+		}
+		for name, property := range object {
+			if propertySchema, ok := schema.Properties[name]; ok {
+				errors = append(errors, validateSchema(propertySchema, property, fieldPath(path, name))...)
+			} else if schema.AdditionalProperties != nil && !schema.AdditionalProperties.allowed {
+				errors = append(errors, fieldError{Field: fieldPath(path, name), Message: "is not a known field"})
+			} else if schema.AdditionalProperties != nil && schema.AdditionalProperties.schema != nil {
+				errors = append(errors, validateSchema(schema.AdditionalProperties.schema, property, fieldPath(path, name))...)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []fieldError{{Field: field, Message: "must be an array"}}
+		}
+		for i, item := range array {
+			errors = append(errors, validateSchema(schema.Items, item, fmt.Sprintf("%s[%d]", field, i))...)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return []fieldError{{Field: field, Message: "must be a string"}}
+		}
+		if schema.MinLength != nil && len(text) < *schema.MinLength {
+			errors = append(errors, fieldError{Field: field, Message: fmt.Sprintf("must have at least %d characters", *schema.MinLength)})
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []fieldError{{Field: field, Message: "must be a boolean"}}
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return []fieldError{{Field: field, Message: "must be an integer"}}
+		}
+		if schema.Minimum != nil && number < *schema.Minimum {
+			errors = append(errors, fieldError{Field: field, Message: fmt.Sprintf("must be at least %v", *schema.Minimum)})
+		}
+	}
+	return errors
+}
+
+// rejects the bodies not matching the named schema of the spec with a 400 listing the fields.
+// The spec only declares JSON bodies, the other content types are answered 415
+func ValidateBody(schemaName string) fiber.Handler {
+	schema, ok := openAPISchemas[schemaName]
+	if !ok {
+		panic(fmt.Sprintf("schema %s is not in the OpenAPI spec", schemaName))
+	}
+	return func(c *fiber.Ctx) error {
+		contentType := c.Get(fiber.HeaderContentType)
+		if len(bytes.TrimSpace(c.Body())) > 0 && !strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			statusCode, msg := failure(requestContext(c), "", ErrCodeUnsupportedMedia, fmt.Sprintf("Content type %q is not supported, send %s", contentType, fiber.MIMEApplicationJSON), nil)
+			c.Type("json")
+			return c.Status(statusCode).SendString(msg)
+		}
+		var body interface{} = map[string]interface{}{}
+		if len(bytes.TrimSpace(c.Body())) > 0 {
+			if err := json.Unmarshal(c.Body(), &body); err != nil {
+				statusCode, msg := failure(requestContext(c), "", ErrCodeInvalidInput, "Error in data, please review input data", err)
+				c.Type("json")
+				return c.Status(statusCode).SendString(msg)
+			}
+		}
+		errors := validateSchema(schema, body, "")
+		if len(errors) == 0 {
+			return c.Next()
+		}
+		sort.Slice(errors, func(i, j int) bool { return errors[i].Field < errors[j].Field })
+		requestID := requestIDOf(c)
+		if requestID == "" {
+			requestID = NewRequestID()
+		}
+		Logger(requestContext(c)).Error().Msgf("request body does not match %s: %v", schemaName, errors)
+		c.Type("json")
+		return c.Status(ErrCodeInvalidInput.HTTPStatus()).SendString(errorBody(requestID, ErrCodeInvalidInput, "Error in data, please review input data", errors))
+	}
+}
+
+func OpenAPIHandler(c *fiber.Ctx) error {
+	c.Type("json")
+	return c.SendString(openAPISpec)
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("OpenAPI", func() {
+	var app *fiber.App
+	var reached bool
+
+	send := func(contentType string, body string) (int, map[string]interface{}) {
+		request := httptest.NewRequest("POST", "/update", strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		response, err := app.Test(request, -1)
+		Expect(err).To(BeNil())
+		content, _ := ioutil.ReadAll(response.Body)
+		result := map[string]interface{}{}
+		_ = json.Unmarshal(content, &result)
+		return response.StatusCode, result
+	}
+
+	BeforeEach(func() {
+		reached = false
+		app = fiber.New()
+		app.Get("/api/openapi.json", OpenAPIHandler)
+		app.Post("/update", ValidateBody("UpdateRequest"), func(c *fiber.Ctx) error {
+			reached = true
+			return c.SendStatus(200)
+		})
+	})
+
+	It("should serve a valid OpenAPI 3 document", func() {
+		response, err := app.Test(httptest.NewRequest("GET", "/api/openapi.json", nil))
+		Expect(err).To(BeNil())
+		Expect(response.Header.Get("Content-Type")).To(ContainSubstring("application/json"))
+		var document map[string]interface{}
+		Expect(json.NewDecoder(response.Body).Decode(&document)).To(Succeed())
+		Expect(document["openapi"]).To(Equal("3.0.3"))
+		Expect(document["paths"]).To(HaveKey("/api/detectsecrets/update"))
+	})
+
+	It("should let a valid body through", func() {
+		body := `{"repo": "repo", "owner": "john", "changes": {"a.py": [{"hashed_secret": "aaa", "line_number": 3, "is_secret": false, "justification": "fixture"}]}}`
+		statusCode, _ := send("application/json", body)
+		Expect(statusCode).To(Equal(200))
+		Expect(reached).To(BeTrue())
+	})
+
+	It("should reject an invalid body with the path of every wrong field", func() {
+		body := `{"owner": "", "provider": "svn", "changes": {"a.py": [{"hashed_secret": "aaa", "line_number": 1.5, "is_secret": "no"}]}}`
+		statusCode, result := send("application/json; charset=utf-8", body)
+		Expect(statusCode).To(Equal(400))
+		Expect(reached).To(BeFalse())
+		Expect(result["code"]).To(Equal("invalid_input"))
+		Expect(result["request_id"]).NotTo(BeEmpty())
+		var fields []string
+		for _, fieldError := range result["details"].([]interface{}) {
+			fields = append(fields, fieldError.(map[string]interface{})["field"].(string))
+		}
+		Expect(fields).To(Equal([]string{
+			"changes.a.py[0].is_secret", "changes.a.py[0].justification", "changes.a.py[0].line_number", "owner", "provider", "repo",
+		}))
+	})
+
+	It("should reject malformed json and answer 415 to other content types", func() {
+		statusCode, result := send("application/json", `{"repo": `)
+		Expect(statusCode).To(Equal(400))
+		Expect(result["message"]).To(ContainSubstring("Error in data"))
+		statusCode, result = send("application/x-www-form-urlencoded", "repo=repo")
+		Expect(statusCode).To(Equal(415))
+		Expect(result["code"]).To(Equal("unsupported_media_type"))
+		Expect(reached).To(BeFalse())
+	})
+
+	It("should reject the fields the schema does not declare", func() {
+		body := `{"repo": "repo", "owner": "john", "ownr": "john", "changes": {}}`
+		statusCode, result := send("application/json", body)
+		Expect(statusCode).To(Equal(400))
+		Expect(reached).To(BeFalse())
+		details := result["details"].([]interface{})
+		Expect(details).To(HaveLen(1))
+		Expect(details[0]).To(Equal(map[string]interface{}{"field": "ownr", "message": "is not a known field"}))
+	})
+
+	It("should accept a value matching exactly one of the oneOf schemas", func() {
+		schema := &jsonSchema{OneOf: []*jsonSchema{{Type: "string"}, {Type: "array", Items: &jsonSchema{Type: "string"}}}}
+		Expect(validateSchema(schema, "a", "")).To(BeEmpty())
+		Expect(validateSchema(schema, []interface{}{"a"}, "")).To(BeEmpty())
+		Expect(validateSchema(schema, 1.0, "details")).To(Equal([]fieldError{{Field: "details", Message: "must match exactly one of 2 schemas"}}))
+	})
+
+	It("should only use the keywords the validator supports in the request bodies", func() {
+		supported := map[string]bool{
+			"$ref": true, "type": true, "required": true, "properties": true, "additionalProperties": true,
+			"items": true, "enum": true, "oneOf": true, "minLength": true, "minimum": true, "description": true,
+		}
+		var document struct {
+			Components struct {
+				Schemas map[string]json.RawMessage `json:"schemas"`
+			} `json:"components"`
+		}
+		Expect(json.Unmarshal([]byte(openAPISpec), &document)).To(Succeed())
+		var check func(name string, raw json.RawMessage)
+		check = func(name string, raw json.RawMessage) {
+			var schema map[string]json.RawMessage
+			if json.Unmarshal(raw, &schema) != nil {
+				return
+			}
+			for keyword, value := range schema {
+				Expect(supported).To(HaveKey(keyword), name)
+				switch keyword {
+				case "properties":
+					var properties map[string]json.RawMessage
+					Expect(json.Unmarshal(value, &properties)).To(Succeed())
+					for property, propertySchema := range properties {
+						check(name+"."+property, propertySchema)
+					}
+				case "items", "additionalProperties":
+					check(name, value)
+				case "oneOf":
+					var alternatives []json.RawMessage
+					Expect(json.Unmarshal(value, &alternatives)).To(Succeed())
+					for _, alternative := range alternatives {
+						check(name, alternative)
+					}
+				case "$ref":
+					var ref string
+					Expect(json.Unmarshal(value, &ref)).To(Succeed())
+					target := strings.TrimPrefix(ref, "#/components/schemas/")
+					check(target, document.Components.Schemas[target])
+				}
+			}
+		}
+		for _, name := range []string{"CreateRequest", "UpdateRequest", "OrgCreateRequest"} {
+			check(name, document.Components.Schemas[name])
+		}
+	})
+})
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"regexp"
+	"sync"
+)
+
+// lists the organization repos matching the filters and creates the secrets file of each one in
+// the background, the progress is reported by OrgRolloutReport
+func (controller controllerImplementation) CreateOrgSecretFiles(c contextInterface) (int, string) {
+	org := c.Params("org")
+	if err := authorizeOrg(requestContext(c), org); err != nil {
+		return failure(requestContext(c), "", ErrCodeForbidden, fmt.Sprintf("You do not have access to %s", org), err)
+	}
+	data := new(orgCreateParams)
+	if err := c.BodyParserOrgCreate(data); err != nil {
+		return failure(requestContext(c), "", ErrCodeInvalidInput, "Error in data, please review input data", err)
+	}
+	filter := RepoFilter{Languages: data.Languages, Topics: data.Topics, IncludeArchived: data.IncludeArchived}
+	if data.NameRegex != "" {
+		nameRegex, err := regexp.Compile(data.NameRegex)
+		if err != nil {
+			return failure(requestContext(c), "", ErrCodeInvalidInput, "Error in data, please review input data", err)
+		}
+		filter.NameRegex = nameRegex
+	}
+	concurrency := data.Concurrency
+	if concurrency <= 0 || concurrency > Config.Workers.OrgRolloutConcurrency {
+		concurrency = Config.Workers.OrgRolloutConcurrency
+	}
+	gitService, err := GitHubHostService(requestContext(c), data.Host)
+	if err != nil {
+		return failure(requestContext(c), "", ErrCodeInvalidInput, "Error in data, please review input data", err)
+	}
+	repos, err := gitService.ListOrgRepos(org, filter)
+	if err != nil {
+		return failure(requestContext(c), "", ErrCodeUpstream, fmt.Sprintf("Error listing the repos of %s", org), err)
+	}
+
+	id := NewRequestID()
+	OrgRollouts.Start(id, org, repos)
+	job := newJob(jobKindOrgRollout, rolloutJobParams{ID: id, Host: data.Host, Org: org, Repos: repos, Concurrency: concurrency})
+	job.ID = id
+	job.Name = fmt.Sprintf("create %s in %d repos of %s", Config.Scanner.SecretsFileName, len(repos), org)
+	job.Host = data.Host
+	job.RequestID = requestIDOf(c)
+	if err := JobQueue.Enqueue(job); err != nil {
+		return failure(requestContext(c), id, ErrCodeUnavailable, fmt.Sprintf("Cannot enqueue %s", job.Name), err)
+	}
+	report, _ := OrgRollouts.Report(id)
+	return 202, reportJSON(report)
+}
+
+func (controller controllerImplementation) OrgRolloutReport(c contextInterface) (int, string) {
+	if err := authorizeOrg(requestContext(c), c.Params("org")); err != nil {
+		return failure(requestContext(c), "", ErrCodeForbidden, fmt.Sprintf("You do not have access to %s", c.Params("org")), err)
+	}
+	report, ok := OrgRollouts.Report(c.Params("id"))
+	if !ok || report.Org != c.Params("org") {
+		return failure(requestContext(c), c.Params("id"), ErrCodeNotFound, "Rollout not found", nil)
+	}
+	return 200, reportJSON(report)
+}
+
+func reportJSON(report RolloutReport) string {
+	content, _ := json.Marshal(report)
+	return string(content)
+}
+
+// runs the create flow of every repo, at most concurrency at a time
+func rollout(ctx context.Context, id string, host string, org string, repos []string, concurrency int) {
+	slots := make(chan struct{}, concurrency)
+	var wait sync.WaitGroup
+	for _, repo := range repos {
+		slots <- struct{}{}
+		wait.Add(1)
+		go func(repo string) {
+			defer func() {
+				<-slots
+				wait.Done()
+			}()
+			OrgRollouts.Update(id, repo, RolloutRunning, "")
+			status, message := createRepoBaseline(ctx, host, org, repo)
+			Logger(ctx).Info().Msgf("Rollout %s, %s/%s %s: %s", id, org, repo, status, message)
+			OrgRollouts.Update(id, repo, status, message)
+		}(repo)
+	}
+	wait.Wait()
+	OrgRollouts.Finish(id)
+}
+
+// scans the default branch and opens the PR adding the secrets file, repos that already have
+// one or an open PR adding it are skipped
+func createRepoBaseline(ctx context.Context, host string, owner string, repo string) (string, string) {
+	gitService, err := GitHubHostService(ctx, host)
+	if err != nil {
+		return RolloutFailed, err.Error()
+	}
+	existing, err := gitService.FileContent(owner, repo, Config.Scanner.SecretsFileName, "")
+	if err != nil {
+		return RolloutFailed, err.Error()
+	}
+	if existing != nil {
+		return RolloutSkipped, fmt.Sprintf("%s already exists", Config.Scanner.SecretsFileName)
+	}
+	pull, found, err := gitService.OpenBaselinePullRequest(owner, repo)
+	if err != nil {
+		return RolloutFailed, err.Error()
+	}
+	if found {
+		return RolloutSkipped, fmt.Sprintf("PR #%d adding %s is already open", pull.Number, Config.Scanner.SecretsFileName)
+	}
+	_, path, err := gitService.CloneRepo(owner, repo)
+	if err != nil {
+		return RolloutFailed, fmt.Sprintf("Error Cloning Repo: %v", err)
+	}
+	defer removeClone(ctx, path)
+	scan, err := ThirdPartyScanner.Scan(path)
+	if err != nil {
+		return RolloutFailed, fmt.Sprintf("scan of %s/%s failed: %v", owner, repo, err)
+	}
+	content, err := MergeBaseline(nil, scan)
+	if err != nil {
+		return RolloutFailed, err.Error()
+	}
+	description := fmt.Sprintf("Created and added %s file, the bot scanned the repo as part of the rollout of "+
+		"detect-secrets to %s and placed the secrets it found in %s file.", Config.Scanner.SecretsFileName, owner, Config.Scanner.SecretsFileName)
+	data := &createParams{Repo: repo, Owner: owner, Content: string(content), Provider: GitHubProvider, Host: host, RequestedBy: "org-rollout"}
+	status, msg := createBaseline(ctx, data, "Create", description)
+	if status >= 300 {
+		return RolloutFailed, failureError(msg).Error()
+	}
+	return RolloutCreated, msg
+}
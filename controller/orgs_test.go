@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	"github.com/go-git/go-git/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"sync"
+)
+
+func orgContext(params map[string]string, data orgCreateParams) contextMock {
+	return contextMock{
+		ParamsHandler: func(key string) string { return params[key] },
+		BodyParserOrgCreateHandler: func(body *orgCreateParams) error {
+			*body = data
+			return nil
+		},
+	}
+}
+
+var _ = Describe("Organization rollout", func() {
+	var mutex sync.Mutex
+	var opened []string
+	var filter services.RepoFilter
+	var jobs []services.Job
+	var clonePath string
+	gitService := gitServiceMock{}
+
+	BeforeEach(func() {
+		opened, jobs = nil, nil
+		services.OrgRollouts = services.NewRolloutStore()
+		services.JobQueue = jobQueueMock{EnqueueHandler: func(job services.Job) error {
+			jobs = append(jobs, job)
+			return nil
+		}}
+		gitService.ListOrgReposHandler = func(org string, repoFilter services.RepoFilter) ([]string, error) {
+			Expect(org).To(Equal("acme"))
+			filter = repoFilter
+			return []string{"api", "web", "legacy", "docs"}, nil
+		}
+		gitService.FileContentHandler = func(_ string, repo string, _ string, _ string) ([]byte, error) {
+			if repo == "legacy" {
+				return []byte(`{"results": {}}`), nil
+			}
+			return nil, nil
+		}
+		gitService.OpenBaselinePullRequestHandler = func(_ string, repo string) (services.BaselinePullRequest, bool, error) {
+			if repo == "docs" {
+				return services.BaselinePullRequest{Number: 9, HeadOwner: "bot", HeadBranch: "secrets", BaseBranch: "main"}, true, nil
+			}
+			return services.BaselinePullRequest{}, false, nil
+		}
+		gitService.CheckUserAccessRepoHandler = func(string, string) error { return nil }
+		gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
+			return "bot", "http://github.com/bot/repo", nil
+		}
+		gitService.CheckForkedRepoHandler = func(string, string) error { return nil }
+		gitService.CloneRepoHandler = func(_ string, repo string) (*git.Repository, string, error) {
+			if repo == "web" {
+				return nil, "", errors.New("clone failed")
+			}
+			path, err := ioutil.TempDir("", "rollout")
+			Expect(err).To(BeNil())
+			services.Clones.Add(path)
+			mutex.Lock()
+			defer mutex.Unlock()
+			clonePath = path
+			return new(git.Repository), path, nil
+		}
+		gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
+			return "branch", "main", nil
+		}
+		gitService.CreateSecretFileHandler = func(string, string) error { return nil }
+		gitService.CommitAndPushHandler = func(string, string, string, string, string, *git.Repository) error {
+			return nil
+		}
+		gitService.OpenPullRequestHandler = func(_ string, _ string, repo string, _ string, _ string, _ string, _ string) (int, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			opened = append(opened, repo)
+			return len(opened), nil
+		}
+		services.GitServiceObject = gitService
+		services.ThirdPartyScanner = scannerMock{ScanHandler: func(string, ...string) ([]byte, error) {
+			return []byte(`{"results": {}}`), nil
+		}}
+	})
+
+	It("should create the secrets file of every repo without one and report the progress", func() {
+		c := orgContext(map[string]string{"org": "acme"}, orgCreateParams{Languages: []string{"Go"}, NameRegex: "^a", Concurrency: 100})
+		statusCode, msg := ControllerObject.CreateOrgSecretFiles(c)
+		Expect(statusCode).To(Equal(202))
+		Expect(filter.Languages).To(Equal([]string{"Go"}))
+		Expect(filter.NameRegex.String()).To(Equal("^a"))
+		var report services.RolloutReport
+		Expect(json.Unmarshal([]byte(msg), &report)).To(Succeed())
+		Expect(report.Total).To(Equal(4))
+		Expect(report.Counts[services.RolloutPending]).To(Equal(4))
+
+		Expect(jobs).To(HaveLen(1))
+		Expect(jobs[0].Run(context.Background())).To(Succeed())
+		Expect(opened).To(Equal([]string{"api"}))
+		Expect(clonePath).NotTo(BeADirectory())
+
+		statusCode, msg = ControllerObject.OrgRolloutReport(orgContext(map[string]string{"org": "acme", "id": report.ID}, orgCreateParams{}))
+		Expect(statusCode).To(Equal(200))
+		var finished services.RolloutReport
+		Expect(json.Unmarshal([]byte(msg), &finished)).To(Succeed())
+		Expect(finished.Status).To(Equal(services.RolloutDone))
+		Expect(finished.Counts).To(Equal(map[string]int{services.RolloutCreated: 1, services.RolloutFailed: 1, services.RolloutSkipped: 2}))
+	})
+
+	It("should resume a rollout with only the repos it had not finished", func() {
+		services.OrgRollouts.Start("r1", "acme", []string{"api", "web", "docs"})
+		services.OrgRollouts.Update("r1", "web", services.RolloutCreated, "pull request #3")
+		services.OrgRollouts.Update("r1", "docs", services.RolloutRunning, "")
+		run, err := jobRunner(jobKindOrgRollout, json.RawMessage(`{"id": "r1", "org": "acme", "repos": ["api", "web", "docs"], "concurrency": 1}`))
+		Expect(err).To(BeNil())
+		Expect(run(context.Background())).To(Succeed())
+		Expect(opened).To(Equal([]string{"api"}))
+
+		report, ok := services.OrgRollouts.Report("r1")
+		Expect(ok).To(BeTrue())
+		Expect(report.Repos).To(Equal([]services.RolloutRepo{
+			{Name: "api", Status: services.RolloutCreated, Message: "PR was Created !"},
+			{Name: "web", Status: services.RolloutCreated, Message: "pull request #3"},
+			{Name: "docs", Status: services.RolloutSkipped, Message: "PR #9 adding .secrets.baseline is already open"},
+		}))
+		Expect(report.Status).To(Equal(services.RolloutDone))
+	})
+
+	It("should reject an invalid name regex", func() {
+		statusCode, _ := ControllerObject.CreateOrgSecretFiles(orgContext(map[string]string{"org": "acme"}, orgCreateParams{NameRegex: "("}))
+		Expect(statusCode).To(Equal(400))
+		Expect(jobs).To(BeEmpty())
+	})
+
+	It("should not find rollouts of other organizations", func() {
+		services.OrgRollouts.Start("r1", "acme", nil)
+		statusCode, _ := ControllerObject.OrgRolloutReport(orgContext(map[string]string{"org": "other", "id": "r1"}, orgCreateParams{}))
+		Expect(statusCode).To(Equal(404))
+	})
+})
@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/go-git/go-git/v5"
+	"strings"
+	"time"
+)
+
+// git operations the create and update pipelines go through
+type pipelineGitService interface {
+	ForkRepo(owner string, repo string) (forkedOwner interface{}, gitURL interface{}, err error)
+	CheckForkedRepo(owner string, repo string) error
+	CloneRepo(owner string, repo string) (*git.Repository, string, error)
+	CreateBranchRepo(repoGit *git.Repository, owner string, repoName string, action string, requestID string) (string, string, error)
+	CheckoutBranch(repoGit *git.Repository, branch string) error
+	CommitAndPush(owner string, originalOwner string, repo string, action string, requestID string, repoGit *git.Repository) error
+	OpenPullRequest(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string) (int, error)
+}
+
+// create or update flow split in steps: fork, push of a branch with the secrets file written
+// by write, and pull request. The checkpoint is saved after each step, a retry of the same
+// request skips the steps it marks as done
+type pipeline struct {
+	checkpoint  PipelineCheckpoint
+	record      *RequestRecord
+	action      string
+	description string
+	write       func(path string) error
+	// code and message answered when write fails
+	writeCode    ErrorCode
+	writeMessage string
+}
+
+// identifies the retries of a request, two requests of the same caller with the same action
+// and params share their checkpoint. The caller is not in params, RequestedBy is not marshalled
+func pipelineKey(action string, requestedBy string, params interface{}) string {
+	content, _ := json.Marshal(params)
+	hash := sha256.New()
+	hash.Write([]byte(action + "\n" + requestedBy + "\n"))
+	hash.Write(content)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// claims the checkpoint left by an earlier run of the request, or the one of a new pipeline.
+// It is not claimed while a run of the same request holds it. The id names the branch, it is
+// kept only when the caller resumes its own fork or branch and is new otherwise
+func claimCheckpoint(ctx context.Context, key string) (PipelineCheckpoint, bool) {
+	now := time.Now()
+	fresh := PipelineCheckpoint{Key: key, PipelineID: NewRequestID()}
+	checkpoint, claimed, err := History.ClaimCheckpoint(fresh, now.Add(-Config.Timeouts.RequestLease),
+		now.Add(-time.Duration(Config.Timeouts.CheckpointRetentionHrs)*time.Hour))
+	if err != nil {
+		Logger(ctx).Error().Msgf("Error claiming the checkpoint of pipeline %s: %v", key, err)
+		return fresh, true
+	}
+	if !claimed || checkpoint.PipelineID == fresh.PipelineID {
+		return checkpoint, claimed
+	}
+	if checkpoint.ForkOwner == "" && checkpoint.Branch == "" {
+		checkpoint.PipelineID = fresh.PipelineID
+		return checkpoint, true
+	}
+	Logger(ctx).Info().Msgf("Resuming pipeline %s from its checkpoint", checkpoint.PipelineID)
+	return checkpoint, true
+}
+
+// deletes the checkpoint once the pipeline has finished, or lets a retry claim it
+func finishCheckpoint(ctx context.Context, checkpoint PipelineCheckpoint, statusCode int) {
+	if statusCode == 200 {
+		deleteCheckpoint(ctx, checkpoint)
+		return
+	}
+	if err := History.ReleaseCheckpoint(checkpoint.Key); err != nil {
+		Logger(ctx).Error().Msgf("Error releasing the checkpoint of pipeline %s: %v", checkpoint.PipelineID, err)
+	}
+}
+
+func saveCheckpoint(ctx context.Context, checkpoint PipelineCheckpoint) {
+	if err := History.SaveCheckpoint(checkpoint); err != nil {
+		Logger(ctx).Error().Msgf("Error saving the checkpoint of pipeline %s: %v", checkpoint.PipelineID, err)
+	}
+}
+
+// forgets the checkpoint once the pipeline has finished
+func deleteCheckpoint(ctx context.Context, checkpoint PipelineCheckpoint) {
+	if err := History.DeleteCheckpoint(checkpoint.Key); err != nil {
+		Logger(ctx).Error().Msgf("Error deleting the checkpoint of pipeline %s: %v", checkpoint.PipelineID, err)
+	}
+}
+
+// deletes a clone once the flow that made it is done
+func removeClone(ctx context.Context, path string) {
+	if err := Clones.Remove(path); err != nil {
+		Logger(ctx).Error().Msgf("Error removing the clone %s: %v", path, err)
+	}
+}
+
+// makes the pipeline of key push to the branch of the open pull request instead of forking and
+// branching, unless an earlier run already picked its branch or is still running
+func reusePullRequest(ctx context.Context, key string, pull BaselinePullRequest) {
+	checkpoint, claimed := claimCheckpoint(ctx, key)
+	if !claimed {
+		return
+	}
+	defer finishCheckpoint(ctx, checkpoint, 0)
+	if checkpoint.Branch != "" {
+		return
+	}
+	Logger(ctx).Info().Msgf("Pushing to the branch of the open PR #%d", pull.Number)
+	checkpoint.ForkOwner, checkpoint.Branch, checkpoint.BaseBranch = pull.HeadOwner, pull.HeadBranch, pull.BaseBranch
+	saveCheckpoint(ctx, checkpoint)
+}
+
+func (p *pipeline) run(ctx context.Context, gitService pipelineGitService) (int, string) {
+	requestID := p.checkpoint.PipelineID
+	owner, repo := p.record.Owner, p.record.Repo
+	if p.checkpoint.PullRequest != 0 {
+		p.record.PullHead, p.record.PullBase = fmt.Sprintf("%s:%s", p.checkpoint.ForkOwner, p.checkpoint.Branch), p.checkpoint.BaseBranch
+		p.recordPullRequest(p.checkpoint.PullRequest)
+		Logger(ctx).Info().Msgf("PR #%d was already opened", p.checkpoint.PullRequest)
+		return 200, "PR was Created !"
+	}
+
+	if p.checkpoint.ForkOwner == "" {
+		forkStarted := time.Now()
+		var _forkOwner interface{}
+		err := Retry(ctx, "fork", func() (forkErr error) {
+			_forkOwner, _, forkErr = gitService.ForkRepo(owner, repo)
+			return forkErr
+		})
+		if err != nil {
+			return failure(ctx, requestID, ErrCodeUpstream, "Error Forking Repo", err)
+		}
+
+		forkOwner := fmt.Sprintf("%v", _forkOwner)
+		err = gitService.CheckForkedRepo(forkOwner, repo)
+		ObservePipelineStep("fork", forkStarted)
+
+		if err != nil {
+			return failure(ctx, requestID, ErrCodeUpstream, "Repo didn't fork properly", err)
+		}
+		p.checkpoint.ForkOwner = forkOwner
+		saveCheckpoint(ctx, p.checkpoint)
+	}
+	forkOwner := p.checkpoint.ForkOwner
+	Logger(ctx).Info().Msgf("Owner who forked the repo: %s", forkOwner)
+
+	if p.checkpoint.Pushed {
+		Logger(ctx).Info().Msgf("Branch %s was already pushed", p.checkpoint.Branch)
+	} else {
+		cloneStarted := time.Now()
+		forkedRepoURL, path, err := gitService.CloneRepo(forkOwner, repo)
+		ObservePipelineStep("clone", cloneStarted)
+		if err != nil {
+			return failure(ctx, requestID, ErrCodeUpstream, "Error Cloning Repo", err)
+		}
+		defer removeClone(ctx, path)
+
+		branchStarted := time.Now()
+		currentBranch, headBranch := p.checkpoint.Branch, p.checkpoint.BaseBranch
+		if currentBranch != "" {
+			// branch of an open pull request, the new commit goes on top of it
+			err = gitService.CheckoutBranch(forkedRepoURL, currentBranch)
+		} else {
+			currentBranch, headBranch, err = gitService.CreateBranchRepo(forkedRepoURL, owner, repo, strings.ToLower(p.action), requestID)
+		}
+		ObservePipelineStep("branch", branchStarted)
+		if err != nil {
+			return failure(ctx, requestID, ErrCodeUpstream, "Error Creating Branch", err)
+		}
+		p.record.PullHead, p.record.PullBase = fmt.Sprintf("%s:%s", forkOwner, currentBranch), headBranch
+
+		err = p.write(path)
+		if err != nil {
+			return failure(ctx, requestID, p.writeCode, p.writeMessage, err)
+		}
+
+		err = gitService.CommitAndPush(forkOwner, owner, repo, p.action, requestID, forkedRepoURL)
+		if err != nil {
+			return failure(ctx, requestID, ErrCodeUpstream, "Error Pushing Branch", err)
+		}
+		p.checkpoint.Branch, p.checkpoint.BaseBranch, p.checkpoint.Pushed = currentBranch, headBranch, true
+		saveCheckpoint(ctx, p.checkpoint)
+	}
+	p.record.PullHead, p.record.PullBase = fmt.Sprintf("%s:%s", forkOwner, p.checkpoint.Branch), p.checkpoint.BaseBranch
+
+	number, err := gitService.OpenPullRequest(forkOwner, owner, repo, p.checkpoint.Branch, p.checkpoint.BaseBranch, p.action, p.description)
+	if errors.Is(err, ErrPullRequestUpdated) {
+		p.checkpoint.PullRequest = number
+		saveCheckpoint(ctx, p.checkpoint)
+		p.recordPullRequest(number)
+		Logger(ctx).Info().Msgf("Updated the existing PR #%d", number)
+		return 200, "PR was Updated !"
+	}
+	if err != nil {
+		return failure(ctx, requestID, ErrCodeUpstream, "Error Opening Pull Request", err)
+	}
+	p.checkpoint.PullRequest = number
+	saveCheckpoint(ctx, p.checkpoint)
+	p.recordPullRequest(number)
+	Logger(ctx).Info().Msg("PR was Created Successfully!")
+	return 200, "PR was Created !"
+}
+
+func (p *pipeline) recordPullRequest(number int) {
+	p.record.PullNumber = number
+	p.record.PullURL = PullRequestURL(p.record.Provider, p.record.Host, p.record.Owner, p.record.Repo, number)
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"errors"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	"github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/go-git/go-git/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Pipeline", func() {
+	var gitService gitServiceMock
+	var forks, clones, pushes int
+	var branchRequestIDs []string
+	var pushErr error
+	context := contextMock{
+		BodyParserCreateHandler: func(data *createParams) error {
+			data.Owner, data.Repo, data.Content = "john", "repo", "{}"
+			return nil
+		},
+	}
+	key := pipelineKey("Create", anonymousRequester, &createParams{Owner: "john", Repo: "repo", Content: "{}"})
+
+	BeforeEach(func() {
+		forks, clones, pushes = 0, 0, 0
+		branchRequestIDs = nil
+		pushErr = nil
+		gitService = gitServiceMock{
+			CheckUserAccessRepoHandler: func(string, string) error { return nil },
+			ForkRepoHandler: func(string, string) (interface{}, interface{}, error) {
+				forks++
+				return "bot", "http://github.com/bot/repo", nil
+			},
+			CheckForkedRepoHandler: func(string, string) error { return nil },
+			CloneRepoHandler: func(string, string) (*git.Repository, string, error) {
+				clones++
+				return new(git.Repository), "path", nil
+			},
+			CreateBranchRepoHandler: func(_ *git.Repository, _ string, _ string, _ string, requestID string) (string, string, error) {
+				branchRequestIDs = append(branchRequestIDs, requestID)
+				return "branch-" + requestID, "main", nil
+			},
+			CreateSecretFileHandler: func(string, string) error { return nil },
+			CommitAndPushHandler: func(string, string, string, string, string, *git.Repository) error {
+				pushes++
+				return pushErr
+			},
+			OpenPullRequestHandler: func(string, string, string, string, string, string, string) (int, error) {
+				return 7, nil
+			},
+		}
+		services.GitServiceObject = gitService
+	})
+
+	It("reuses the fork and the pipeline id when a push is retried", func() {
+		pushErr = errors.New("connection reset")
+		statusCode, _ := ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(502))
+		checkpoint, found, err := services.History.Checkpoint(key)
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(checkpoint.ForkOwner).To(Equal("bot"))
+		Expect(checkpoint.Pushed).To(BeFalse())
+
+		pushErr = nil
+		statusCode, msg := ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(200))
+		Expect(msg).To(Equal("PR was Created !"))
+		Expect(forks).To(Equal(1))
+		Expect(pushes).To(Equal(2))
+		Expect(branchRequestIDs).To(HaveLen(2))
+		Expect(branchRequestIDs[1]).To(Equal(checkpoint.PipelineID))
+		_, found, _ = services.History.Checkpoint(key)
+		Expect(found).To(BeFalse())
+	})
+
+	It("opens the pull request from the branch already pushed", func() {
+		Expect(services.History.SaveCheckpoint(services.PipelineCheckpoint{Key: key, PipelineID: "p1", ForkOwner: "bot",
+			Branch: "branch-p1", BaseBranch: "main", Pushed: true})).To(Succeed())
+		var head string
+		gitService.OpenPullRequestHandler = func(owner string, _ string, _ string, currentBranch string, _ string, _ string, _ string) (int, error) {
+			head = owner + ":" + currentBranch
+			return 7, nil
+		}
+		services.GitServiceObject = gitService
+		statusCode, _ := ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(200))
+		Expect(head).To(Equal("bot:branch-p1"))
+		Expect(forks + clones + pushes).To(Equal(0))
+		history, err := services.History.RepoHistory("john", "repo")
+		Expect(err).To(BeNil())
+		Expect(history.Requests).To(HaveLen(1))
+		Expect(history.Requests[0].RequestID).To(Equal("p1"))
+		Expect(history.Requests[0].PullHead).To(Equal("bot:branch-p1"))
+	})
+
+	It("skips every step once the pull request was opened", func() {
+		Expect(services.History.SaveCheckpoint(services.PipelineCheckpoint{Key: key, PipelineID: "p1", ForkOwner: "bot",
+			Branch: "branch-p1", BaseBranch: "main", Pushed: true, PullRequest: 7})).To(Succeed())
+		gitService.OpenPullRequestHandler = func(string, string, string, string, string, string, string) (int, error) {
+			Fail("the pull request was already opened")
+			return 0, nil
+		}
+		services.GitServiceObject = gitService
+		statusCode, msg := ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(200))
+		Expect(msg).To(Equal("PR was Created !"))
+		Expect(forks + clones + pushes).To(Equal(0))
+	})
+
+	It("keeps the checkpoints of two callers apart", func() {
+		params := &createParams{Owner: "john", Repo: "repo", Content: "{}", RequestedBy: "alice"}
+		Expect(pipelineKey("Create", "alice", params)).NotTo(Equal(pipelineKey("Create", "bob", params)))
+		Expect(pipelineKey("Create", "alice", params)).To(Equal(pipelineKey("Create", "alice", params)))
+	})
+
+	It("names the branches of two callers sending the same change apart", func() {
+		for _, name := range []string{"alice", "bob"} {
+			c := context
+			c.LocalValues = map[string]interface{}{requestContextKey: services.WithIdentity(requestContext(contextMock{}),
+				services.Identity{Name: name, Method: services.AuthMethodAPIKey, Role: utils.RoleAdmin, Orgs: []string{utils.AllOrgs}})}
+			statusCode, _ := ControllerObject.CreateSecretFile(c)
+			Expect(statusCode).To(Equal(200))
+		}
+		Expect(branchRequestIDs).To(HaveLen(2))
+		Expect(branchRequestIDs[0]).NotTo(Equal(branchRequestIDs[1]))
+	})
+
+	It("gives a new id to a checkpoint left before the fork", func() {
+		Expect(services.History.SaveCheckpoint(services.PipelineCheckpoint{Key: key, PipelineID: "p1"})).To(Succeed())
+		statusCode, _ := ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(200))
+		Expect(branchRequestIDs).To(HaveLen(1))
+		Expect(branchRequestIDs[0]).NotTo(Equal("p1"))
+	})
+
+	It("refuses to run while another run of the request holds the checkpoint", func() {
+		checkpoint, claimed, err := services.History.ClaimCheckpoint(services.PipelineCheckpoint{Key: key, PipelineID: "p1"},
+			time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(claimed).To(BeTrue())
+		checkpoint.ForkOwner = "bot"
+		Expect(services.History.SaveCheckpoint(checkpoint)).To(Succeed())
+		statusCode, _ := ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(409))
+		Expect(forks).To(Equal(0))
+
+		services.Config.Timeouts.RequestLease = time.Nanosecond
+		statusCode, _ = ControllerObject.CreateSecretFile(context)
+		Expect(statusCode).To(Equal(200))
+		Expect(forks).To(Equal(0))
+		Expect(branchRequestIDs).To(Equal([]string{"p1"}))
+	})
+})
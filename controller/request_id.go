@@ -0,0 +1,36 @@
+package controller
+
+import (
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/gofiber/fiber/v2"
+	"regexp"
+	"time"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// ids sent by the clients are only kept when they are safe to log and to echo back
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// tags the request with the X-Request-ID sent by the client, or a new one, answers it back in
+// the same header and logs every line of the request with it
+func RequestIDMiddleware(c *fiber.Ctx) error {
+	requestID := c.Get(requestIDHeader)
+	if !requestIDPattern.MatchString(requestID) {
+		requestID = NewRequestID()
+	}
+	c.Set(requestIDHeader, requestID)
+	ctx := WithRequestID(requestContext(c), requestID)
+	c.Locals(requestContextKey, ctx)
+	started := time.Now()
+	err := c.Next()
+	Logger(ctx).Info().Str("method", c.Method()).Str("path", c.Path()).Int("status", c.Response().StatusCode()).
+		Dur("duration", time.Since(started)).Msg("Request finished")
+	return err
+}
+
+// id of the request set by RequestIDMiddleware, empty outside it
+func requestIDOf(c localsContext) string {
+	return RequestID(requestContext(c))
+}
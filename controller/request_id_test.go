@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rs/zerolog"
+	"net/http/httptest"
+)
+
+var _ = Describe("Request ID", func() {
+	var logs *bytes.Buffer
+	var previousLogger zerolog.Logger
+
+	BeforeEach(func() {
+		logs = &bytes.Buffer{}
+		previousLogger = ZeroLogger
+		ZeroLogger = zerolog.New(logs)
+	})
+
+	AfterEach(func() {
+		ZeroLogger = previousLogger
+	})
+
+	It("should keep the X-Request-ID of the client and tag the logs and errors with it", func() {
+		var body string
+		app := fiber.New()
+		app.Use(RequestIDMiddleware)
+		app.Get("/api/things", func(c *fiber.Ctx) error {
+			status, msg := failure(requestContext(c), "", ErrCodeNotFound, "Thing not found", nil)
+			body = msg
+			return c.Status(status).SendString(msg)
+		})
+		request := httptest.NewRequest("GET", "/api/things", nil)
+		request.Header.Set("X-Request-ID", "client-id-1")
+		response, err := app.Test(request)
+		Expect(err).To(BeNil())
+		Expect(response.Header.Get("X-Request-ID")).To(Equal("client-id-1"))
+
+		var decoded errorResponse
+		Expect(json.Unmarshal([]byte(body), &decoded)).To(Succeed())
+		Expect(decoded.RequestID).To(Equal("client-id-1"))
+		lines := bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n"))
+		Expect(lines).To(HaveLen(2))
+		for _, line := range lines {
+			Expect(string(line)).To(ContainSubstring(`"request_id":"client-id-1"`))
+		}
+	})
+
+	It("should generate an id when the client sends none or an unsafe one", func() {
+		app := fiber.New()
+		app.Use(RequestIDMiddleware)
+		app.Get("/api/things", func(c *fiber.Ctx) error {
+			return c.SendString(requestIDOf(c))
+		})
+		request := httptest.NewRequest("GET", "/api/things", nil)
+		request.Header.Set("X-Request-ID", "bad id\nwith newline")
+		response, err := app.Test(request)
+		Expect(err).To(BeNil())
+		Expect(response.Header.Get("X-Request-ID")).To(MatchRegexp("^[0-9a-f]{8}$"))
+	})
+})
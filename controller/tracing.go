@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
+)
+
+// Locals key of the context carrying the span, request id and logger of the request
+const requestContextKey = "request_context"
+
+// reads and writes the trace headers of a fiber request
+type fiberHeaderCarrier struct {
+	c *fiber.Ctx
+}
+
+func (carrier fiberHeaderCarrier) Get(key string) string {
+	return carrier.c.Get(key)
+}
+
+func (carrier fiberHeaderCarrier) Set(key string, value string) {
+	carrier.c.Set(key, value)
+}
+
+func (carrier fiberHeaderCarrier) Keys() []string {
+	var keys []string
+	carrier.c.Request().Header.VisitAll(func(key []byte, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// opens a server span for every request, child of the trace sent in the traceparent header
+// when there is one, the handlers read it back with requestContext
+func TracingMiddleware(c *fiber.Ctx) error {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), fiberHeaderCarrier{c})
+	ctx, span := Tracer.Start(ctx, c.Method()+" "+c.Path(), trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPMethodKey.String(c.Method()), semconv.HTTPTargetKey.String(c.OriginalURL())))
+	defer span.End()
+	c.Locals(requestContextKey, ctx)
+	err := c.Next()
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		if fiberError, ok := err.(*fiber.Error); ok {
+			status = fiberError.Code
+		}
+		span.RecordError(err)
+	}
+	span.SetName(c.Method() + " " + c.Route().Path)
+	span.SetAttributes(semconv.HTTPRouteKey.String(c.Route().Path), semconv.HTTPStatusCodeKey.Int(status))
+	if status >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, strconv.Itoa(status))
+	}
+	return err
+}
+
+type localsContext interface {
+	Locals(key string, value ...interface{}) interface{}
+}
+
+// context carrying the span, request id and logger of the request, the background context
+// outside TracingMiddleware and RequestIDMiddleware
+func requestContext(c localsContext) context.Context {
+	if ctx, ok := c.Locals(requestContextKey).(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
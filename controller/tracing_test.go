@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http/httptest"
+)
+
+var _ = Describe("Tracing", func() {
+	var recorder *tracetest.SpanRecorder
+	var previousTracer trace.Tracer
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		previousTracer = services.Tracer
+		services.Tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+
+	AfterEach(func() {
+		services.Tracer = previousTracer
+	})
+
+	It("should continue the trace of the traceparent header and name the span after the route", func() {
+		var handlerSpan trace.SpanContext
+		app := fiber.New()
+		app.Use(TracingMiddleware)
+		app.Get("/api/things/:id", func(c *fiber.Ctx) error {
+			handlerSpan = trace.SpanContextFromContext(requestContext(c))
+			return c.Status(502).SendString("upstream")
+		})
+		request := httptest.NewRequest("GET", "/api/things/42", nil)
+		request.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+		_, err := app.Test(request)
+		Expect(err).To(BeNil())
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name()).To(Equal("GET /api/things/:id"))
+		Expect(spans[0].SpanKind()).To(Equal(trace.SpanKindServer))
+		Expect(spans[0].SpanContext().TraceID().String()).To(Equal("0af7651916cd43dd8448eb211c80319c"))
+		Expect(spans[0].Parent().SpanID().String()).To(Equal("b7ad6b7169203331"))
+		Expect(spans[0].Status().Code).To(Equal(codes.Error))
+		Expect(handlerSpan.SpanID()).To(Equal(spans[0].SpanContext().SpanID()))
+	})
+
+	It("should fall back to the background context outside the middleware", func() {
+		Expect(trace.SpanContextFromContext(requestContext(contextMock{})).IsValid()).To(BeFalse())
+	})
+})
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+)
+
+func (controller controllerImplementation) GitHubWebhook(c webhookContextInterface) (int, string) {
+	body := c.Body()
+	if !VerifyGitHubSignature(Config.GitHub.WebhookSecret, body, c.Get("X-Hub-Signature-256")) {
+		return failure(requestContext(c), c.Get("X-GitHub-Delivery"), ErrCodeUnauthorized, "Invalid signature", nil)
+	}
+	event := c.Get("X-GitHub-Event")
+	deliveryID := c.Get("X-GitHub-Delivery")
+	// the delivery id is the key that drops the redeliveries, without it a job could run twice
+	if deliveryID == "" {
+		return failure(requestContext(c), "", ErrCodeInvalidInput, "Missing X-GitHub-Delivery header", nil)
+	}
+	host := c.Get("X-GitHub-Enterprise-Host")
+	Logger(requestContext(c)).Info().Msgf("Received %s webhook, delivery %s", event, deliveryID)
+
+	var job *Job
+	var statusCode int
+	var msg string
+	switch event {
+	case "ping":
+		return 200, "pong"
+	case "push":
+		job, statusCode, msg = pushJob(requestContext(c), body, host)
+	case "pull_request":
+		job, statusCode, msg = pullRequestJob(requestContext(c), body, host)
+	case "issue_comment":
+		job, statusCode, msg = issueCommentJob(requestContext(c), body, host)
+	default:
+		return 202, fmt.Sprintf("Event %s ignored", event)
+	}
+	if job == nil {
+		return statusCode, msg
+	}
+	if _, err := GitHubHostService(requestContext(c), host); err != nil {
+		return failure(requestContext(c), deliveryID, ErrCodeInvalidInput, "Error in data, please review input data", err)
+	}
+
+	if WebhookDeliveries.Seen(deliveryID) {
+		Logger(requestContext(c)).Info().Msgf("Delivery %s was already processed", deliveryID)
+		return 200, "Delivery already processed"
+	}
+	job.ID = deliveryID
+	job.RequestID = requestIDOf(c)
+	if err := JobQueue.Enqueue(*job); err != nil {
+		WebhookDeliveries.Forget(deliveryID)
+		return failure(requestContext(c), deliveryID, ErrCodeUnavailable, fmt.Sprintf("Cannot enqueue %s", job.Name), err)
+	}
+	return statusCode, msg
+}
+
+func pushJob(ctx context.Context, body []byte, host string) (*Job, int, string) {
+	push := new(pushEventParams)
+	if err := json.Unmarshal(body, push); err != nil {
+		statusCode, msg := failure(ctx, "", ErrCodeInvalidInput, "Error in data, please review input data", err)
+		return nil, statusCode, msg
+	}
+	if push.Deleted || push.Ref != fmt.Sprintf("refs/heads/%s", push.Repository.DefaultBranch) {
+		return nil, 202, "Push is not to the default branch, ignored"
+	}
+	owner := push.Repository.Owner.Login
+	if owner == "" {
+		owner = push.Repository.Owner.Name
+	}
+	repo := push.Repository.Name
+	job := newJob(jobKindRefreshBaseline, repoJobParams{Host: host, Owner: owner, Repo: repo})
+	job.Name = fmt.Sprintf("refresh %s of %s/%s", Config.Scanner.SecretsFileName, owner, repo)
+	job.Host = host
+	return &job, 202, "Baseline refresh enqueued"
+}
+
+func pullRequestJob(ctx context.Context, body []byte, host string) (*Job, int, string) {
+	event := new(pullRequestEventParams)
+	if err := json.Unmarshal(body, event); err != nil {
+		statusCode, msg := failure(ctx, "", ErrCodeInvalidInput, "Error in data, please review input data", err)
+		return nil, statusCode, msg
+	}
+	switch event.Action {
+	case "opened", "synchronize", "reopened", "ready_for_review":
+	default:
+		return nil, 202, fmt.Sprintf("Pull request action %s ignored", event.Action)
+	}
+	owner := event.Repository.Owner.Login
+	repo := event.Repository.Name
+	number := event.Number
+	headSHA := event.PullRequest.Head.SHA
+	baseSHA := event.PullRequest.Base.SHA
+	job := newJob(jobKindCheckPullRequest, repoJobParams{Host: host, Owner: owner, Repo: repo, Number: number, HeadSHA: headSHA, BaseSHA: baseSHA})
+	job.Name = fmt.Sprintf("check secrets of %s/%s#%d at %s", owner, repo, number, headSHA)
+	job.Host = host
+	return &job, 202, "Pull request check enqueued"
+}
+
+// scans the default branch and, when it has secrets missing from the baseline, opens a
+// PR creating or updating the secrets file. A PR left open by an earlier push gets the new
+// commit on its branch instead
+func refreshBaseline(ctx context.Context, host string, owner string, repo string) error {
+	gitService, err := GitHubHostService(ctx, host)
+	if err != nil {
+		return err
+	}
+	_, path, err := gitService.CloneRepo(owner, repo)
+	if err != nil {
+		return err
+	}
+	defer removeClone(ctx, path)
+	scan, err := ThirdPartyScanner.Scan(path)
+	if err != nil {
+		return fmt.Errorf("scan of %s/%s failed: %v", owner, repo, err)
+	}
+	baseline, err := ReadBaseline(path)
+	if err != nil {
+		return err
+	}
+	newSecrets, err := NewSecrets(baseline, scan)
+	if err != nil {
+		return err
+	}
+	if len(newSecrets) == 0 {
+		Logger(ctx).Info().Msgf("No new secrets found in %s/%s", owner, repo)
+		return nil
+	}
+	content, err := MergeBaseline(baseline, scan)
+	if err != nil {
+		return err
+	}
+
+	action := "Update"
+	description := fmt.Sprintf("Updated %s file, new secrets were found in %d files after a push to the default branch.", Config.Scanner.SecretsFileName, len(newSecrets))
+	if baseline == nil {
+		action = "Create"
+		description = fmt.Sprintf("Created and added %s file, the bot scanned the repo after a push to the default branch "+
+			"and placed the secrets it found in %s file.", Config.Scanner.SecretsFileName, Config.Scanner.SecretsFileName)
+	}
+	data := &createParams{Repo: repo, Owner: owner, Content: string(content), Provider: GitHubProvider, Host: host, RequestedBy: "github-webhook"}
+	pull, found, err := gitService.OpenBaselinePullRequest(owner, repo)
+	if err != nil {
+		return err
+	}
+	if found {
+		reusePullRequest(ctx, pipelineKey(action, data.RequestedBy, data), pull)
+	}
+	status, msg := createBaseline(ctx, data, action, description)
+	if status >= 300 {
+		return failureError(msg)
+	}
+	return nil
+}
+
+// scans the files changed by the pull request and reports the secrets that are not audited
+// in the base branch baseline as a check run on the head commit and as review comments
+func checkPullRequest(ctx context.Context, host string, owner string, repo string, number int, headSHA string, baseSHA string) error {
+	ctx = WithLogFields(ctx, "owner", owner, "repo", repo, "action", "Check")
+	gitService, err := GitHubHostService(ctx, host)
+	if err != nil {
+		return err
+	}
+	files, err := gitService.PullRequestFiles(owner, repo, number)
+	if err != nil {
+		return err
+	}
+	scan := []byte(nil)
+	if len(files) > 0 {
+		repoGit, path, err := gitService.CloneRepo(owner, repo)
+		if err != nil {
+			return err
+		}
+		defer removeClone(ctx, path)
+		if err := gitService.CheckoutPullRequest(repoGit, number, headSHA); err != nil {
+			return err
+		}
+		scan, err = ThirdPartyScanner.Scan(path, files...)
+		if err != nil {
+			return fmt.Errorf("scan of %s/%s#%d failed: %v", owner, repo, number, err)
+		}
+	}
+	baseline, err := gitService.FileContent(owner, repo, Config.Scanner.SecretsFileName, baseSHA)
+	if err != nil {
+		return err
+	}
+	unaudited, err := UnauditedSecrets(baseline, scan)
+	if err != nil {
+		return err
+	}
+	if err := gitService.CreateCheckRun(owner, repo, headSHA, unaudited); err != nil {
+		return err
+	}
+	return gitService.SyncSecretReviewComments(owner, repo, number, headSHA, unaudited)
+}
@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/go-git/go-git/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+func signedWebhook(event string, deliveryID string, body string) webhookContextMock {
+	mac := hmac.New(sha256.New, []byte("webhook-secret"))
+	mac.Write([]byte(body))
+	return webhookContextMock{
+		BodyHandler: func() []byte { return []byte(body) },
+		Headers: map[string]string{
+			"X-GitHub-Event":      event,
+			"X-GitHub-Delivery":   deliveryID,
+			"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+		},
+	}
+}
+
+const pushToMain = `{"ref": "refs/heads/main", "after": "abc", "repository": {"name": "repo", "default_branch": "main", "owner": {"login": "john"}}}`
+
+var _ = Describe("Webhooks", func() {
+	var enqueued []services.Job
+
+	BeforeEach(func() {
+		enqueued = nil
+		services.Config.GitHub.WebhookSecret = "webhook-secret"
+		services.WebhookDeliveries = services.NewDeliveryStore(time.Hour)
+		services.JobQueue = jobQueueMock{
+			EnqueueHandler: func(job services.Job) error {
+				enqueued = append(enqueued, job)
+				return nil
+			},
+			DepthHandler: func() int { return len(enqueued) },
+		}
+	})
+
+	Context("GitHubWebhook controller is triggered", func() {
+		It("should enqueue a baseline refresh for a push to the default branch", func() {
+			statusCode, msg := ControllerObject.GitHubWebhook(signedWebhook("push", "delivery-1", pushToMain))
+			Expect(statusCode).To(Equal(202))
+			Expect(msg).To(Equal("Baseline refresh enqueued"))
+			Expect(enqueued).To(HaveLen(1))
+			Expect(enqueued[0].ID).To(Equal("delivery-1"))
+			Expect(enqueued[0].Name).To(ContainSubstring("john/repo"))
+		})
+
+		It("should reject deliveries with an invalid signature", func() {
+			webhook := signedWebhook("push", "delivery-1", pushToMain)
+			webhook.Headers["X-Hub-Signature-256"] = "sha256=00"
+			statusCode, _ := ControllerObject.GitHubWebhook(webhook)
+			Expect(statusCode).To(Equal(401))
+			Expect(enqueued).To(BeEmpty())
+		})
+
+		It("should reject deliveries without a delivery id", func() {
+			statusCode, msg := ControllerObject.GitHubWebhook(signedWebhook("push", "", pushToMain))
+			Expect(statusCode).To(Equal(400))
+			Expect(msg).To(ContainSubstring("Missing X-GitHub-Delivery header"))
+			Expect(enqueued).To(BeEmpty())
+		})
+
+		It("should answer pings and ignore other events", func() {
+			statusCode, msg := ControllerObject.GitHubWebhook(signedWebhook("ping", "delivery-1", `{}`))
+			Expect(statusCode).To(Equal(200))
+			Expect(msg).To(Equal("pong"))
+			statusCode, _ = ControllerObject.GitHubWebhook(signedWebhook("issues", "delivery-2", `{}`))
+			Expect(statusCode).To(Equal(202))
+			Expect(enqueued).To(BeEmpty())
+		})
+
+		It("should ignore pushes to other branches", func() {
+			body := `{"ref": "refs/heads/feature", "repository": {"name": "repo", "default_branch": "main", "owner": {"login": "john"}}}`
+			statusCode, msg := ControllerObject.GitHubWebhook(signedWebhook("push", "delivery-1", body))
+			Expect(statusCode).To(Equal(202))
+			Expect(msg).To(ContainSubstring("not to the default branch"))
+			Expect(enqueued).To(BeEmpty())
+		})
+
+		It("should process a delivery only once", func() {
+			ControllerObject.GitHubWebhook(signedWebhook("push", "delivery-1", pushToMain))
+			statusCode, msg := ControllerObject.GitHubWebhook(signedWebhook("push", "delivery-1", pushToMain))
+			Expect(statusCode).To(Equal(200))
+			Expect(msg).To(Equal("Delivery already processed"))
+			Expect(enqueued).To(HaveLen(1))
+		})
+
+		It("should enqueue a check for opened and updated pull requests", func() {
+			body := `{"action": "synchronize", "number": 7, "pull_request": {"head": {"sha": "head"}, "base": {"sha": "base", "ref": "main"}}, "repository": {"name": "repo", "owner": {"login": "john"}}}`
+			statusCode, msg := ControllerObject.GitHubWebhook(signedWebhook("pull_request", "delivery-1", body))
+			Expect(statusCode).To(Equal(202))
+			Expect(msg).To(Equal("Pull request check enqueued"))
+			Expect(enqueued).To(HaveLen(1))
+			Expect(enqueued[0].Name).To(ContainSubstring("john/repo#7 at head"))
+		})
+
+		It("should ignore closed pull requests", func() {
+			body := `{"action": "closed", "number": 7, "repository": {"name": "repo", "owner": {"login": "john"}}}`
+			statusCode, _ := ControllerObject.GitHubWebhook(signedWebhook("pull_request", "delivery-1", body))
+			Expect(statusCode).To(Equal(202))
+			Expect(enqueued).To(BeEmpty())
+		})
+
+		It("should allow a redelivery when the queue was full", func() {
+			services.JobQueue = jobQueueMock{
+				EnqueueHandler: func(services.Job) error { return services.ErrQueueFull },
+			}
+			statusCode, _ := ControllerObject.GitHubWebhook(signedWebhook("push", "delivery-1", pushToMain))
+			Expect(statusCode).To(Equal(503))
+			Expect(services.WebhookDeliveries.Seen("delivery-1")).To(BeFalse())
+		})
+	})
+
+	Context("the baseline refresh job runs", func() {
+		var path string
+		var content, action string
+		gitService := gitServiceMock{}
+		scan := `{"results": {"config.py": [{"type": "Secret Keyword", "hashed_secret": "aaa", "line_number": 3}]}}`
+
+		BeforeEach(func() {
+			var err error
+			path, err = ioutil.TempDir("", "refresh")
+			Expect(err).To(BeNil())
+			content, action = "", ""
+			gitService.CheckUserAccessRepoHandler = func(string, string) error { return nil }
+			gitService.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
+				return "bot", "http://github.com/bot/repo", nil
+			}
+			gitService.CheckForkedRepoHandler = func(string, string) error { return nil }
+			gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
+				return new(git.Repository), path, nil
+			}
+			gitService.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
+				return "branch", "main", nil
+			}
+			gitService.CreateSecretFileHandler = func(_ string, secretFile string) error {
+				content = secretFile
+				return nil
+			}
+			gitService.CommitAndPushHandler = func(string, string, string, string, string, *git.Repository) error {
+				return nil
+			}
+			gitService.OpenPullRequestHandler = func(_ string, _ string, _ string, _ string, _ string, prAction string, _ string) (int, error) {
+				action = prAction
+				return 1, nil
+			}
+			gitService.OpenBaselinePullRequestHandler = func(string, string) (services.BaselinePullRequest, bool, error) {
+				return services.BaselinePullRequest{}, false, nil
+			}
+			services.GitServiceObject = gitService
+			services.ThirdPartyScanner = scannerMock{ScanHandler: func(string, ...string) ([]byte, error) {
+				return []byte(scan), nil
+			}}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(path)
+		})
+
+		It("should create the secrets file when the repo has none", func() {
+			Expect(refreshBaseline(context.Background(), "", "john", "repo")).To(Succeed())
+			Expect(action).To(Equal("Create"))
+			Expect(content).To(ContainSubstring(`"hashed_secret": "aaa"`))
+		})
+
+		It("should update the secrets file when it misses secrets", func() {
+			baseline := `{"results": {"config.py": [{"type": "Secret Keyword", "hashed_secret": "zzz", "line_number": 1, "is_secret": false}]}}`
+			Expect(ioutil.WriteFile(path+"/"+services.Config.Scanner.SecretsFileName, []byte(baseline), 0644)).To(Succeed())
+			Expect(refreshBaseline(context.Background(), "", "john", "repo")).To(Succeed())
+			Expect(action).To(Equal("Update"))
+		})
+
+		It("should do nothing when every secret is already in the baseline", func() {
+			Expect(ioutil.WriteFile(path+"/"+services.Config.Scanner.SecretsFileName, []byte(scan), 0644)).To(Succeed())
+			Expect(refreshBaseline(context.Background(), "", "john", "repo")).To(Succeed())
+			Expect(action).To(Equal(""))
+		})
+
+		It("should push the new secrets to the open baseline PR instead of opening another", func() {
+			var checkedOut, head string
+			reusing := gitService
+			reusing.OpenBaselinePullRequestHandler = func(string, string) (services.BaselinePullRequest, bool, error) {
+				return services.BaselinePullRequest{Number: 4, HeadOwner: "bot", HeadBranch: "secrets-branch", BaseBranch: "main"}, true, nil
+			}
+			reusing.ForkRepoHandler = func(string, string) (interface{}, interface{}, error) {
+				Fail("the fork of the open PR must be reused")
+				return nil, nil, nil
+			}
+			reusing.CreateBranchRepoHandler = func(*git.Repository, string, string, string, string) (string, string, error) {
+				Fail("the branch of the open PR must be reused")
+				return "", "", nil
+			}
+			reusing.CheckoutBranchHandler = func(_ *git.Repository, branch string) error {
+				checkedOut = branch
+				return nil
+			}
+			reusing.OpenPullRequestHandler = func(_ string, _ string, _ string, currentBranch string, _ string, _ string, _ string) (int, error) {
+				head = currentBranch
+				return 4, services.ErrPullRequestUpdated
+			}
+			services.GitServiceObject = reusing
+			Expect(refreshBaseline(context.Background(), "", "john", "repo")).To(Succeed())
+			Expect(checkedOut).To(Equal("secrets-branch"))
+			Expect(head).To(Equal("secrets-branch"))
+			Expect(content).To(ContainSubstring(`"hashed_secret": "aaa"`))
+		})
+
+		It("should return the scanner error", func() {
+			services.ThirdPartyScanner = scannerMock{ScanHandler: func(string, ...string) ([]byte, error) {
+				return nil, errors.New("detect-secrets not found")
+			}}
+			err := refreshBaseline(context.Background(), "", "john", "repo")
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("detect-secrets not found"))
+		})
+	})
+
+	Context("the pull request check job runs", func() {
+		var reported SecretUpdateMap
+		var scannedFiles []string
+		var commented SecretUpdateMap
+		var checkedOut string
+		gitService := gitServiceMock{}
+
+		BeforeEach(func() {
+			reported, commented, scannedFiles, checkedOut = nil, nil, nil, ""
+			gitService.PullRequestFilesHandler = func(string, string, int) ([]string, error) {
+				return []string{"config.py"}, nil
+			}
+			gitService.CloneRepoHandler = func(string, string) (*git.Repository, string, error) {
+				return new(git.Repository), "path", nil
+			}
+			gitService.CheckoutPullRequestHandler = func(_ *git.Repository, _ int, headSHA string) error {
+				checkedOut = headSHA
+				return nil
+			}
+			gitService.FileContentHandler = func(_ string, _ string, _ string, ref string) ([]byte, error) {
+				Expect(ref).To(Equal("base"))
+				return []byte(`{"results": {"config.py": [{"hashed_secret": "audited", "is_secret": false}]}}`), nil
+			}
+			gitService.CreateCheckRunHandler = func(_ string, _ string, _ string, unaudited SecretUpdateMap) error {
+				reported = unaudited
+				return nil
+			}
+			gitService.SyncSecretReviewCommentsHandler = func(_ string, _ string, number int, headSHA string, unaudited SecretUpdateMap) error {
+				Expect(number).To(Equal(7))
+				Expect(headSHA).To(Equal("head"))
+				commented = unaudited
+				return nil
+			}
+			services.GitServiceObject = gitService
+			services.ThirdPartyScanner = scannerMock{ScanHandler: func(_ string, files ...string) ([]byte, error) {
+				scannedFiles = files
+				return []byte(`{"results": {"config.py": [{"hashed_secret": "audited"}, {"hashed_secret": "new", "line_number": 4}]}}`), nil
+			}}
+		})
+
+		It("should report only the secrets not audited in the base baseline", func() {
+			Expect(checkPullRequest(context.Background(), "", "john", "repo", 7, "head", "base")).To(Succeed())
+			Expect(checkedOut).To(Equal("head"))
+			Expect(scannedFiles).To(Equal([]string{"config.py"}))
+			Expect(reported["config.py"]).To(HaveLen(1))
+			Expect(reported["config.py"][0]["hashed_secret"]).To(Equal("new"))
+			Expect(commented).To(Equal(reported))
+		})
+
+		It("should pass without scanning when no files changed", func() {
+			gitService.PullRequestFilesHandler = func(string, string, int) ([]string, error) {
+				return nil, nil
+			}
+			services.GitServiceObject = gitService
+			Expect(checkPullRequest(context.Background(), "", "john", "repo", 7, "head", "base")).To(Succeed())
+			Expect(scannedFiles).To(BeNil())
+			Expect(reported).To(BeEmpty())
+		})
+	})
+})
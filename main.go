@@ -1,17 +1,139 @@
 package main
 
 import (
-	//"github.com/eliezer-borde-globant/EBGoProject/controller"
-	//. "github.com/eliezer-borde-globant/EBGoProject/controller"
+	"context"
+	"github.com/eliezer-borde-globant/EBGoProject/config"
+	"github.com/eliezer-borde-globant/EBGoProject/controller"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
+	"github.com/eliezer-borde-globant/EBGoProject/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	app := fiber.New()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		utils.ZeroLogger.Fatal().Msgf("Cannot load the configuration: %v", err)
+	}
+	if err := utils.ConfigureLogger(cfg.Logging.Level, cfg.Logging.Format); err != nil {
+		utils.ZeroLogger.Fatal().Msgf("Cannot configure the logger: %v", err)
+	}
+	if err := buildServices(cfg); err != nil {
+		utils.ZeroLogger.Fatal().Msgf("Cannot open the %s history database: %v", cfg.Database.Driver, err)
+	}
+	if cfg.Auth.Disabled {
+		utils.ZeroLogger.Warn().Msg("Authentication is disabled, anyone reaching the API can use it")
+	}
+	shutdownTracing, err := services.InitTracing(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.ServiceName)
+	if err != nil {
+		utils.ZeroLogger.Fatal().Msgf("Cannot start the %s trace exporter: %v", cfg.Tracing.Exporter, err)
+	}
+	defer shutdownTracing(context.Background())
+
+	app := fiber.New(fiber.Config{
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	})
 	app.Use(logger.New())
-	app.Static("/", "./public")
-	app.Post("/api/detectsecrets/update", nil)
-	app.Post("/api/detectsecrets/create", nil)
-	app.Listen(":3000")
+	app.Use(controller.MetricsMiddleware)
+	app.Use(controller.TracingMiddleware)
+	app.Use(controller.RequestIDMiddleware)
+	app.Static("/", cfg.Server.PublicDir)
+	viewer, auditor, admin := controller.RequireRole(utils.RoleViewer), controller.RequireRole(utils.RoleAuditor), controller.RequireRole(utils.RoleAdmin)
+	app.Post("/api/detectsecrets/update", auditor, controller.ValidateBody("UpdateRequest"), controller.IdempotencyMiddleware, controller.UpdateSecretFileHandler)
+	app.Post("/api/detectsecrets/create", admin, controller.ValidateBody("CreateRequest"), controller.IdempotencyMiddleware, controller.CreateSecretFileHandler)
+	app.Post("/api/detectsecrets/orgs/:org/create", admin, controller.ValidateBody("OrgCreateRequest"), controller.IdempotencyMiddleware, controller.CreateOrgSecretFilesHandler)
+	app.Get("/api/detectsecrets/orgs/:org/create/:id", viewer, controller.OrgRolloutReportHandler)
+	app.Get("/api/detectsecrets/audits", auditor, controller.AuditReportHandler)
+	app.Get("/api/detectsecrets/:owner/:repo/history", viewer, controller.RepoHistoryHandler)
+	app.Get("/api/openapi.json", viewer, controller.OpenAPIHandler)
+	app.Get("/metrics", viewer, controller.MetricsHandler)
+	app.Get("/healthz", controller.HealthzHandler)
+	app.Get("/readyz", controller.ReadyzHandler)
+	app.Post("/api/webhooks/github", controller.GitHubWebhookHandler)
+
+	// the saved jobs the queue has no room for stay in the file and are saved again at shutdown
+	var leftover []services.Job
+	pending, err := services.LoadPendingJobs(cfg.Shutdown.PendingJobsFile)
+	if err != nil {
+		utils.ZeroLogger.Error().Msgf("Cannot read the jobs left by the last shutdown: %v", err)
+	} else {
+		leftover = controller.ResumeJobs(pending)
+		if err := services.SavePendingJobs(cfg.Shutdown.PendingJobsFile, leftover); err != nil {
+			utils.ZeroLogger.Error().Msgf("Cannot save %d jobs left to resume: %v", len(leftover), err)
+		}
+	}
+
+	listening := make(chan error, 1)
+	go func() {
+		if cfg.Server.TLS.Enabled() {
+			listening <- app.ListenTLS(cfg.Server.ListenAddress, cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+			return
+		}
+		listening <- app.Listen(cfg.Server.ListenAddress)
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err := <-listening:
+		utils.ZeroLogger.Fatal().Msgf("Cannot listen on %s: %v", cfg.Server.ListenAddress, err)
+	case received := <-signals:
+		utils.ZeroLogger.Info().Msgf("Received %s, shutting down", received)
+	}
+	shutdown(app, cfg.Shutdown, leftover)
+}
+
+// hands cfg to the services and the controller and builds the services keeping state from it,
+// once and before the server starts
+func buildServices(cfg *config.Config) error {
+	services.Config = cfg
+	history, err := services.OpenHistory(cfg.Database.Driver, cfg.Database.DSN)
+	if err != nil {
+		return err
+	}
+	services.History = history
+	services.JobQueue = services.NewJobQueue(cfg.Workers.JobQueueSize, cfg.Workers.JobWorkers)
+	services.Health = services.NewHealthChecker()
+	services.IdempotencyKeys = services.NewIdempotencyStore(time.Duration(cfg.Timeouts.IdempotencyRetentionHrs)*time.Hour, cfg.Timeouts.RequestLease)
+	services.Auth = services.NewAuthenticator(cfg.Auth.APIKeys, cfg.Auth.OIDC)
+	return nil
+}
+
+// stops accepting connections and jobs, gives the running requests and jobs until the
+// shutdown timeout, saves the jobs that did not finish with the leftover ones not resumed at
+// start and removes the clones left on disk once the workers have stopped
+func shutdown(app *fiber.App, cfg config.ShutdownConfig, leftover []services.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- app.Shutdown()
+	}()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			utils.ZeroLogger.Error().Msgf("Error stopping the server: %v", err)
+		}
+	case <-ctx.Done():
+		utils.ZeroLogger.Warn().Msgf("Requests still running after %s", cfg.Timeout)
+	}
+
+	unfinished, err := services.JobQueue.Shutdown(ctx)
+	if err != nil {
+		utils.ZeroLogger.Warn().Msgf("Jobs still running after %s were interrupted", cfg.Timeout)
+	}
+	unfinished = append(unfinished, leftover...)
+	if err := services.SavePendingJobs(cfg.PendingJobsFile, unfinished); err != nil {
+		utils.ZeroLogger.Error().Msgf("Cannot save %d unfinished jobs: %v", len(unfinished), err)
+	} else if len(unfinished) > 0 {
+		utils.ZeroLogger.Info().Msgf("Saved %d unfinished jobs to %s", len(unfinished), cfg.PendingJobsFile)
+	}
+	if err := services.Clones.RemoveAll(); err != nil {
+		utils.ZeroLogger.Error().Msgf("Cannot remove the work directories: %v", err)
+	}
 }
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"io/ioutil"
+	"os"
+)
+
+// content of the sidecar file kept next to the secrets file
+type auditFileParams struct {
+	Audits []AuditDecision `json:"audits"`
+}
+
+// appends the decisions to the sidecar audit file of the clone, creating it when missing
+func (workspace gitWorkspaceImplementation) WriteAuditFile(path string, decisions []AuditDecision) error {
+	path = fmt.Sprintf("%s/%s", path, AuditFileName)
+	auditFile := auditFileParams{Audits: []AuditDecision{}}
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, &auditFile); err != nil {
+			workspace.logger().Error().Msgf("Cannot parse %s: %v", path, err)
+			return err
+		}
+	}
+	auditFile.Audits = append(auditFile.Audits, decisions...)
+	content, err = json.MarshalIndent(auditFile, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		workspace.logger().Error().Msgf("Error writing file: %v", err)
+		return err
+	}
+	return nil
+}
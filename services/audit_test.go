@@ -0,0 +1,23 @@
+package services
+
+import (
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+)
+
+var _ = Describe("Audit file", func() {
+	It("appends the decisions to the sidecar file of the clone", func() {
+		path, err := ioutil.TempDir("", "audit")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(path)
+		workspace := gitWorkspaceImplementation{}
+		Expect(workspace.WriteAuditFile(path, []AuditDecision{{HashedSecret: "aaa", Justification: "fixture"}})).To(Succeed())
+		Expect(workspace.WriteAuditFile(path, []AuditDecision{{HashedSecret: "bbb", IsSecret: true, Justification: "live key"}})).To(Succeed())
+		content, err := ioutil.ReadFile(path + "/" + AuditFileName)
+		Expect(err).To(BeNil())
+		Expect(string(content)).To(MatchRegexp(`(?s)"hashed_secret": "aaa".*"hashed_secret": "bbb".*"justification": "live key"`))
+	})
+})
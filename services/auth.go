@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"strings"
+)
+
+const (
+	AuthMethodAPIKey = "api_key"
+	AuthMethodOIDC   = "oidc"
+	// GitHub user of a signed webhook, like the author of a comment
+	AuthMethodGitHub = "github"
+)
+
+var ErrUnauthenticated = NewServiceError(ErrCodeUnauthorized, "missing or invalid credentials", nil)
+
+// who sent a request and what it may do
+type Identity struct {
+	Name   string
+	Method string
+	Role   string
+	Orgs   []string
+}
+
+// the identity has role or one above it
+func (identity Identity) HasRole(role string) bool {
+	return RoleIncludes(identity.Role, role)
+}
+
+func (identity Identity) AllowsOrg(org string) bool {
+	for _, allowed := range identity.Orgs {
+		if allowed == AllOrgs || strings.EqualFold(allowed, org) {
+			return true
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// identity of the request in ctx, false for the background jobs and when authentication is off
+func IdentityFrom(ctx context.Context) (Identity, bool) {
+	if ctx == nil {
+		return Identity{}, false
+	}
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+type authenticatorInterface interface {
+	Enabled() bool
+	Authenticate(apiKey string, bearerToken string) (Identity, error)
+}
+
+type authenticatorImplementation struct {
+	apiKeys  []APIKey
+	provider OIDCProvider
+	verifier *oidcVerifier
+}
+
+// authenticates with the API keys and the bearer tokens of provider, authentication is off
+// when there are no keys and no issuer
+func NewAuthenticator(apiKeys []APIKey, provider OIDCProvider) authenticatorInterface {
+	auth := authenticatorImplementation{apiKeys: apiKeys, provider: provider}
+	if provider.Issuer != "" {
+		auth.verifier = newOIDCVerifier(provider.Issuer, provider.Audience)
+	}
+	return auth
+}
+
+func (auth authenticatorImplementation) Enabled() bool {
+	return len(auth.apiKeys) > 0 || auth.verifier != nil
+}
+
+// identity of the API key, or else of the bearer token. An identity without a role is valid
+// but is not allowed on any route
+func (auth authenticatorImplementation) Authenticate(apiKey string, bearerToken string) (Identity, error) {
+	if apiKey != "" {
+		digest := sha256.Sum256([]byte(apiKey))
+		for _, key := range auth.apiKeys {
+			expected := sha256.Sum256([]byte(key.Key))
+			if subtle.ConstantTimeCompare(digest[:], expected[:]) == 1 {
+				return Identity{Name: key.Name, Method: AuthMethodAPIKey, Role: key.Role, Orgs: key.Orgs}, nil
+			}
+		}
+		return Identity{}, ErrUnauthenticated
+	}
+	if bearerToken == "" || auth.verifier == nil {
+		return Identity{}, ErrUnauthenticated
+	}
+	claims, err := auth.verifier.Verify(bearerToken)
+	if err != nil {
+		return Identity{}, NewServiceError(ErrCodeUnauthorized, "invalid bearer token", err)
+	}
+	// the name is what the audit trail and the GitHub permission checks use
+	name, _ := claims[auth.provider.UsernameClaim].(string)
+	if name == "" {
+		return Identity{}, NewServiceError(ErrCodeUnauthorized, fmt.Sprintf("bearer token has no %s claim", auth.provider.UsernameClaim), nil)
+	}
+	identity := Identity{Name: name, Method: AuthMethodOIDC}
+	for _, group := range stringsClaim(claims[auth.provider.GroupsClaim]) {
+		grant, ok := auth.provider.Groups[group]
+		if !ok {
+			continue
+		}
+		if ValidRole(grant.Role) && !RoleIncludes(identity.Role, grant.Role) {
+			identity.Role = grant.Role
+		}
+		identity.Orgs = append(identity.Orgs, grant.Orgs...)
+	}
+	return identity, nil
+}
+
+// claims listing values are arrays, some issuers send a single value as a string
+func stringsClaim(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			if text, ok := item.(string); ok {
+				values = append(values, text)
+			}
+		}
+		return values
+	}
+	return nil
+}
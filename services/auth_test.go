@@ -0,0 +1,153 @@
+package services
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func signToken(key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	encode := func(value interface{}) string {
+		content, _ := json.Marshal(value)
+		return base64.RawURLEncoding.EncodeToString(content)
+	}
+	signed := encode(map[string]string{"alg": "RS256", "kid": kid}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	Expect(err).To(BeNil())
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+var _ = Describe("Auth", func() {
+	It("ranks the roles and matches the org allow-list", func() {
+		auditor := Identity{Role: RoleAuditor, Orgs: []string{"Acme"}}
+		Expect(auditor.HasRole(RoleViewer)).To(BeTrue())
+		Expect(auditor.HasRole(RoleAuditor)).To(BeTrue())
+		Expect(auditor.HasRole(RoleAdmin)).To(BeFalse())
+		Expect(Identity{}.HasRole(RoleViewer)).To(BeFalse())
+		Expect(auditor.AllowsOrg("acme")).To(BeTrue())
+		Expect(auditor.AllowsOrg("other")).To(BeFalse())
+		Expect(Identity{Orgs: []string{AllOrgs}}.AllowsOrg("other")).To(BeTrue())
+	})
+
+	It("is off until an API key or an issuer is configured", func() {
+		Expect(NewAuthenticator(nil, OIDCProvider{}).Enabled()).To(BeFalse())
+		Expect(NewAuthenticator([]APIKey{{Name: "ci", Key: "secret"}}, OIDCProvider{}).Enabled()).To(BeTrue())
+	})
+
+	It("authenticates the API keys", func() {
+		auth := NewAuthenticator([]APIKey{{Name: "ci", Key: "0123456789abcdef", RoleGrant: RoleGrant{Role: RoleAdmin, Orgs: []string{AllOrgs}}}}, OIDCProvider{})
+		identity, err := auth.Authenticate("0123456789abcdef", "")
+		Expect(err).To(BeNil())
+		Expect(identity).To(Equal(Identity{Name: "ci", Method: AuthMethodAPIKey, Role: RoleAdmin, Orgs: []string{AllOrgs}}))
+		_, err = auth.Authenticate("wrong", "")
+		Expect(err).To(Equal(ErrUnauthenticated))
+		_, err = auth.Authenticate("", "")
+		Expect(err).To(Equal(ErrUnauthenticated))
+	})
+
+	Context("with an OIDC issuer", func() {
+		var server *httptest.Server
+		var key *rsa.PrivateKey
+		var auth authenticatorInterface
+		var claims map[string]interface{}
+
+		BeforeEach(func() {
+			var err error
+			key, err = rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).To(BeNil())
+			mux := http.NewServeMux()
+			server = httptest.NewServer(mux)
+			mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/keys"})
+			})
+			mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+					"kty": "RSA", "use": "sig", "kid": "k1",
+					"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				}}})
+			})
+			auth = NewAuthenticator(nil, OIDCProvider{
+				Issuer: server.URL, Audience: "scanner", UsernameClaim: "email", GroupsClaim: "groups",
+				Groups: map[string]RoleGrant{
+					"readers":  {Role: RoleViewer, Orgs: []string{"acme"}},
+					"security": {Role: RoleAuditor, Orgs: []string{"globex"}},
+				},
+			})
+			claims = map[string]interface{}{
+				"iss": server.URL, "aud": []string{"scanner"}, "email": "alice@example.com",
+				"groups": []string{"readers", "security", "unknown"}, "exp": time.Now().Add(time.Hour).Unix(),
+			}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("maps the groups of a valid token to the highest role and every org", func() {
+			identity, err := auth.Authenticate("", signToken(key, "k1", claims))
+			Expect(err).To(BeNil())
+			Expect(identity.Name).To(Equal("alice@example.com"))
+			Expect(identity.Method).To(Equal(AuthMethodOIDC))
+			Expect(identity.Role).To(Equal(RoleAuditor))
+			Expect(identity.Orgs).To(ConsistOf("acme", "globex"))
+		})
+
+		It("rejects expired tokens and tokens for another audience or issuer", func() {
+			for claim, value := range map[string]interface{}{
+				"exp": time.Now().Add(-time.Hour).Unix(),
+				"aud": "other",
+				"iss": "https://issuer.example",
+			} {
+				invalid := map[string]interface{}{}
+				for name, original := range claims {
+					invalid[name] = original
+				}
+				invalid[claim] = value
+				_, err := auth.Authenticate("", signToken(key, "k1", invalid))
+				Expect(err).NotTo(BeNil(), claim)
+			}
+		})
+
+		It("rejects tokens without a username", func() {
+			for _, value := range []interface{}{nil, "", 42} {
+				invalid := map[string]interface{}{}
+				for name, original := range claims {
+					invalid[name] = original
+				}
+				invalid["email"] = value
+				if value == nil {
+					delete(invalid, "email")
+				}
+				_, err := auth.Authenticate("", signToken(key, "k1", invalid))
+				var serviceError *ServiceError
+				Expect(errors.As(err, &serviceError)).To(BeTrue(), fmt.Sprint(value))
+				Expect(serviceError.Code).To(Equal(ErrCodeUnauthorized))
+			}
+		})
+
+		It("rejects tokens signed by another key", func() {
+			other, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).To(BeNil())
+			_, err = auth.Authenticate("", signToken(other, "k1", claims))
+			Expect(err).NotTo(BeNil())
+			_, err = auth.Authenticate("", signToken(key, "k2", claims))
+			Expect(err).NotTo(BeNil())
+			_, err = auth.Authenticate("", "not-a-token")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// audit fields kept from the previous baseline when a new scan replaces it
+var baselineAuditFields = []string{"is_secret", "is_verified"}
+
+// reads the secrets file of a clone, returns nil content when the repo has none yet
+func ReadBaseline(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", path, Config.Scanner.SecretsFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return content, err
+}
+
+func parseBaseline(content []byte) (map[string]interface{}, SecretUpdateMap, error) {
+	fileStruct := map[string]interface{}{}
+	results := SecretUpdateMap{}
+	if len(content) == 0 {
+		return fileStruct, results, nil
+	}
+	if err := json.Unmarshal(content, &fileStruct); err != nil {
+		return nil, nil, err
+	}
+	rawResults, err := json.Marshal(fileStruct["results"])
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(rawResults, &results); err != nil {
+		return nil, nil, NewServiceError(ErrCodeInvalidInput, "could not parse the result data in secret file", err)
+	}
+	return fileStruct, results, nil
+}
+
+// the secrets recorded in a baseline, grouped by file
+func BaselineSecrets(content []byte) (SecretUpdateMap, error) {
+	_, results, err := parseBaseline(content)
+	return results, err
+}
+
+// line numbers move with unrelated edits, a secret is identified by file and hash
+func secretKey(filename string, secret map[string]interface{}) string {
+	return fmt.Sprintf("%s:%v", filename, secret["hashed_secret"])
+}
+
+func indexSecrets(results SecretUpdateMap) map[string]map[string]interface{} {
+	index := map[string]map[string]interface{}{}
+	for filename, secrets := range results {
+		for _, secret := range secrets {
+			index[secretKey(filename, secret)] = secret
+		}
+	}
+	return index
+}
+
+// returns the secrets found by the scan that are not in the baseline yet
+func NewSecrets(baseline []byte, scan []byte) (SecretUpdateMap, error) {
+	_, baselineResults, err := parseBaseline(baseline)
+	if err != nil {
+		return nil, err
+	}
+	_, scanResults, err := parseBaseline(scan)
+	if err != nil {
+		return nil, err
+	}
+	known := indexSecrets(baselineResults)
+	found := SecretUpdateMap{}
+	for filename, secrets := range scanResults {
+		for _, secret := range secrets {
+			if _, ok := known[secretKey(filename, secret)]; !ok {
+				found[filename] = append(found[filename], secret)
+			}
+		}
+	}
+	return found, nil
+}
+
+// returns the secrets found by the scan that are missing from the baseline, not audited yet
+// or audited as real secrets, the ones a pull request check fails on. The real ones are
+// returned with is_secret set so they are reported as such
+func UnauditedSecrets(baseline []byte, scan []byte) (SecretUpdateMap, error) {
+	_, baselineResults, err := parseBaseline(baseline)
+	if err != nil {
+		return nil, err
+	}
+	_, scanResults, err := parseBaseline(scan)
+	if err != nil {
+		return nil, err
+	}
+	known := indexSecrets(baselineResults)
+	found := SecretUpdateMap{}
+	for filename, secrets := range scanResults {
+		for _, secret := range secrets {
+			previous, ok := known[secretKey(filename, secret)]
+			if ok {
+				isSecret, audited := previous["is_secret"].(bool)
+				if audited && !isSecret {
+					continue
+				}
+				if audited {
+					confirmed := map[string]interface{}{}
+					for field, value := range secret {
+						confirmed[field] = value
+					}
+					confirmed["is_secret"] = true
+					secret = confirmed
+				}
+			}
+			found[filename] = append(found[filename], secret)
+		}
+	}
+	return found, nil
+}
+
+// builds the new secrets file from the scan, keeping the audit decisions of the baseline
+func MergeBaseline(baseline []byte, scan []byte) ([]byte, error) {
+	_, baselineResults, err := parseBaseline(baseline)
+	if err != nil {
+		return nil, err
+	}
+	fileStruct, scanResults, err := parseBaseline(scan)
+	if err != nil {
+		return nil, err
+	}
+	known := indexSecrets(baselineResults)
+	for filename, secrets := range scanResults {
+		for _, secret := range secrets {
+			previous, ok := known[secretKey(filename, secret)]
+			if !ok {
+				continue
+			}
+			for _, field := range baselineAuditFields {
+				if value, ok := previous[field]; ok {
+					secret[field] = value
+				}
+			}
+		}
+	}
+	fileStruct["results"] = scanResults
+	return json.MarshalIndent(fileStruct, "", "  ")
+}
+
+// audit field of a secret whose value differs between two baselines
+type SecretChange struct {
+	Filename     string      `json:"filename"`
+	HashedSecret interface{} `json:"hashed_secret"`
+	Field        string      `json:"field"`
+	Before       interface{} `json:"before"`
+	After        interface{} `json:"after"`
+}
+
+// secrets added, removed and audited between two versions of a secrets file
+type BaselineDiff struct {
+	Added   SecretUpdateMap `json:"added"`
+	Removed SecretUpdateMap `json:"removed"`
+	Changed []SecretChange  `json:"changed"`
+}
+
+// compares the secrets of two baselines instead of their text, so reordering and formatting
+// are not reported
+func DiffBaselines(before []byte, after []byte) (BaselineDiff, error) {
+	diff := BaselineDiff{Added: SecretUpdateMap{}, Removed: SecretUpdateMap{}, Changed: []SecretChange{}}
+	_, beforeResults, err := parseBaseline(before)
+	if err != nil {
+		return diff, err
+	}
+	_, afterResults, err := parseBaseline(after)
+	if err != nil {
+		return diff, err
+	}
+	previous := indexSecrets(beforeResults)
+	current := indexSecrets(afterResults)
+	for filename, secrets := range afterResults {
+		for _, secret := range secrets {
+			old, ok := previous[secretKey(filename, secret)]
+			if !ok {
+				diff.Added[filename] = append(diff.Added[filename], secret)
+				continue
+			}
+			for _, field := range baselineAuditFields {
+				if !reflect.DeepEqual(old[field], secret[field]) {
+					diff.Changed = append(diff.Changed, SecretChange{
+						Filename: filename, HashedSecret: secret["hashed_secret"], Field: field, Before: old[field], After: secret[field],
+					})
+				}
+			}
+		}
+	}
+	for filename, secrets := range beforeResults {
+		for _, secret := range secrets {
+			if _, ok := current[secretKey(filename, secret)]; !ok {
+				diff.Removed[filename] = append(diff.Removed[filename], secret)
+			}
+		}
+	}
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		if diff.Changed[i].Filename != diff.Changed[j].Filename {
+			return diff.Changed[i].Filename < diff.Changed[j].Filename
+		}
+		return fmt.Sprint(diff.Changed[i].HashedSecret, diff.Changed[i].Field) < fmt.Sprint(diff.Changed[j].HashedSecret, diff.Changed[j].Field)
+	})
+	return diff, nil
+}
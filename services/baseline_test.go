@@ -0,0 +1,93 @@
+package services
+
+import (
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+)
+
+var _ = Describe("Baseline", func() {
+	baseline := []byte(`{
+  "version": "0.14.3",
+  "results": {
+    "config.py": [
+      {"type": "Secret Keyword", "hashed_secret": "aaa", "line_number": 3, "is_secret": false, "is_verified": false}
+    ]
+  }
+}`)
+	scan := []byte(`{
+  "version": "0.14.3",
+  "results": {
+    "config.py": [
+      {"type": "Secret Keyword", "hashed_secret": "aaa", "line_number": 5, "is_verified": false},
+      {"type": "AWS Access Key", "hashed_secret": "bbb", "line_number": 9, "is_verified": false}
+    ],
+    "app.py": [
+      {"type": "Base64 High Entropy String", "hashed_secret": "ccc", "line_number": 1, "is_verified": false}
+    ]
+  }
+}`)
+
+	It("finds the secrets missing from the baseline regardless of line moves", func() {
+		found, err := NewSecrets(baseline, scan)
+		Expect(err).To(BeNil())
+		Expect(found).To(HaveLen(2))
+		Expect(found["config.py"]).To(HaveLen(1))
+		Expect(found["config.py"][0]["hashed_secret"]).To(Equal("bbb"))
+		Expect(found["app.py"]).To(HaveLen(1))
+	})
+
+	It("treats every secret as new when there is no baseline", func() {
+		found, err := NewSecrets(nil, scan)
+		Expect(err).To(BeNil())
+		Expect(found["config.py"]).To(HaveLen(2))
+	})
+
+	It("keeps the audit decisions of the baseline when merging a scan", func() {
+		merged, err := MergeBaseline(baseline, scan)
+		Expect(err).To(BeNil())
+		_, results, err := parseBaseline(merged)
+		Expect(err).To(BeNil())
+		Expect(results["config.py"][0]["is_secret"]).To(Equal(false))
+		Expect(results["config.py"][0]["line_number"]).To(BeNumerically("==", 5))
+		Expect(results["config.py"][1]).NotTo(HaveKey("is_secret"))
+		var fileStruct map[string]interface{}
+		Expect(json.Unmarshal(merged, &fileStruct)).To(Succeed())
+		Expect(fileStruct["version"]).To(Equal("0.14.3"))
+	})
+
+	It("returns an error for malformed content", func() {
+		_, err := NewSecrets([]byte("not json"), scan)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("reads the secrets file of a clone and nil when it is missing", func() {
+		path, err := ioutil.TempDir("", "baseline")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(path)
+		content, err := ReadBaseline(path)
+		Expect(err).To(BeNil())
+		Expect(content).To(BeNil())
+		Expect(ioutil.WriteFile(path+"/"+Config.Scanner.SecretsFileName, baseline, 0644)).To(Succeed())
+		content, err = ReadBaseline(path)
+		Expect(err).To(BeNil())
+		Expect(content).To(Equal(baseline))
+	})
+
+	It("diffs the secrets of two baselines regardless of their order", func() {
+		before := []byte(`{"results": {"a.py": [{"hashed_secret": "kept"}, {"hashed_secret": "audited"}, {"hashed_secret": "gone"}]}}`)
+		after := []byte(`{"results": {"a.py": [{"hashed_secret": "audited", "is_secret": false}, {"hashed_secret": "kept"}], "b.py": [{"hashed_secret": "new"}]}}`)
+		diff, err := DiffBaselines(before, after)
+		Expect(err).To(BeNil())
+		Expect(diff.Added["b.py"]).To(HaveLen(1))
+		Expect(diff.Removed["a.py"][0]["hashed_secret"]).To(Equal("gone"))
+		Expect(diff.Changed).To(Equal([]SecretChange{{Filename: "a.py", HashedSecret: "audited", Field: "is_secret", Before: nil, After: false}}))
+
+		diff, err = DiffBaselines(nil, after)
+		Expect(err).To(BeNil())
+		Expect(diff.Added).To(HaveLen(2))
+		Expect(diff.Removed).To(BeEmpty())
+	})
+})
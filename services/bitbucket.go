@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// repositories live under a project key, personal forks under "~username"
+func bitbucketRepoPath(owner string, repo string) string {
+	return fmt.Sprintf("projects/%s/repos/%s", url.PathEscape(owner), url.PathEscape(repo))
+}
+
+func (gitService bitbucketServiceImplementation) request(method string, path string, payload interface{}, result interface{}) (int, error) {
+	header := http.Header{}
+	header.Set("Authorization", fmt.Sprintf("Bearer %s", Config.Bitbucket.Token))
+	return jsonRequest(gitService.context(), "Bitbucket", method, fmt.Sprintf("%s/rest/api/1.0/%s", strings.TrimSuffix(Config.Bitbucket.URL, "/"), path), header, payload, result)
+}
+
+func bitbucketRef(branch string, owner string, repo string) *bitbucketRefParams {
+	ref := &bitbucketRefParams{ID: fmt.Sprintf("refs/heads/%s", branch)}
+	ref.Repository.Slug = repo
+	ref.Repository.Project.Key = owner
+	return ref
+}
+
+func (gitService bitbucketServiceImplementation) withContext(ctx context.Context) gitServiceInterface {
+	gitService.ctx = ctx
+	return gitService
+}
+
+func (gitService bitbucketServiceImplementation) CheckUserAccessRepo(owner string, repo string) error {
+	gitService.logger().Info().Msgf("check user has access to %s/%s in bitbucket", owner, repo)
+	_, err := gitService.request(http.MethodGet, bitbucketRepoPath(owner, repo), nil, nil)
+	if err != nil {
+		gitService.logger().Error().Msgf("Error fetching repository: %v", err)
+		return err
+	}
+	return nil
+}
+
+// forks into the personal project of the token user, an existing fork is reused
+func (gitService bitbucketServiceImplementation) ForkRepo(owner string, repo string) (forkedOwner interface{}, gitURL interface{}, err error) {
+	gitService.logger().Info().Msgf("Forking repository from '%s/%s' in bitbucket", owner, repo)
+	var fork bitbucketRepositoryParams
+	status, err := gitService.request(http.MethodPost, bitbucketRepoPath(owner, repo), struct{}{}, &fork)
+	if status == http.StatusConflict {
+		personalProject := fmt.Sprintf("~%s", Config.Bitbucket.Username)
+		gitService.logger().Info().Msgf("Repository '%s/%s' was already forked to %s", owner, repo, personalProject)
+		_, err = gitService.request(http.MethodGet, bitbucketRepoPath(personalProject, repo), nil, &fork)
+		if err != nil {
+			gitService.logger().Error().Msgf("Error fetching existing fork of '%s/%s': %v", owner, repo, err)
+			return "", "", err
+		}
+	} else if err != nil {
+		gitService.logger().Error().Msgf("Error forking repository from '%s/%s': %v", owner, repo, err)
+		return "", "", err
+	}
+	cloneURL := ""
+	for _, link := range fork.Links.Clone {
+		if link.Name == "http" {
+			cloneURL = link.Href
+		}
+	}
+	gitService.logger().Info().Msgf("Project of the fork: %s", fork.Project.Key)
+	return fork.Project.Key, cloneURL, nil
+}
+
+func (gitService bitbucketServiceImplementation) CheckForkedRepo(owner string, repo string) error {
+	gitService.logger().Info().Msgf("Checking if repository was forked properly")
+	for attempt := 0; attempt < Config.Timeouts.ForkPollAttempts; attempt++ {
+		var fork bitbucketRepositoryParams
+		_, err := gitService.request(http.MethodGet, bitbucketRepoPath(owner, repo), nil, &fork)
+		if err != nil {
+			gitService.logger().Error().Msgf("Error: %v", err)
+			return err
+		}
+		switch fork.State {
+		case "", "AVAILABLE":
+			gitService.logger().Info().Msgf("Repository has been forked successfully")
+			return nil
+		case "INITIALISATION_FAILED":
+			return NewServiceError(ErrCodeUpstream, fmt.Sprintf("fork of %s/%s failed", owner, repo), nil)
+		}
+		if err := sleepContext(gitService.context(), Config.Timeouts.ForkPollInterval); err != nil {
+			return err
+		}
+	}
+	return NewServiceError(ErrCodeTimeout, fmt.Sprintf("fork of %s/%s did not finish in time", owner, repo), nil)
+}
+
+func (gitService bitbucketServiceImplementation) CloneRepo(owner string, repo string) (*git.Repository, string, error) {
+	cloneURL, err := url.Parse(Config.Bitbucket.URL)
+	if err != nil {
+		return nil, "", err
+	}
+	cloneURL.User = url.UserPassword(Config.Bitbucket.Username, Config.Bitbucket.Token)
+	cloneURL.Path = fmt.Sprintf("%s/scm/%s/%s.git", strings.TrimSuffix(cloneURL.Path, "/"), strings.ToLower(owner), repo)
+	return gitService.CloneFromURL(owner, repo, cloneURL.String())
+}
+
+func (gitService bitbucketServiceImplementation) OpenPullRequest(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string) (int, error) {
+	gitService.logger().Info().Msgf("Head Branch: %s", headBranch)
+	gitService.logger().Info().Msgf("Current Branch: %s", currentBranch)
+	defer ObservePipelineStep("pull_request", time.Now())
+	var number int
+	err := Retry(gitService.context(), "pull request", func() (err error) {
+		number, err = gitService.CreatePullRequest(owner, originalOwner, repo, currentBranch, headBranch, action, description)
+		return err
+	})
+	return number, err
+}
+
+// opens the pull request from the fork, when one is already open for the branch its
+// title and description are updated and ErrPullRequestUpdated is returned
+func (gitService bitbucketServiceImplementation) CreatePullRequest(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string) (int, error) {
+	pullRequest := bitbucketPullRequestParams{
+		Title:       PullRequestTitle(action),
+		Description: description,
+		FromRef:     bitbucketRef(currentBranch, owner, repo),
+		ToRef:       bitbucketRef(headBranch, originalOwner, repo),
+	}
+	pullRequestsPath := fmt.Sprintf("%s/pull-requests", bitbucketRepoPath(originalOwner, repo))
+	var created bitbucketPullRequestParams
+	status, err := gitService.request(http.MethodPost, pullRequestsPath, pullRequest, &created)
+	if err == nil {
+		gitService.logger().Info().Msgf("PR success Created! '%s/%s'", owner, repo)
+		return created.ID, nil
+	}
+	if status != http.StatusConflict {
+		gitService.logger().Error().Msgf("Error creating PR in '%s/%s': %v", originalOwner, repo, err)
+		return 0, err
+	}
+
+	var open bitbucketPullRequestPageParams
+	query := url.Values{"direction": {"INCOMING"}, "at": {pullRequest.ToRef.ID}, "state": {"OPEN"}}
+	_, err = gitService.request(http.MethodGet, fmt.Sprintf("%s?%s", pullRequestsPath, query.Encode()), nil, &open)
+	if err != nil {
+		return 0, err
+	}
+	for _, existing := range open.Values {
+		if existing.FromRef == nil || existing.FromRef.ID != pullRequest.FromRef.ID || !strings.EqualFold(existing.FromRef.Repository.Project.Key, owner) {
+			continue
+		}
+		update := bitbucketPullRequestParams{Version: existing.Version, Title: pullRequest.Title, Description: description}
+		_, err = gitService.request(http.MethodPut, fmt.Sprintf("%s/%d", pullRequestsPath, existing.ID), update, nil)
+		if err != nil {
+			return 0, err
+		}
+		gitService.logger().Info().Msgf("PR success Updated! '%s/%s'", owner, repo)
+		return existing.ID, ErrPullRequestUpdated
+	}
+	return 0, NewServiceError(ErrCodeConflict, fmt.Sprintf("pull request from %s could not be created or found", currentBranch), nil)
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+// in memory stand in for the Bitbucket Data Center REST API
+type fakeBitbucket struct {
+	repositories map[string]bitbucketRepositoryParams
+	forkConflict bool
+	states       []string
+	pullRequests []bitbucketPullRequestParams
+	updates      []bitbucketPullRequestParams
+	auth         []string
+}
+
+func (fake *fakeBitbucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.auth = append(fake.auth, r.Header.Get("Authorization"))
+	path := strings.TrimPrefix(r.URL.Path, "/rest/api/1.0/")
+	writeJSON := func(status int, body interface{}) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	}
+	switch {
+	case strings.HasSuffix(path, "/pull-requests") && r.Method == http.MethodPost:
+		var pullRequest bitbucketPullRequestParams
+		_ = json.NewDecoder(r.Body).Decode(&pullRequest)
+		for _, existing := range fake.pullRequests {
+			if existing.FromRef.ID == pullRequest.FromRef.ID {
+				writeJSON(http.StatusConflict, map[string]string{"message": "Only one pull request may be open for a given source and target branch"})
+				return
+			}
+		}
+		pullRequest.ID = len(fake.pullRequests) + 1
+		fake.pullRequests = append(fake.pullRequests, pullRequest)
+		writeJSON(http.StatusCreated, pullRequest)
+	case strings.HasSuffix(path, "/pull-requests") && r.Method == http.MethodGet:
+		writeJSON(http.StatusOK, bitbucketPullRequestPageParams{Values: fake.pullRequests})
+	case strings.Contains(path, "/pull-requests/") && r.Method == http.MethodPut:
+		var update bitbucketPullRequestParams
+		_ = json.NewDecoder(r.Body).Decode(&update)
+		fake.updates = append(fake.updates, update)
+		writeJSON(http.StatusOK, update)
+	case r.Method == http.MethodPost:
+		if fake.forkConflict {
+			writeJSON(http.StatusConflict, map[string]string{"message": "This repository URL is already taken."})
+			return
+		}
+		writeJSON(http.StatusCreated, fake.repositories["projects/~bot/repos/repo"])
+	default:
+		repository, ok := fake.repositories[path]
+		if !ok {
+			writeJSON(http.StatusNotFound, map[string]string{"message": "Repository does not exist."})
+			return
+		}
+		if len(fake.states) > 0 {
+			repository.State = fake.states[0]
+			fake.states = fake.states[1:]
+		}
+		writeJSON(http.StatusOK, repository)
+	}
+}
+
+var _ = Describe("Bitbucket", func() {
+	var fake *fakeBitbucket
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		fork := bitbucketRepositoryParams{Slug: "repo", State: "AVAILABLE"}
+		fork.Project.Key = "~BOT"
+		fork.Links.Clone = append(fork.Links.Clone, struct {
+			Href string `json:"href"`
+			Name string `json:"name"`
+		}{Href: "https://bitbucket.example/scm/~bot/repo.git", Name: "http"})
+		fake = &fakeBitbucket{repositories: map[string]bitbucketRepositoryParams{
+			"projects/SEC/repos/repo": {Slug: "repo", State: "AVAILABLE"},
+			"projects/~bot/repos/repo": fork,
+		}}
+		server = httptest.NewServer(fake)
+		Config.Bitbucket.URL, Config.Bitbucket.Username, Config.Bitbucket.Token = server.URL, "bot", "bitbucket-token"
+		Config.Timeouts.ForkPollInterval = time.Millisecond
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("is selected by the provider name", func() {
+		service, err := GitProvider(context.Background(), "bitbucket", "")
+		Expect(err).To(BeNil())
+		Expect(service).To(Equal(TraceGitService(context.Background(), BitbucketServiceObject)))
+	})
+
+	Context("when checking user access", func() {
+		It("returns no error for a readable repository and sends the token", func() {
+			Expect(BitbucketServiceObject.CheckUserAccessRepo("SEC", "repo")).To(BeNil())
+			Expect(fake.auth).To(ConsistOf("Bearer bitbucket-token"))
+		})
+
+		It("returns an error when the repository does not exist", func() {
+			Expect(BitbucketServiceObject.CheckUserAccessRepo("SEC", "missing")).NotTo(BeNil())
+		})
+	})
+
+	Context("when forking a repository", func() {
+		It("returns the personal project and http clone url of the fork", func() {
+			owner, gitURL, err := BitbucketServiceObject.ForkRepo("SEC", "repo")
+			Expect(err).To(BeNil())
+			Expect(owner).To(Equal("~BOT"))
+			Expect(gitURL).To(Equal("https://bitbucket.example/scm/~bot/repo.git"))
+		})
+
+		It("reuses the fork when it already exists", func() {
+			fake.forkConflict = true
+			owner, _, err := BitbucketServiceObject.ForkRepo("SEC", "repo")
+			Expect(err).To(BeNil())
+			Expect(owner).To(Equal("~BOT"))
+		})
+
+		It("waits until the fork is available", func() {
+			fake.states = []string{"INITIALISING", "AVAILABLE"}
+			Expect(BitbucketServiceObject.CheckForkedRepo("~bot", "repo")).To(BeNil())
+			Expect(fake.states).To(BeEmpty())
+		})
+
+		It("returns an error when the fork failed", func() {
+			fake.states = []string{"INITIALISATION_FAILED"}
+			Expect(BitbucketServiceObject.CheckForkedRepo("~bot", "repo")).NotTo(BeNil())
+		})
+	})
+
+	Context("when opening a pull request", func() {
+		It("creates it from the fork branch into the original repository", func() {
+			number, err := bitbucketServiceImplementation{}.CreatePullRequest("~BOT", "SEC", "repo", "feature", "main", "Create", "description")
+			Expect(err).To(BeNil())
+			Expect(number).To(Equal(1))
+			Expect(fake.pullRequests).To(HaveLen(1))
+			Expect(fake.pullRequests[0].FromRef.ID).To(Equal("refs/heads/feature"))
+			Expect(fake.pullRequests[0].FromRef.Repository.Project.Key).To(Equal("~BOT"))
+			Expect(fake.pullRequests[0].ToRef.ID).To(Equal("refs/heads/main"))
+			Expect(fake.pullRequests[0].ToRef.Repository.Project.Key).To(Equal("SEC"))
+		})
+
+		It("updates the open pull request for the same branch", func() {
+			service := bitbucketServiceImplementation{}
+			_, err := service.CreatePullRequest("~BOT", "SEC", "repo", "feature", "main", "Create", "first")
+			Expect(err).To(BeNil())
+			number, err := service.CreatePullRequest("~BOT", "SEC", "repo", "feature", "main", "Update", "second")
+			Expect(err).To(Equal(ErrPullRequestUpdated))
+			Expect(number).To(Equal(1))
+			Expect(fake.updates).To(HaveLen(1))
+			Expect(fake.updates[0].Description).To(Equal("second"))
+			Expect(fake.updates[0].FromRef).To(BeNil())
+		})
+	})
+})
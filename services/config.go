@@ -0,0 +1,9 @@
+package services
+
+import (
+	"github.com/eliezer-borde-globant/EBGoProject/config"
+)
+
+// configuration the services and the controller read their settings from, main sets the
+// loaded one before serving and nothing changes it afterwards
+var Config = config.Defaults()
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/go-github/v33/github"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// kind of failure, decides the HTTP status the API answers with
+type ErrorCode string
+
+const (
+	ErrCodeInProgress       ErrorCode = "in_progress"
+	ErrCodeInvalidInput     ErrorCode = "invalid_input"
+	ErrCodeUnsupportedMedia ErrorCode = "unsupported_media_type"
+	ErrCodeUnauthorized     ErrorCode = "unauthorized"
+	ErrCodeNotFound         ErrorCode = "not_found"
+	ErrCodeForbidden        ErrorCode = "forbidden"
+	ErrCodeRateLimited      ErrorCode = "rate_limited"
+	ErrCodeConflict         ErrorCode = "conflict"
+	ErrCodeUpstream         ErrorCode = "upstream_failure"
+	ErrCodeTimeout          ErrorCode = "timeout"
+	ErrCodeUnavailable      ErrorCode = "unavailable"
+	ErrCodeInternal         ErrorCode = "internal"
+)
+
+func (code ErrorCode) HTTPStatus() int {
+	switch code {
+	case ErrCodeInProgress:
+		return http.StatusAccepted
+	case ErrCodeInvalidInput:
+		return http.StatusBadRequest
+	case ErrCodeUnsupportedMedia:
+		return http.StatusUnsupportedMediaType
+	case ErrCodeUnauthorized:
+		return http.StatusUnauthorized
+	case ErrCodeNotFound:
+		return http.StatusNotFound
+	case ErrCodeForbidden:
+		return http.StatusForbidden
+	case ErrCodeRateLimited:
+		return http.StatusTooManyRequests
+	case ErrCodeConflict:
+		return http.StatusConflict
+	case ErrCodeUpstream:
+		return http.StatusBadGateway
+	case ErrCodeTimeout:
+		return http.StatusGatewayTimeout
+	case ErrCodeUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// error with the kind of failure attached, Err is the underlying cause when there is one
+type ServiceError struct {
+	Code    ErrorCode
+	Message string
+	Err     error
+}
+
+func NewServiceError(code ErrorCode, message string, err error) *ServiceError {
+	return &ServiceError{Code: code, Message: message, Err: err}
+}
+
+func (serviceError *ServiceError) Error() string {
+	if serviceError.Err == nil {
+		return serviceError.Message
+	}
+	return fmt.Sprintf("%s: %v", serviceError.Message, serviceError.Err)
+}
+
+func (serviceError *ServiceError) Unwrap() error {
+	return serviceError.Err
+}
+
+// non successful response of a REST API called without a client library
+type HTTPStatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (statusError *HTTPStatusError) Error() string {
+	if statusError.Body == "" {
+		return fmt.Sprintf("%s %s returned %d", statusError.Method, statusError.Path, statusError.StatusCode)
+	}
+	return fmt.Sprintf("%s %s returned %d: %s", statusError.Method, statusError.Path, statusError.StatusCode, statusError.Body)
+}
+
+func codeForStatus(statusCode int) ErrorCode {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrCodeForbidden
+	case statusCode == http.StatusNotFound:
+		return ErrCodeNotFound
+	case statusCode == http.StatusConflict || statusCode == http.StatusUnprocessableEntity:
+		return ErrCodeConflict
+	case statusCode == http.StatusTooManyRequests:
+		return ErrCodeRateLimited
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		return ErrCodeTimeout
+	}
+	return ErrCodeUpstream
+}
+
+// code of the error, looking through wrapped errors for the typed errors of this package, the
+// GitHub client, go-git transports and network timeouts, fallback when none applies
+func ClassifyError(err error, fallback ErrorCode) ErrorCode {
+	var serviceError *ServiceError
+	if errors.As(err, &serviceError) {
+		return serviceError.Code
+	}
+	var rateLimitError *github.RateLimitError
+	var abuseRateLimitError *github.AbuseRateLimitError
+	if errors.As(err, &rateLimitError) || errors.As(err, &abuseRateLimitError) {
+		return ErrCodeRateLimited
+	}
+	var githubError *github.ErrorResponse
+	if errors.As(err, &githubError) && githubError.Response != nil {
+		return codeForStatus(githubError.Response.StatusCode)
+	}
+	var statusError *HTTPStatusError
+	if errors.As(err, &statusError) {
+		return codeForStatus(statusError.StatusCode)
+	}
+	var netError net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netError) && netError.Timeout()) {
+		return ErrCodeTimeout
+	}
+	switch {
+	case errors.Is(err, transport.ErrRepositoryNotFound):
+		return ErrCodeNotFound
+	case errors.Is(err, transport.ErrAuthenticationRequired), errors.Is(err, transport.ErrAuthorizationFailed):
+		return ErrCodeForbidden
+	}
+	return fallback
+}
+
+var urlCredentials = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/@\s]+@`)
+
+// text of err without the credentials of the urls it mentions nor the configured tokens,
+// safe to log and to answer to API clients
+func RedactError(err error) string {
+	text := urlCredentials.ReplaceAllString(err.Error(), "${1}")
+	secrets := []string{Config.GitHub.Token, Config.GitLab.Token, Config.Bitbucket.Token, Config.GitHub.WebhookSecret}
+	for _, host := range Config.GitHub.Hosts {
+		secrets = append(secrets, host.Token)
+	}
+	for _, secret := range secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, "[REDACTED]")
+		}
+	}
+	return text
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/go-github/v33/github"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+)
+
+var _ = Describe("Errors", func() {
+	githubError := func(statusCode int) error {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: statusCode}, Message: "failed"}
+	}
+
+	It("keeps the code of typed errors through wrapping", func() {
+		err := fmt.Errorf("creating branch: %w", NewServiceError(ErrCodeConflict, "branch exists", nil))
+		Expect(ClassifyError(err, ErrCodeInternal)).To(Equal(ErrCodeConflict))
+		Expect(ClassifyError(ErrQueueFull, ErrCodeInternal)).To(Equal(ErrCodeUnavailable))
+	})
+
+	It("maps GitHub and REST responses by status", func() {
+		Expect(ClassifyError(githubError(404), ErrCodeUpstream)).To(Equal(ErrCodeNotFound))
+		Expect(ClassifyError(githubError(403), ErrCodeUpstream)).To(Equal(ErrCodeForbidden))
+		Expect(ClassifyError(githubError(422), ErrCodeUpstream)).To(Equal(ErrCodeConflict))
+		Expect(ClassifyError(githubError(500), ErrCodeInternal)).To(Equal(ErrCodeUpstream))
+		Expect(ClassifyError(&github.RateLimitError{Response: &http.Response{StatusCode: 403}}, ErrCodeUpstream)).To(Equal(ErrCodeRateLimited))
+		Expect(ClassifyError(&HTTPStatusError{Method: "POST", Path: "/forks", StatusCode: 429}, ErrCodeUpstream)).To(Equal(ErrCodeRateLimited))
+	})
+
+	It("recognizes timeouts and git transport errors", func() {
+		Expect(ClassifyError(fmt.Errorf("fetch: %w", context.DeadlineExceeded), ErrCodeUpstream)).To(Equal(ErrCodeTimeout))
+		Expect(ClassifyError(transport.ErrRepositoryNotFound, ErrCodeUpstream)).To(Equal(ErrCodeNotFound))
+		Expect(ClassifyError(transport.ErrAuthenticationRequired, ErrCodeUpstream)).To(Equal(ErrCodeForbidden))
+	})
+
+	It("falls back for untyped errors", func() {
+		Expect(ClassifyError(errors.New("boom"), ErrCodeUpstream)).To(Equal(ErrCodeUpstream))
+		Expect(ErrCodeUpstream.HTTPStatus()).To(Equal(502))
+		Expect(ErrCodeTimeout.HTTPStatus()).To(Equal(504))
+		Expect(ErrCodeRateLimited.HTTPStatus()).To(Equal(429))
+	})
+})
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitLab addresses projects by their url encoded "namespace/name" path
+func gitLabProjectPath(owner string, repo string) string {
+	return fmt.Sprintf("projects/%s", url.PathEscape(fmt.Sprintf("%s/%s", owner, repo)))
+}
+
+func (gitService gitLabServiceImplementation) request(method string, path string, payload interface{}, result interface{}) (int, error) {
+	header := http.Header{}
+	header.Set("PRIVATE-TOKEN", Config.GitLab.Token)
+	return jsonRequest(gitService.context(), "GitLab", method, fmt.Sprintf("%s/api/v4/%s", strings.TrimSuffix(Config.GitLab.URL, "/"), path), header, payload, result)
+}
+
+func (gitService gitLabServiceImplementation) withContext(ctx context.Context) gitServiceInterface {
+	gitService.ctx = ctx
+	return gitService
+}
+
+func (gitService gitLabServiceImplementation) CheckUserAccessRepo(owner string, repo string) error {
+	gitService.logger().Info().Msgf("check user has access to %s/%s in gitlab", owner, repo)
+	_, err := gitService.request(http.MethodGet, gitLabProjectPath(owner, repo), nil, nil)
+	if err != nil {
+		gitService.logger().Error().Msgf("Error fetching project: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (gitService gitLabServiceImplementation) ForkRepo(owner string, repo string) (forkedOwner interface{}, gitURL interface{}, err error) {
+	gitService.logger().Info().Msgf("Forking project from '%s/%s' in gitlab", owner, repo)
+	var project gitLabProjectParams
+	status, err := gitService.request(http.MethodPost, fmt.Sprintf("%s/fork", gitLabProjectPath(owner, repo)), nil, &project)
+	if status == http.StatusConflict {
+		gitService.logger().Info().Msgf("Project '%s/%s' was already forked", owner, repo)
+		var user gitLabUserParams
+		if _, err := gitService.request(http.MethodGet, "user", nil, &user); err != nil {
+			gitService.logger().Error().Msgf("Error fetching gitlab user: %v", err)
+			return "", "", err
+		}
+		_, err = gitService.request(http.MethodGet, gitLabProjectPath(user.Username, repo), nil, &project)
+		if err != nil {
+			gitService.logger().Error().Msgf("Error fetching existing fork of '%s/%s': %v", owner, repo, err)
+			return "", "", err
+		}
+	} else if err != nil {
+		gitService.logger().Error().Msgf("Error forking project from '%s/%s': %v", owner, repo, err)
+		return "", "", err
+	}
+	gitService.logger().Info().Msgf("User who forked: %s", project.Namespace.FullPath)
+	return project.Namespace.FullPath, project.HTTPURLToRepo, nil
+}
+
+// forks are created asynchronously, waits until the import of the fork has finished
+func (gitService gitLabServiceImplementation) CheckForkedRepo(owner string, repo string) error {
+	gitService.logger().Info().Msgf("Checking if project was forked properly")
+	for attempt := 0; attempt < Config.Timeouts.ForkPollAttempts; attempt++ {
+		var project gitLabProjectParams
+		_, err := gitService.request(http.MethodGet, gitLabProjectPath(owner, repo), nil, &project)
+		if err != nil {
+			gitService.logger().Error().Msgf("Error: %v", err)
+			return err
+		}
+		switch project.ImportStatus {
+		case "", "none", "finished":
+			gitService.logger().Info().Msgf("Project has been forked successfully")
+			return nil
+		case "failed":
+			return NewServiceError(ErrCodeUpstream, fmt.Sprintf("fork of %s/%s failed", owner, repo), nil)
+		}
+		if err := sleepContext(gitService.context(), Config.Timeouts.ForkPollInterval); err != nil {
+			return err
+		}
+	}
+	return NewServiceError(ErrCodeTimeout, fmt.Sprintf("fork of %s/%s did not finish in time", owner, repo), nil)
+}
+
+func (gitService gitLabServiceImplementation) CloneRepo(owner string, repo string) (*git.Repository, string, error) {
+	cloneURL, err := url.Parse(Config.GitLab.URL)
+	if err != nil {
+		return nil, "", err
+	}
+	cloneURL.User = url.UserPassword("oauth2", Config.GitLab.Token)
+	cloneURL.Path = fmt.Sprintf("%s/%s/%s.git", strings.TrimSuffix(cloneURL.Path, "/"), owner, repo)
+	return gitService.CloneFromURL(owner, repo, cloneURL.String())
+}
+
+func (gitService gitLabServiceImplementation) OpenPullRequest(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string) (int, error) {
+	gitService.logger().Info().Msgf("Head Branch: %s", headBranch)
+	gitService.logger().Info().Msgf("Current Branch: %s", currentBranch)
+	defer ObservePipelineStep("pull_request", time.Now())
+	var number int
+	err := Retry(gitService.context(), "merge request", func() (err error) {
+		number, err = gitService.CreateMergeRequest(owner, originalOwner, repo, currentBranch, headBranch, action, description)
+		return err
+	})
+	return number, err
+}
+
+// opens the merge request from the fork, when one is already open for the branch its
+// title and description are updated and ErrPullRequestUpdated is returned
+func (gitService gitLabServiceImplementation) CreateMergeRequest(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string) (int, error) {
+	var fork, target gitLabProjectParams
+	if _, err := gitService.request(http.MethodGet, gitLabProjectPath(owner, repo), nil, &fork); err != nil {
+		return 0, err
+	}
+	if _, err := gitService.request(http.MethodGet, gitLabProjectPath(originalOwner, repo), nil, &target); err != nil {
+		return 0, err
+	}
+	mergeRequest := gitLabMergeRequestParams{
+		SourceBranch:    currentBranch,
+		TargetBranch:    headBranch,
+		TargetProjectID: target.ID,
+		Title:           PullRequestTitle(action),
+		Description:     description,
+	}
+	var created gitLabMergeRequestParams
+	status, err := gitService.request(http.MethodPost, fmt.Sprintf("projects/%d/merge_requests", fork.ID), mergeRequest, &created)
+	if err == nil {
+		gitService.logger().Info().Msgf("MR success Created! '%s/%s'", owner, repo)
+		return created.IID, nil
+	}
+	if status != http.StatusConflict {
+		gitService.logger().Error().Msgf("Error creating MR in '%s/%s': %v", originalOwner, repo, err)
+		return 0, err
+	}
+
+	var open []gitLabMergeRequestParams
+	query := url.Values{"state": {"opened"}, "source_branch": {currentBranch}, "target_branch": {headBranch}}
+	_, err = gitService.request(http.MethodGet, fmt.Sprintf("projects/%d/merge_requests?%s", target.ID, query.Encode()), nil, &open)
+	if err != nil {
+		return 0, err
+	}
+	for _, existing := range open {
+		if existing.SourceProjectID != fork.ID {
+			continue
+		}
+		update := map[string]string{"title": mergeRequest.Title, "description": description}
+		_, err = gitService.request(http.MethodPut, fmt.Sprintf("projects/%d/merge_requests/%d", target.ID, existing.IID), update, nil)
+		if err != nil {
+			return 0, err
+		}
+		gitService.logger().Info().Msgf("MR success Updated! '%s/%s'", owner, repo)
+		return existing.IID, ErrPullRequestUpdated
+	}
+	return 0, NewServiceError(ErrCodeConflict, fmt.Sprintf("merge request from %s could not be created or found", currentBranch), nil)
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+// in memory stand in for the GitLab REST API
+type fakeGitLab struct {
+	projects      map[string]gitLabProjectParams
+	username      string
+	forkConflict  bool
+	importStatus  []string
+	mergeRequests []gitLabMergeRequestParams
+	openRequests  []gitLabMergeRequestParams
+	updates       []string
+	tokens        []string
+}
+
+func (fake *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.tokens = append(fake.tokens, r.Header.Get("PRIVATE-TOKEN"))
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/")
+	writeJSON := func(status int, body interface{}) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	}
+	switch {
+	case path == "user":
+		writeJSON(http.StatusOK, gitLabUserParams{Username: fake.username})
+	case strings.HasSuffix(path, "/fork") && r.Method == http.MethodPost:
+		if fake.forkConflict {
+			writeJSON(http.StatusConflict, map[string]string{"message": "has already been taken"})
+			return
+		}
+		project := gitLabProjectParams{ID: 99, HTTPURLToRepo: "https://gitlab.example/" + fake.username + "/repo.git"}
+		project.Namespace.FullPath = fake.username
+		writeJSON(http.StatusCreated, project)
+	case strings.HasSuffix(path, "/merge_requests") && r.Method == http.MethodPost && len(fake.openRequests) > 0:
+		writeJSON(http.StatusConflict, map[string][]string{"message": {"Another open merge request already exists for this source branch"}})
+	case strings.HasSuffix(path, "/merge_requests") && r.Method == http.MethodGet:
+		writeJSON(http.StatusOK, fake.openRequests)
+	case strings.Contains(path, "/merge_requests/") && r.Method == http.MethodPut:
+		fake.updates = append(fake.updates, path)
+		writeJSON(http.StatusOK, map[string]string{})
+	case strings.HasSuffix(path, "/merge_requests") && r.Method == http.MethodPost:
+		var mergeRequest gitLabMergeRequestParams
+		_ = json.NewDecoder(r.Body).Decode(&mergeRequest)
+		fake.mergeRequests = append(fake.mergeRequests, mergeRequest)
+		writeJSON(http.StatusCreated, map[string]int{"iid": 1})
+	case strings.HasPrefix(path, "projects/"):
+		project, ok := fake.projects[strings.TrimPrefix(path, "projects/")]
+		if !ok {
+			writeJSON(http.StatusNotFound, map[string]string{"message": "404 Project Not Found"})
+			return
+		}
+		if len(fake.importStatus) > 0 {
+			project.ImportStatus = fake.importStatus[0]
+			fake.importStatus = fake.importStatus[1:]
+		}
+		writeJSON(http.StatusOK, project)
+	default:
+		writeJSON(http.StatusNotFound, map[string]string{"message": "404 Not Found"})
+	}
+}
+
+var _ = Describe("GitLab", func() {
+	var fake *fakeGitLab
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		fork := gitLabProjectParams{ID: 99, HTTPURLToRepo: "https://gitlab.example/bot/repo.git"}
+		fork.Namespace.FullPath = "bot"
+		fake = &fakeGitLab{
+			username: "bot",
+			projects: map[string]gitLabProjectParams{
+				"john%2Frepo": {ID: 1, PathWithNamespace: "john/repo"},
+				"bot%2Frepo":  fork,
+			},
+		}
+		server = httptest.NewServer(fake)
+		Config.GitLab.URL, Config.GitLab.Token = server.URL, "gitlab-token"
+		Config.Timeouts.ForkPollInterval = time.Millisecond
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("is selected by the provider name", func() {
+		service, err := GitProvider(context.Background(), "gitlab", "")
+		Expect(err).To(BeNil())
+		Expect(service).To(Equal(TraceGitService(context.Background(), GitLabServiceObject)))
+		_, err = GitProvider(context.Background(), "svn", "")
+		Expect(err).NotTo(BeNil())
+	})
+
+	Context("when checking user access", func() {
+		It("returns no error for a visible project and sends the token", func() {
+			Expect(GitLabServiceObject.CheckUserAccessRepo("john", "repo")).To(BeNil())
+			Expect(fake.tokens).To(ConsistOf("gitlab-token"))
+		})
+
+		It("returns an error when the project is not found", func() {
+			err := GitLabServiceObject.CheckUserAccessRepo("john", "missing")
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("404"))
+		})
+	})
+
+	Context("when forking a project", func() {
+		It("returns the namespace and clone url of the fork", func() {
+			owner, gitURL, err := GitLabServiceObject.ForkRepo("john", "repo")
+			Expect(err).To(BeNil())
+			Expect(owner).To(Equal("bot"))
+			Expect(gitURL).To(Equal("https://gitlab.example/bot/repo.git"))
+		})
+
+		It("reuses the existing fork when the project was already forked", func() {
+			fake.forkConflict = true
+			owner, gitURL, err := GitLabServiceObject.ForkRepo("john", "repo")
+			Expect(err).To(BeNil())
+			Expect(owner).To(Equal("bot"))
+			Expect(gitURL).To(Equal("https://gitlab.example/bot/repo.git"))
+		})
+
+		It("waits until the fork import has finished", func() {
+			fake.importStatus = []string{"scheduled", "started", "finished"}
+			Expect(GitLabServiceObject.CheckForkedRepo("bot", "repo")).To(BeNil())
+			Expect(fake.importStatus).To(BeEmpty())
+		})
+
+		It("returns an error when the fork import failed", func() {
+			fake.importStatus = []string{"failed"}
+			Expect(GitLabServiceObject.CheckForkedRepo("bot", "repo")).NotTo(BeNil())
+		})
+	})
+
+	Context("when opening a merge request", func() {
+		It("targets the original project from the fork branch", func() {
+			number, err := gitLabServiceImplementation{}.CreateMergeRequest("bot", "john", "repo", "feature", "main", "Create", "description")
+			Expect(err).To(BeNil())
+			Expect(number).To(Equal(1))
+			Expect(fake.mergeRequests).To(HaveLen(1))
+			Expect(fake.mergeRequests[0].SourceBranch).To(Equal("feature"))
+			Expect(fake.mergeRequests[0].TargetBranch).To(Equal("main"))
+			Expect(fake.mergeRequests[0].TargetProjectID).To(Equal(1))
+			Expect(fake.mergeRequests[0].Title).To(Equal("[Detect Secrets] Create Secret BaseLine File"))
+		})
+
+		It("updates the open merge request of the fork branch", func() {
+			fake.openRequests = []gitLabMergeRequestParams{
+				{IID: 4, SourceBranch: "feature", SourceProjectID: 7},
+				{IID: 5, SourceBranch: "feature", SourceProjectID: 99},
+			}
+			number, err := gitLabServiceImplementation{}.CreateMergeRequest("bot", "john", "repo", "feature", "main", "Update", "description")
+			Expect(err).To(Equal(ErrPullRequestUpdated))
+			Expect(number).To(Equal(5))
+			Expect(fake.updates).To(Equal([]string{"projects/1/merge_requests/5"}))
+		})
+
+		It("fails when the conflicting merge request is not found", func() {
+			fake.openRequests = []gitLabMergeRequestParams{{IID: 4, SourceBranch: "feature", SourceProjectID: 7}}
+			_, err := gitLabServiceImplementation{}.CreateMergeRequest("bot", "john", "repo", "feature", "main", "Update", "description")
+			Expect(err).NotTo(Equal(ErrPullRequestUpdated))
+			Expect(ClassifyError(err, ErrCodeInternal)).To(Equal(ErrCodeConflict))
+			Expect(fake.updates).To(BeEmpty())
+		})
+	})
+})
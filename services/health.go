@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	HealthOK      = "ok"
+	HealthFailing = "failing"
+)
+
+// result of one readiness check, Message explains a failure
+type HealthCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Message    string `json:"message,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// ok only when every check is ok
+type ReadinessReport struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+type healthCheckerInterface interface {
+	Ready(ctx context.Context) ReadinessReport
+}
+
+type healthCheckerImplementation struct {
+	checks map[string]func(ctx context.Context) error
+}
+
+// checks the token of the default GitHub host and of every GITHUB_HOSTS entry, that the work
+// directory is writable and has scanner.min_free_disk_mb free, and that the job queue has room
+func NewHealthChecker() healthCheckerInterface {
+	checks := map[string]func(ctx context.Context) error{
+		"github":     checkGitHubToken("", Config.GitHub.DefaultHost().Token),
+		"work_dir":   checkWorkDir,
+		"disk_space": checkFreeDisk,
+		"job_queue":  checkJobQueue,
+	}
+	for name, host := range Config.GitHub.Hosts {
+		checks[fmt.Sprintf("github:%s", name)] = checkGitHubToken(name, host.Token)
+	}
+	return healthCheckerImplementation{checks: checks}
+}
+
+// runs the checks concurrently, the report lists them sorted by name
+func (checker healthCheckerImplementation) Ready(ctx context.Context) ReadinessReport {
+	ctx, cancel := context.WithTimeout(ctx, Config.Timeouts.Readiness)
+	defer cancel()
+	report := ReadinessReport{Status: HealthOK}
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+	for name, check := range checker.checks {
+		wait.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wait.Done()
+			started := time.Now()
+			result := HealthCheck{Name: name, Status: HealthOK}
+			if err := check(ctx); err != nil {
+				result.Status, result.Message = HealthFailing, err.Error()
+			}
+			result.DurationMS = time.Since(started).Milliseconds()
+			mutex.Lock()
+			defer mutex.Unlock()
+			if result.Status != HealthOK {
+				report.Status = HealthFailing
+			}
+			report.Checks = append(report.Checks, result)
+		}(name, check)
+	}
+	wait.Wait()
+	sort.Slice(report.Checks, func(i, j int) bool { return report.Checks[i].Name < report.Checks[j].Name })
+	return report
+}
+
+// the rate limit endpoint needs a valid token but does not count against the quota
+func checkGitHubToken(host string, token string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if token == "" {
+			return NewServiceError(ErrCodeUnauthorized, "no GitHub token configured", nil)
+		}
+		gitService, err := GitHubHostService(ctx, host)
+		if err != nil {
+			return err
+		}
+		_, _, err = gitService.GetGitHubClient().RateLimits(ctx)
+		return err
+	}
+}
+
+func checkWorkDir(ctx context.Context) error {
+	file, err := ioutil.TempFile(Config.Scanner.WorkDir, ".readyz-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString("ok"); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func checkFreeDisk(ctx context.Context) error {
+	free, err := freeDiskBytes(Config.Scanner.WorkDir)
+	if err != nil {
+		return err
+	}
+	if minimum := uint64(Config.Scanner.MinFreeDiskMB) * 1024 * 1024; free < minimum {
+		return fmt.Errorf("%d MB free in %s, below the %d MB minimum", free/1024/1024, Config.Scanner.WorkDir, Config.Scanner.MinFreeDiskMB)
+	}
+	return nil
+}
+
+// a full or closed queue rejects every new job
+func checkJobQueue(ctx context.Context) error {
+	if JobQueue.Closed() {
+		return ErrQueueClosed
+	}
+	if depth, capacity := JobQueue.Depth(), JobQueue.Capacity(); depth >= capacity {
+		return fmt.Errorf("job queue is full, %d of %d jobs waiting", depth, capacity)
+	}
+	return nil
+}
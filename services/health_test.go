@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+)
+
+var _ = Describe("Health", func() {
+	It("reports every check sorted by name and fails when one of them fails", func() {
+		checker := healthCheckerImplementation{checks: map[string]func(ctx context.Context) error{
+			"work_dir":  func(ctx context.Context) error { return nil },
+			"github":    func(ctx context.Context) error { return errors.New("401 Bad credentials") },
+			"job_queue": func(ctx context.Context) error { return nil },
+		}}
+		report := checker.Ready(context.Background())
+		Expect(report.Status).To(Equal(HealthFailing))
+		Expect(report.Checks).To(HaveLen(3))
+		Expect(report.Checks[0].Name).To(Equal("github"))
+		Expect(report.Checks[0].Status).To(Equal(HealthFailing))
+		Expect(report.Checks[0].Message).To(Equal("401 Bad credentials"))
+		Expect(report.Checks[1].Status).To(Equal(HealthOK))
+	})
+
+	Context("GitHub token", func() {
+		var server *httptest.Server
+		var status int
+
+		BeforeEach(func() {
+			mux := http.NewServeMux()
+			server = httptest.NewServer(mux)
+			ThirdPartyContext = thirdPartyContextImpl{}
+			ThirdPartyOauth = thirdPartyOauthImpl{}
+			ThirdPartyGitHub = fakeGitHubClientFactory{baseURL: server.URL}
+			mux.HandleFunc("/rate_limit", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+				fmt.Fprint(w, `{"resources": {"core": {"limit": 5000, "remaining": 4999, "reset": 1}}}`)
+			})
+		})
+
+		AfterEach(func() {
+			server.Close()
+			ThirdPartyGitHub = thirdPartyGitHubImpl{}
+		})
+
+		It("passes when the rate limit endpoint accepts the token", func() {
+			status = 200
+			Expect(checkGitHubToken("", "token")(context.Background())).To(Succeed())
+		})
+
+		It("fails when the token is rejected or missing", func() {
+			status = 401
+			Expect(checkGitHubToken("", "token")(context.Background())).NotTo(Succeed())
+			Expect(checkGitHubToken("", "")(context.Background())).NotTo(Succeed())
+		})
+	})
+
+	Context("work directory", func() {
+		It("passes when a file can be written and leaves nothing behind", func() {
+			dir, err := ioutil.TempDir("", "workdir")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+			Config.Scanner.WorkDir = dir
+			Expect(checkWorkDir(context.Background())).To(Succeed())
+			files, _ := ioutil.ReadDir(dir)
+			Expect(files).To(BeEmpty())
+			Expect(checkFreeDisk(context.Background())).To(Succeed())
+		})
+
+		It("fails when the directory does not exist", func() {
+			Config.Scanner.WorkDir = filepath.Join(os.TempDir(), "missing-work-dir", "nested")
+			Expect(checkWorkDir(context.Background())).NotTo(Succeed())
+			Expect(checkFreeDisk(context.Background())).NotTo(Succeed())
+		})
+	})
+
+	It("fails while the job queue is full", func() {
+		previousQueue := JobQueue
+		defer func() { JobQueue = previousQueue }()
+		JobQueue = NewJobQueue(1, 0)
+		Expect(checkJobQueue(context.Background())).To(Succeed())
+		Expect(JobQueue.Enqueue(Job{ID: "1", Run: func(ctx context.Context) error { return nil }})).To(Succeed())
+		Expect(checkJobQueue(context.Background())).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,514 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"strings"
+	"time"
+)
+
+// create or update request and the pull request it resulted in
+type RequestRecord struct {
+	RequestID   string    `json:"request_id"`
+	Action      string    `json:"action"`
+	Provider    string    `json:"provider"`
+	Host        string    `json:"host"`
+	Owner       string    `json:"owner"`
+	Repo        string    `json:"repo"`
+	RequestedBy string    `json:"requested_by"`
+	StatusCode  int       `json:"status_code"`
+	Message     string    `json:"message"`
+	PullHead    string    `json:"pull_request_head"`
+	PullBase    string    `json:"pull_request_base"`
+	PullNumber  int       `json:"pull_request_number"`
+	PullURL     string    `json:"pull_request_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// is_secret value given to a secret by an update request, Authenticated is false when
+// DecidedBy was not authenticated, for the requests sent while authentication is off
+type AuditDecision struct {
+	RequestID     string    `json:"request_id"`
+	Owner         string    `json:"owner"`
+	Repo          string    `json:"repo"`
+	Filename      string    `json:"filename"`
+	HashedSecret  string    `json:"hashed_secret"`
+	IsSecret      bool      `json:"is_secret"`
+	DecidedBy     string    `json:"decided_by"`
+	Authenticated bool      `json:"authenticated"`
+	Justification string    `json:"justification"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// narrows the audit decisions returned for compliance reports, empty fields match everything
+type AuditFilter struct {
+	Owner     string
+	Repo      string
+	DecidedBy string
+	Since     time.Time
+	Until     time.Time
+}
+
+// progress of a create or update pipeline, saved after each step so a retry of the same
+// request reuses the fork, the pushed branch and the pull request instead of starting over.
+// ClaimedAt is set while a run holds the checkpoint and zero once it let it go
+type PipelineCheckpoint struct {
+	Key         string    `json:"key"`
+	PipelineID  string    `json:"pipeline_id"`
+	ForkOwner   string    `json:"fork_owner"`
+	Branch      string    `json:"branch"`
+	BaseBranch  string    `json:"base_branch"`
+	Pushed      bool      `json:"pushed"`
+	PullRequest int       `json:"pull_request"`
+	ClaimedAt   time.Time `json:"claimed_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RepoHistory struct {
+	Requests       []RequestRecord `json:"requests"`
+	AuditDecisions []AuditDecision `json:"audit_decisions"`
+}
+
+// records what the service did to each repo and the progress of the pipelines still running,
+// backed by SQLite or Postgres
+type historyStoreInterface interface {
+	SaveRequest(record RequestRecord) error
+	SaveAuditDecision(decision AuditDecision) error
+	RepoHistory(owner string, repo string) (RepoHistory, error)
+	AuditDecisions(filter AuditFilter) ([]AuditDecision, error)
+	SaveCheckpoint(checkpoint PipelineCheckpoint) error
+	Checkpoint(key string) (PipelineCheckpoint, bool, error)
+	ClaimCheckpoint(fresh PipelineCheckpoint, staleBefore time.Time, expiredBefore time.Time) (PipelineCheckpoint, bool, error)
+	ReleaseCheckpoint(key string) error
+	DeleteCheckpoint(key string) error
+	BeginIdempotentRequest(scope string, key string, fingerprint string, staleBefore time.Time, expiredBefore time.Time) (IdempotencyRecord, bool, error)
+	CompleteIdempotentRequest(scope string, key string, statusCode int, contentType string, body string) error
+	ForgetIdempotentRequest(scope string, key string) error
+	SaveRollout(report RolloutReport) error
+	UpdateRolloutRepo(id string, repo string, status string, message string) error
+	FinishRollout(id string) error
+	Rollout(id string) (RolloutReport, bool, error)
+}
+
+type sqlHistoryImplementation struct {
+	db     *sql.DB
+	driver string
+}
+
+// opens the database with a database/sql driver, sqlite3 or postgres, and creates the tables
+func OpenHistory(driver string, dsn string) (historyStoreInterface, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite3" {
+		// sqlite allows a single writer, sharing one connection avoids "database is locked"
+		db.SetMaxOpenConns(1)
+	}
+	store := sqlHistoryImplementation{db: db, driver: driver}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store sqlHistoryImplementation) migrate() error {
+	id := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if store.driver == "postgres" {
+		id = "BIGSERIAL PRIMARY KEY"
+	}
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS requests (
+			id ` + id + `,
+			request_id TEXT NOT NULL,
+			action TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			host TEXT NOT NULL,
+			owner TEXT NOT NULL,
+			repo TEXT NOT NULL,
+			requested_by TEXT NOT NULL,
+			status_code INTEGER NOT NULL,
+			message TEXT NOT NULL,
+			pull_request_head TEXT NOT NULL,
+			pull_request_base TEXT NOT NULL,
+			pull_request_number INTEGER NOT NULL DEFAULT 0,
+			pull_request_url TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS requests_repo ON requests (owner, repo)`,
+		`CREATE TABLE IF NOT EXISTS audit_decisions (
+			id ` + id + `,
+			request_id TEXT NOT NULL,
+			owner TEXT NOT NULL,
+			repo TEXT NOT NULL,
+			filename TEXT NOT NULL,
+			hashed_secret TEXT NOT NULL,
+			is_secret BOOLEAN NOT NULL,
+			decided_by TEXT NOT NULL,
+			authenticated BOOLEAN NOT NULL DEFAULT FALSE,
+			justification TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS audit_decisions_repo ON audit_decisions (owner, repo)`,
+		`CREATE TABLE IF NOT EXISTS pipeline_checkpoints (
+			pipeline_key TEXT PRIMARY KEY,
+			pipeline_id TEXT NOT NULL,
+			fork_owner TEXT NOT NULL,
+			branch TEXT NOT NULL,
+			base_branch TEXT NOT NULL,
+			pushed BOOLEAN NOT NULL,
+			pull_request INTEGER NOT NULL,
+			claimed_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			idempotency_scope TEXT NOT NULL,
+			idempotency_key TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			done BOOLEAN NOT NULL,
+			status_code INTEGER NOT NULL,
+			content_type TEXT NOT NULL,
+			body TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (idempotency_scope, idempotency_key)
+		)`,
+		`CREATE TABLE IF NOT EXISTS rollouts (
+			rollout_id TEXT PRIMARY KEY,
+			org TEXT NOT NULL,
+			status TEXT NOT NULL,
+			started_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS rollout_repos (
+			rollout_id TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			status TEXT NOT NULL,
+			message TEXT NOT NULL,
+			PRIMARY KEY (rollout_id, name)
+		)`,
+	}
+	for _, statement := range statements {
+		if _, err := store.db.Exec(statement); err != nil {
+			return fmt.Errorf("error creating the history tables: %v", err)
+		}
+	}
+	return nil
+}
+
+// queries are written with ? placeholders, postgres numbers them
+func (store sqlHistoryImplementation) rebind(query string) string {
+	if store.driver != "postgres" {
+		return query
+	}
+	var out strings.Builder
+	position := 0
+	for _, char := range query {
+		if char == '?' {
+			position++
+			out.WriteString(fmt.Sprintf("$%d", position))
+			continue
+		}
+		out.WriteRune(char)
+	}
+	return out.String()
+}
+
+func (store sqlHistoryImplementation) SaveRequest(record RequestRecord) error {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	_, err := store.db.Exec(store.rebind(`INSERT INTO requests (request_id, action, provider, host, owner, repo, requested_by,
+		status_code, message, pull_request_head, pull_request_base, pull_request_number, pull_request_url, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		record.RequestID, record.Action, record.Provider, record.Host, record.Owner, record.Repo, record.RequestedBy,
+		record.StatusCode, record.Message, record.PullHead, record.PullBase, record.PullNumber, record.PullURL, record.CreatedAt)
+	if err != nil {
+		ZeroLogger.Error().Msgf("Error saving request %s on %s/%s: %v", record.RequestID, record.Owner, record.Repo, err)
+	}
+	return err
+}
+
+func (store sqlHistoryImplementation) SaveAuditDecision(decision AuditDecision) error {
+	if decision.CreatedAt.IsZero() {
+		decision.CreatedAt = time.Now().UTC()
+	}
+	_, err := store.db.Exec(store.rebind(`INSERT INTO audit_decisions (request_id, owner, repo, filename, hashed_secret,
+		is_secret, decided_by, authenticated, justification, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		decision.RequestID, decision.Owner, decision.Repo, decision.Filename, decision.HashedSecret,
+		decision.IsSecret, decision.DecidedBy, decision.Authenticated, decision.Justification, decision.CreatedAt)
+	if err != nil {
+		ZeroLogger.Error().Msgf("Error saving the audit of %s on %s/%s: %v", decision.HashedSecret, decision.Owner, decision.Repo, err)
+	}
+	return err
+}
+
+// requests and audit decisions of the repo, oldest first
+func (store sqlHistoryImplementation) RepoHistory(owner string, repo string) (RepoHistory, error) {
+	history := RepoHistory{Requests: []RequestRecord{}, AuditDecisions: []AuditDecision{}}
+	rows, err := store.db.Query(store.rebind(`SELECT request_id, action, provider, host, owner, repo, requested_by, status_code,
+		message, pull_request_head, pull_request_base, pull_request_number, pull_request_url, created_at FROM requests
+		WHERE owner = ? AND repo = ? ORDER BY id`), owner, repo)
+	if err != nil {
+		return history, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var record RequestRecord
+		if err := rows.Scan(&record.RequestID, &record.Action, &record.Provider, &record.Host, &record.Owner, &record.Repo,
+			&record.RequestedBy, &record.StatusCode, &record.Message, &record.PullHead, &record.PullBase, &record.PullNumber,
+			&record.PullURL, &record.CreatedAt); err != nil {
+			return history, err
+		}
+		history.Requests = append(history.Requests, record)
+	}
+	if err := rows.Err(); err != nil {
+		return history, err
+	}
+
+	decisions, err := store.AuditDecisions(AuditFilter{Owner: owner, Repo: repo})
+	history.AuditDecisions = decisions
+	return history, err
+}
+
+// audit decisions matching the filter, oldest first
+func (store sqlHistoryImplementation) AuditDecisions(filter AuditFilter) ([]AuditDecision, error) {
+	query := `SELECT request_id, owner, repo, filename, hashed_secret, is_secret, decided_by, authenticated, justification,
+		created_at FROM audit_decisions WHERE 1 = 1`
+	var args []interface{}
+	for column, value := range map[string]string{"owner": filter.Owner, "repo": filter.Repo, "decided_by": filter.DecidedBy} {
+		if value != "" {
+			query += fmt.Sprintf(" AND %s = ?", column)
+			args = append(args, value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		query += " AND created_at < ?"
+		args = append(args, filter.Until.UTC())
+	}
+	rows, err := store.db.Query(store.rebind(query+" ORDER BY id"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	decisions := []AuditDecision{}
+	for rows.Next() {
+		var decision AuditDecision
+		if err := rows.Scan(&decision.RequestID, &decision.Owner, &decision.Repo, &decision.Filename, &decision.HashedSecret,
+			&decision.IsSecret, &decision.DecidedBy, &decision.Authenticated, &decision.Justification, &decision.CreatedAt); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions, rows.Err()
+}
+
+// replaces the checkpoint saved under the same key, a claimed checkpoint has its claim renewed
+func (store sqlHistoryImplementation) SaveCheckpoint(checkpoint PipelineCheckpoint) error {
+	checkpoint.UpdatedAt = time.Now().UTC()
+	if !checkpoint.ClaimedAt.IsZero() {
+		checkpoint.ClaimedAt = checkpoint.UpdatedAt
+	}
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(store.rebind(`DELETE FROM pipeline_checkpoints WHERE pipeline_key = ?`), checkpoint.Key); err != nil {
+		return err
+	}
+	if _, err := store.insertCheckpoint(tx, checkpoint, ""); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// inserts checkpoint with the statement ending in conflict, the queries share a transaction or
+// the database
+func (store sqlHistoryImplementation) insertCheckpoint(db interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, checkpoint PipelineCheckpoint, conflict string) (sql.Result, error) {
+	return db.Exec(store.rebind(`INSERT INTO pipeline_checkpoints (pipeline_key, pipeline_id, fork_owner, branch, base_branch,
+		pushed, pull_request, claimed_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`+conflict),
+		checkpoint.Key, checkpoint.PipelineID, checkpoint.ForkOwner, checkpoint.Branch, checkpoint.BaseBranch,
+		checkpoint.Pushed, checkpoint.PullRequest, checkpoint.ClaimedAt.UTC(), checkpoint.UpdatedAt.UTC())
+}
+
+func (store sqlHistoryImplementation) Checkpoint(key string) (PipelineCheckpoint, bool, error) {
+	var checkpoint PipelineCheckpoint
+	err := store.db.QueryRow(store.rebind(`SELECT pipeline_key, pipeline_id, fork_owner, branch, base_branch, pushed, pull_request,
+		claimed_at, updated_at FROM pipeline_checkpoints WHERE pipeline_key = ?`), key).Scan(&checkpoint.Key, &checkpoint.PipelineID,
+		&checkpoint.ForkOwner, &checkpoint.Branch, &checkpoint.BaseBranch, &checkpoint.Pushed, &checkpoint.PullRequest,
+		&checkpoint.ClaimedAt, &checkpoint.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return checkpoint, false, nil
+	}
+	return checkpoint, err == nil, err
+}
+
+// claims the checkpoint of fresh.Key, saving fresh when there is none. A checkpoint claimed
+// since staleBefore belongs to a run still going and is not returned, one not updated since
+// expiredBefore is dropped first. The insert and the claim are single statements so two runs
+// cannot both get the checkpoint
+func (store sqlHistoryImplementation) ClaimCheckpoint(fresh PipelineCheckpoint, staleBefore time.Time, expiredBefore time.Time) (PipelineCheckpoint, bool, error) {
+	if _, err := store.db.Exec(store.rebind(`DELETE FROM pipeline_checkpoints WHERE updated_at < ?`), expiredBefore.UTC()); err != nil {
+		return PipelineCheckpoint{}, false, err
+	}
+	now := time.Now().UTC()
+	fresh.ClaimedAt, fresh.UpdatedAt = now, now
+	result, err := store.insertCheckpoint(store.db, fresh, ` ON CONFLICT (pipeline_key) DO NOTHING`)
+	if err != nil {
+		return PipelineCheckpoint{}, false, err
+	}
+	if inserted, err := result.RowsAffected(); err != nil || inserted == 1 {
+		return fresh, err == nil, err
+	}
+	result, err = store.db.Exec(store.rebind(`UPDATE pipeline_checkpoints SET claimed_at = ? WHERE pipeline_key = ? AND claimed_at < ?`),
+		now, fresh.Key, staleBefore.UTC())
+	if err != nil {
+		return PipelineCheckpoint{}, false, err
+	}
+	if claimed, err := result.RowsAffected(); err != nil || claimed != 1 {
+		return PipelineCheckpoint{}, false, err
+	}
+	checkpoint, found, err := store.Checkpoint(fresh.Key)
+	return checkpoint, found, err
+}
+
+// lets another run claim the checkpoint of key
+func (store sqlHistoryImplementation) ReleaseCheckpoint(key string) error {
+	_, err := store.db.Exec(store.rebind(`UPDATE pipeline_checkpoints SET claimed_at = ? WHERE pipeline_key = ?`), time.Time{}.UTC(), key)
+	return err
+}
+
+func (store sqlHistoryImplementation) DeleteCheckpoint(key string) error {
+	_, err := store.db.Exec(store.rebind(`DELETE FROM pipeline_checkpoints WHERE pipeline_key = ?`), key)
+	return err
+}
+
+// records the key of scope as in flight, or returns the record of the earlier request with the
+// same key. The insert is skipped on conflict so two concurrent requests cannot both run. A
+// request in flight since staleBefore is taken to have died with its process, the key is
+// claimed again for the retry
+func (store sqlHistoryImplementation) BeginIdempotentRequest(scope string, key string, fingerprint string, staleBefore time.Time, expiredBefore time.Time) (IdempotencyRecord, bool, error) {
+	if _, err := store.db.Exec(store.rebind(`DELETE FROM idempotency_keys WHERE created_at < ?`), expiredBefore.UTC()); err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	now := time.Now().UTC()
+	record := IdempotencyRecord{Fingerprint: fingerprint, CreatedAt: now}
+	result, err := store.db.Exec(store.rebind(`INSERT INTO idempotency_keys (idempotency_scope, idempotency_key, fingerprint, done,
+		status_code, content_type, body, created_at, started_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (idempotency_scope, idempotency_key) DO NOTHING`),
+		scope, key, record.Fingerprint, false, 0, "", "", now, now)
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	if inserted, err := result.RowsAffected(); err != nil || inserted == 1 {
+		return IdempotencyRecord{}, false, err
+	}
+	err = store.db.QueryRow(store.rebind(`SELECT fingerprint, done, status_code, content_type, body, created_at FROM idempotency_keys
+		WHERE idempotency_scope = ? AND idempotency_key = ?`), scope, key).Scan(&record.Fingerprint, &record.Done,
+		&record.StatusCode, &record.ContentType, &record.Body, &record.CreatedAt)
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	if record.Fingerprint != fingerprint {
+		return IdempotencyRecord{}, true, ErrIdempotencyKeyReused
+	}
+	if record.Done {
+		return record, true, nil
+	}
+	result, err = store.db.Exec(store.rebind(`UPDATE idempotency_keys SET started_at = ?
+		WHERE idempotency_scope = ? AND idempotency_key = ? AND done = ? AND started_at < ?`), now, scope, key, false, staleBefore.UTC())
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	if reclaimed, err := result.RowsAffected(); err != nil || reclaimed == 1 {
+		return IdempotencyRecord{}, false, err
+	}
+	return record, true, nil
+}
+
+func (store sqlHistoryImplementation) CompleteIdempotentRequest(scope string, key string, statusCode int, contentType string, body string) error {
+	_, err := store.db.Exec(store.rebind(`UPDATE idempotency_keys SET done = ?, status_code = ?, content_type = ?, body = ?
+		WHERE idempotency_scope = ? AND idempotency_key = ?`), true, statusCode, contentType, body, scope, key)
+	return err
+}
+
+func (store sqlHistoryImplementation) ForgetIdempotentRequest(scope string, key string) error {
+	_, err := store.db.Exec(store.rebind(`DELETE FROM idempotency_keys WHERE idempotency_scope = ? AND idempotency_key = ?`), scope, key)
+	return err
+}
+
+// saves the rollout and its repos, replacing the rollout saved under the same id
+func (store sqlHistoryImplementation) SaveRollout(report RolloutReport) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, statement := range []string{`DELETE FROM rollout_repos WHERE rollout_id = ?`, `DELETE FROM rollouts WHERE rollout_id = ?`} {
+		if _, err := tx.Exec(store.rebind(statement), report.ID); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(store.rebind(`INSERT INTO rollouts (rollout_id, org, status, started_at) VALUES (?, ?, ?, ?)`),
+		report.ID, report.Org, report.Status, report.StartedAt.UTC())
+	if err != nil {
+		return err
+	}
+	for position, repo := range report.Repos {
+		_, err = tx.Exec(store.rebind(`INSERT INTO rollout_repos (rollout_id, position, name, status, message) VALUES (?, ?, ?, ?, ?)`),
+			report.ID, position, repo.Name, repo.Status, repo.Message)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (store sqlHistoryImplementation) UpdateRolloutRepo(id string, repo string, status string, message string) error {
+	_, err := store.db.Exec(store.rebind(`UPDATE rollout_repos SET status = ?, message = ? WHERE rollout_id = ? AND name = ?`),
+		status, message, id, repo)
+	return err
+}
+
+func (store sqlHistoryImplementation) FinishRollout(id string) error {
+	_, err := store.db.Exec(store.rebind(`UPDATE rollouts SET status = ? WHERE rollout_id = ?`), RolloutDone, id)
+	return err
+}
+
+// the rollout with its repos in the order they were listed, Counts is left to the caller
+func (store sqlHistoryImplementation) Rollout(id string) (RolloutReport, bool, error) {
+	var report RolloutReport
+	err := store.db.QueryRow(store.rebind(`SELECT rollout_id, org, status, started_at FROM rollouts WHERE rollout_id = ?`), id).
+		Scan(&report.ID, &report.Org, &report.Status, &report.StartedAt)
+	if err == sql.ErrNoRows {
+		return report, false, nil
+	}
+	if err != nil {
+		return report, false, err
+	}
+	rows, err := store.db.Query(store.rebind(`SELECT name, status, message FROM rollout_repos WHERE rollout_id = ? ORDER BY position`), id)
+	if err != nil {
+		return report, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var repo RolloutRepo
+		if err := rows.Scan(&repo.Name, &repo.Status, &repo.Message); err != nil {
+			return report, false, err
+		}
+		report.Repos = append(report.Repos, repo)
+	}
+	report.Total = len(report.Repos)
+	return report, true, rows.Err()
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("History", func() {
+	var store historyStoreInterface
+
+	BeforeEach(func() {
+		var err error
+		store, err = OpenHistory("sqlite3", ":memory:")
+		Expect(err).To(BeNil())
+	})
+
+	It("returns the requests and audit decisions of a repo in order", func() {
+		decidedAt := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+		Expect(store.SaveRequest(RequestRecord{RequestID: "r1", Action: "Create", Provider: "github", Owner: "john", Repo: "repo",
+			RequestedBy: "alice", StatusCode: 200, Message: "PR was Created !", PullHead: "bot:branch", PullBase: "main",
+			PullNumber: 4, PullURL: "https://github.com/john/repo/pull/4"})).To(Succeed())
+		Expect(store.SaveRequest(RequestRecord{RequestID: "r2", Action: "Update", Owner: "john", Repo: "repo"})).To(Succeed())
+		Expect(store.SaveRequest(RequestRecord{RequestID: "r3", Action: "Create", Owner: "john", Repo: "other"})).To(Succeed())
+		Expect(store.SaveAuditDecision(AuditDecision{RequestID: "r2", Owner: "john", Repo: "repo", Filename: "a.py",
+			HashedSecret: "aaa", IsSecret: false, DecidedBy: "bob", CreatedAt: decidedAt})).To(Succeed())
+
+		history, err := store.RepoHistory("john", "repo")
+		Expect(err).To(BeNil())
+		Expect(history.Requests).To(HaveLen(2))
+		Expect(history.Requests[0].RequestID).To(Equal("r1"))
+		Expect(history.Requests[0].PullHead).To(Equal("bot:branch"))
+		Expect(history.Requests[0].PullNumber).To(Equal(4))
+		Expect(history.Requests[0].PullURL).To(Equal("https://github.com/john/repo/pull/4"))
+		Expect(history.Requests[0].CreatedAt.IsZero()).To(BeFalse())
+		Expect(history.Requests[1].RequestID).To(Equal("r2"))
+		Expect(history.AuditDecisions).To(HaveLen(1))
+		Expect(history.AuditDecisions[0].DecidedBy).To(Equal("bob"))
+		Expect(history.AuditDecisions[0].CreatedAt.Equal(decidedAt)).To(BeTrue())
+	})
+
+	It("keeps the history of a database opened again", func() {
+		path, err := ioutil.TempDir("", "history")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(path)
+		dsn := filepath.Join(path, "history.db")
+		first, err := OpenHistory("sqlite3", dsn)
+		Expect(err).To(BeNil())
+		Expect(first.SaveRequest(RequestRecord{RequestID: "r1", Owner: "john", Repo: "repo", PullNumber: 2})).To(Succeed())
+
+		reopened, err := OpenHistory("sqlite3", dsn)
+		Expect(err).To(BeNil())
+		history, err := reopened.RepoHistory("john", "repo")
+		Expect(err).To(BeNil())
+		Expect(history.Requests).To(HaveLen(1))
+		Expect(history.Requests[0].PullNumber).To(Equal(2))
+	})
+
+	It("returns empty lists for a repo without history", func() {
+		history, err := store.RepoHistory("john", "missing")
+		Expect(err).To(BeNil())
+		Expect(history.Requests).To(BeEmpty())
+		Expect(history.AuditDecisions).NotTo(BeNil())
+	})
+
+	It("keeps the last checkpoint of a pipeline until it is deleted", func() {
+		_, found, err := store.Checkpoint("key")
+		Expect(err).To(BeNil())
+		Expect(found).To(BeFalse())
+
+		Expect(store.SaveCheckpoint(PipelineCheckpoint{Key: "key", PipelineID: "p1", ForkOwner: "bot"})).To(Succeed())
+		Expect(store.SaveCheckpoint(PipelineCheckpoint{Key: "key", PipelineID: "p1", ForkOwner: "bot", Branch: "branch",
+			BaseBranch: "main", Pushed: true})).To(Succeed())
+		checkpoint, found, err := store.Checkpoint("key")
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(checkpoint.PipelineID).To(Equal("p1"))
+		Expect(checkpoint.Branch).To(Equal("branch"))
+		Expect(checkpoint.Pushed).To(BeTrue())
+		Expect(checkpoint.UpdatedAt.IsZero()).To(BeFalse())
+
+		Expect(store.DeleteCheckpoint("key")).To(Succeed())
+		_, found, err = store.Checkpoint("key")
+		Expect(err).To(BeNil())
+		Expect(found).To(BeFalse())
+	})
+
+	It("lets one run at a time claim a checkpoint and drops the expired ones", func() {
+		now := time.Now()
+		checkpoint, claimed, err := store.ClaimCheckpoint(PipelineCheckpoint{Key: "key", PipelineID: "p1"}, now.Add(-time.Hour), now.Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(claimed).To(BeTrue())
+		Expect(checkpoint.PipelineID).To(Equal("p1"))
+		checkpoint.ForkOwner = "bot"
+		Expect(store.SaveCheckpoint(checkpoint)).To(Succeed())
+
+		_, claimed, err = store.ClaimCheckpoint(PipelineCheckpoint{Key: "key", PipelineID: "p2"}, now.Add(-time.Hour), now.Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(claimed).To(BeFalse())
+
+		Expect(store.ReleaseCheckpoint("key")).To(Succeed())
+		checkpoint, claimed, err = store.ClaimCheckpoint(PipelineCheckpoint{Key: "key", PipelineID: "p3"}, now.Add(-time.Hour), now.Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(claimed).To(BeTrue())
+		Expect(checkpoint.PipelineID).To(Equal("p1"))
+		Expect(checkpoint.ForkOwner).To(Equal("bot"))
+
+		_, claimed, _ = store.ClaimCheckpoint(PipelineCheckpoint{Key: "key", PipelineID: "p4"}, time.Now().Add(time.Second), now.Add(-time.Hour))
+		Expect(claimed).To(BeTrue())
+		checkpoint, claimed, err = store.ClaimCheckpoint(PipelineCheckpoint{Key: "key", PipelineID: "p5"}, now.Add(-time.Hour), time.Now().Add(time.Second))
+		Expect(err).To(BeNil())
+		Expect(claimed).To(BeTrue())
+		Expect(checkpoint.PipelineID).To(Equal("p5"))
+		Expect(checkpoint.ForkOwner).To(BeEmpty())
+	})
+
+	It("keeps the idempotency keys of each scope until they expire", func() {
+		_, exists, err := store.BeginIdempotentRequest("api_key:ci", "key", "fp", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeFalse())
+		record, exists, err := store.BeginIdempotentRequest("api_key:ci", "key", "fp", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeTrue())
+		Expect(record.Done).To(BeFalse())
+
+		Expect(store.CompleteIdempotentRequest("api_key:ci", "key", 200, "text/plain", "PR was Created !")).To(Succeed())
+		record, _, err = store.BeginIdempotentRequest("api_key:ci", "key", "fp", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(record.Done).To(BeTrue())
+		Expect(record.StatusCode).To(Equal(200))
+		Expect(record.ContentType).To(Equal("text/plain"))
+		Expect(record.Body).To(Equal("PR was Created !"))
+		_, _, err = store.BeginIdempotentRequest("api_key:ci", "key", "other", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+		Expect(err).To(Equal(ErrIdempotencyKeyReused))
+
+		_, exists, err = store.BeginIdempotentRequest("oidc:alice", "key", "other", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeFalse())
+		_, exists, err = store.BeginIdempotentRequest("api_key:ci", "key", "other", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeFalse())
+
+		Expect(store.ForgetIdempotentRequest("api_key:ci", "key")).To(Succeed())
+		_, exists, err = store.BeginIdempotentRequest("api_key:ci", "key", "fp", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeFalse())
+	})
+
+	It("lets a retry take over a key left in flight for longer than the lease", func() {
+		_, exists, err := store.BeginIdempotentRequest("api_key:ci", "key", "fp", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeFalse())
+		_, exists, err = store.BeginIdempotentRequest("api_key:ci", "key", "fp", time.Now().Add(time.Second), time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeFalse())
+		record, exists, err := store.BeginIdempotentRequest("api_key:ci", "key", "fp", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeTrue())
+		Expect(record.Done).To(BeFalse())
+
+		Expect(store.CompleteIdempotentRequest("api_key:ci", "key", 200, "application/json", "{}")).To(Succeed())
+		record, exists, err = store.BeginIdempotentRequest("api_key:ci", "key", "fp", time.Now().Add(time.Second), time.Now().Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeTrue())
+		Expect(record.Done).To(BeTrue())
+	})
+
+	It("keeps the rollouts with the status of each repo in order", func() {
+		started := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+		report := RolloutReport{ID: "r1", Org: "acme", Status: RolloutRunning, StartedAt: started, Total: 2,
+			Repos: []RolloutRepo{{Name: "web", Status: RolloutPending}, {Name: "api", Status: RolloutPending}}}
+		Expect(store.SaveRollout(report)).To(Succeed())
+		Expect(store.UpdateRolloutRepo("r1", "api", RolloutCreated, "pull request #1")).To(Succeed())
+		Expect(store.FinishRollout("r1")).To(Succeed())
+
+		saved, ok, err := store.Rollout("r1")
+		Expect(err).To(BeNil())
+		Expect(ok).To(BeTrue())
+		Expect(saved.Status).To(Equal(RolloutDone))
+		Expect(saved.StartedAt.Equal(started)).To(BeTrue())
+		Expect(saved.Repos).To(Equal([]RolloutRepo{{Name: "web", Status: RolloutPending}, {Name: "api", Status: RolloutCreated, Message: "pull request #1"}}))
+
+		report.Repos = report.Repos[:1]
+		Expect(store.SaveRollout(report)).To(Succeed())
+		saved, _, _ = store.Rollout("r1")
+		Expect(saved.Status).To(Equal(RolloutRunning))
+		Expect(saved.Repos).To(HaveLen(1))
+		_, ok, err = store.Rollout("missing")
+		Expect(err).To(BeNil())
+		Expect(ok).To(BeFalse())
+	})
+
+	It("numbers the placeholders for postgres", func() {
+		postgres := sqlHistoryImplementation{driver: "postgres"}
+		Expect(postgres.rebind("WHERE owner = ? AND repo = ?")).To(Equal("WHERE owner = $1 AND repo = $2"))
+		Expect(sqlHistoryImplementation{driver: "sqlite3"}.rebind("owner = ?")).To(Equal("owner = ?"))
+	})
+})
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+var ErrIdempotencyKeyReused = NewServiceError(ErrCodeConflict, "idempotency key was already used with a different request", nil)
+
+// outcome of the first request sent with an idempotency key, Done is false while it runs
+type IdempotencyRecord struct {
+	Fingerprint string
+	Done        bool
+	StatusCode  int
+	ContentType string
+	Body        string
+	CreatedAt   time.Time
+}
+
+// remembers the requests sent with an Idempotency-Key header so retries get the original result.
+// Keys are scoped by the caller, the same key sent by two callers names two requests
+type idempotencyStoreInterface interface {
+	Begin(scope string, key string, fingerprint string) (IdempotencyRecord, bool, error)
+	Complete(scope string, key string, statusCode int, contentType string, body string) error
+	Forget(scope string, key string) error
+}
+
+// keeps the records in the history database so they survive restarts and are shared by replicas.
+// A request in flight for longer than lease is taken over by its next retry
+type idempotencyStoreImplementation struct {
+	retention time.Duration
+	lease     time.Duration
+}
+
+func NewIdempotencyStore(retention time.Duration, lease time.Duration) idempotencyStoreInterface {
+	return idempotencyStoreImplementation{retention: retention, lease: lease}
+}
+
+// hash identifying a request, two requests reusing a key must have the same one
+func RequestFingerprint(method string, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// records the key as in flight, or returns the record of the earlier request with the same key
+// sent in the retention window
+func (store idempotencyStoreImplementation) Begin(scope string, key string, fingerprint string) (IdempotencyRecord, bool, error) {
+	now := time.Now()
+	return History.BeginIdempotentRequest(scope, key, fingerprint, now.Add(-store.lease), now.Add(-store.retention))
+}
+
+func (store idempotencyStoreImplementation) Complete(scope string, key string, statusCode int, contentType string, body string) error {
+	return History.CompleteIdempotentRequest(scope, key, statusCode, contentType, body)
+}
+
+func (store idempotencyStoreImplementation) Forget(scope string, key string) error {
+	return History.ForgetIdempotentRequest(scope, key)
+}
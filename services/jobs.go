@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull   = NewServiceError(ErrCodeUnavailable, "job queue is full", nil)
+	ErrQueueClosed = NewServiceError(ErrCodeUnavailable, "job queue is shutting down", nil)
+)
+
+// unit of background work, Run is executed by one of the queue workers. Host is the GitHub
+// host the job calls, its start is delayed while the quota of the host is running out.
+// RequestID is the id of the request that enqueued the job, its logs are tagged with it.
+// Kind and Params describe the job so it can be saved at shutdown and rebuilt on the next start
+type Job struct {
+	ID          string                          `json:"id"`
+	Name        string                          `json:"name"`
+	Host        string                          `json:"host,omitempty"`
+	RequestID   string                          `json:"request_id,omitempty"`
+	Kind        string                          `json:"kind"`
+	Params      json.RawMessage                 `json:"params,omitempty"`
+	Interrupted bool                            `json:"interrupted,omitempty"`
+	Run         func(ctx context.Context) error `json:"-"`
+}
+
+type jobQueueInterface interface {
+	Enqueue(job Job) error
+	Depth() int
+	Capacity() int
+	Closed() bool
+	Shutdown(ctx context.Context) ([]Job, error)
+}
+
+type jobQueueState struct {
+	closed  bool
+	running map[string]Job
+}
+
+type jobQueueImplementation struct {
+	jobs    chan Job
+	stop    chan struct{}
+	mutex   *sync.Mutex
+	state   *jobQueueState
+	workers *sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func NewJobQueue(size int, workers int) jobQueueInterface {
+	ctx, cancel := context.WithCancel(context.Background())
+	queue := jobQueueImplementation{
+		jobs:    make(chan Job, size),
+		stop:    make(chan struct{}),
+		mutex:   &sync.Mutex{},
+		state:   &jobQueueState{running: map[string]Job{}},
+		workers: &sync.WaitGroup{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	for i := 0; i < workers; i++ {
+		queue.workers.Add(1)
+		go queue.work()
+	}
+	return queue
+}
+
+// runs jobs until the queue is shut down, the job in progress is finished first
+func (queue jobQueueImplementation) work() {
+	defer queue.workers.Done()
+	for {
+		select {
+		case <-queue.stop:
+			return
+		default:
+		}
+		select {
+		case <-queue.stop:
+			return
+		case job := <-queue.jobs:
+			queue.run(job)
+		}
+	}
+}
+
+func (queue jobQueueImplementation) run(job Job) {
+	requestID := job.RequestID
+	if requestID == "" {
+		requestID = job.ID
+	}
+	ctx := WithLogFields(WithRequestID(queue.ctx, requestID), "job_id", job.ID)
+	queue.mutex.Lock()
+	queue.state.running[job.ID] = job
+	queue.mutex.Unlock()
+	defer func() {
+		queue.mutex.Lock()
+		delete(queue.state.running, job.ID)
+		queue.mutex.Unlock()
+	}()
+
+	if delay := GitHubRateLimits.JobDelay(job.Host); delay > 0 {
+		Logger(ctx).Info().Msgf("GitHub rate limit is running out, job %s starts in %s", job.ID, delay.Round(time.Second))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+	Logger(ctx).Info().Msgf("Starting job %s: %s", job.ID, job.Name)
+	ctx, span := Tracer.Start(ctx, "job", trace.WithAttributes(attribute.String("job.id", job.ID), attribute.String("job.name", job.Name)))
+	err := job.Run(ctx)
+	EndSpan(span, err)
+	if err != nil {
+		Logger(ctx).Error().Msgf("Job %s failed: %v", job.ID, err)
+		return
+	}
+	Logger(ctx).Info().Msgf("Job %s finished", job.ID)
+}
+
+// adds the job without blocking, returns ErrQueueFull when there is no room left and
+// ErrQueueClosed once the queue is shutting down
+func (queue jobQueueImplementation) Enqueue(job Job) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.state.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case queue.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (queue jobQueueImplementation) Depth() int {
+	return len(queue.jobs)
+}
+
+func (queue jobQueueImplementation) Capacity() int {
+	return cap(queue.jobs)
+}
+
+func (queue jobQueueImplementation) Closed() bool {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return queue.state.closed
+}
+
+// stops accepting jobs and waits until ctx is done for the running ones. Returns the jobs
+// that did not finish: the ones still waiting and, when ctx expired first, the running ones
+// marked as interrupted after cancelling their context. It returns once every worker has
+// stopped, so no job still uses its clone when the caller removes them
+func (queue jobQueueImplementation) Shutdown(ctx context.Context) ([]Job, error) {
+	queue.mutex.Lock()
+	if queue.state.closed {
+		queue.mutex.Unlock()
+		return nil, nil
+	}
+	queue.state.closed = true
+	close(queue.stop)
+	queue.mutex.Unlock()
+
+	var unfinished []Job
+	for waiting := true; waiting; {
+		select {
+		case job := <-queue.jobs:
+			unfinished = append(unfinished, job)
+		default:
+			waiting = false
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		queue.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return unfinished, nil
+	case <-ctx.Done():
+	}
+	queue.cancel()
+	queue.mutex.Lock()
+	for _, job := range queue.state.running {
+		job.Interrupted = true
+		unfinished = append(unfinished, job)
+	}
+	queue.mutex.Unlock()
+	<-done
+	return unfinished, ctx.Err()
+}
+
+// writes the jobs to path for LoadPendingJobs, the file is removed when there are none
+func SavePendingJobs(path string, jobs []Job) error {
+	if len(jobs) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	content, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// reads the jobs saved by SavePendingJobs, their Run is rebuilt by the caller from Kind and
+// Params. The file is kept until the caller saves the jobs it could not enqueue over it
+func LoadPendingJobs(path string) ([]Job, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jobs []Job
+	if err := json.Unmarshal(content, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
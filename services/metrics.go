@@ -0,0 +1,186 @@
+package services
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_scanner_http_requests_total",
+		Help: "API requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "secret_scanner_http_request_duration_seconds",
+		Help:    "API request latency by route, method and status.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"route", "method", "status"})
+	PipelineStepDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "secret_scanner_pipeline_step_duration_seconds",
+		Help:    "Duration of the steps of the create and update pipeline.",
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"step"})
+	GitHubAPIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_scanner_github_api_requests_total",
+		Help: "GitHub API calls by endpoint and status, status is error when no response was received.",
+	}, []string{"method", "endpoint", "status"})
+	GitHubRateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "secret_scanner_github_rate_limit_remaining",
+		Help: "Requests left in the core rate limit of each GitHub host.",
+	}, []string{"host"})
+	RetryAttemptCounts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_scanner_retry_attempts_total",
+		Help: "Attempts made by Retry by operation.",
+	}, []string{"operation"})
+	RetryExhaustedCounts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_scanner_retry_exhausted_total",
+		Help: "Operations that still failed after the last attempt of Retry.",
+	}, []string{"operation"})
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "secret_scanner_job_queue_depth",
+		Help: "Jobs waiting for a worker.",
+	}, func() float64 {
+		// main builds the queue, a gather before that reports it empty
+		if JobQueue == nil {
+			return 0
+		}
+		return float64(JobQueue.Depth())
+	})
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "secret_scanner_clone_cache_bytes",
+		Help: "Disk used by the repos cloned on this instance.",
+	}, func() float64 {
+		return float64(Clones.Size())
+	})
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "secret_scanner_clone_cache_repos",
+		Help: "Repos cloned on this instance that are still on disk.",
+	}, func() float64 {
+		return float64(len(Clones.Paths()))
+	})
+)
+
+// records the duration of a pipeline step started at started
+func ObservePipelineStep(step string, started time.Time) {
+	PipelineStepDuration.WithLabelValues(step).Observe(time.Since(started).Seconds())
+}
+
+var (
+	githubNumber = regexp.MustCompile(`^[0-9]+$`)
+	githubSHA    = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// path of a GitHub API call with the owner, repo, numbers and file paths replaced by
+// placeholders, keeps the endpoint label bounded
+func githubEndpoint(path string) string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "/api/v3"), "/")
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case i == 1 && (segments[0] == "orgs" || segments[0] == "users"):
+			segments[i] = "{" + strings.TrimSuffix(segments[0], "s") + "}"
+		case i == 1 && segments[0] == "repos":
+			segments[i] = "{owner}"
+		case i == 2 && segments[0] == "repos":
+			segments[i] = "{repo}"
+		case i > 0 && segments[i-1] == "contents":
+			return "/" + strings.Join(append(segments[:i], "{path}"), "/")
+		case i > 0 && segments[i-1] == "collaborators":
+			segments[i] = "{user}"
+		case githubNumber.MatchString(segment):
+			segments[i] = "{number}"
+		case githubSHA.MatchString(segment):
+			segments[i] = "{sha}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+func countGitHubRequest(request *http.Request, response *http.Response) {
+	status := "error"
+	if response != nil {
+		status = strconv.Itoa(response.StatusCode)
+	}
+	GitHubAPIRequests.WithLabelValues(request.Method, githubEndpoint(request.URL.Path), status).Inc()
+}
+
+type cloneRegistryInterface interface {
+	Add(path string)
+	Remove(path string) error
+	Paths() []string
+	Size() int64
+	RemoveAll() error
+}
+
+type cloneRegistryImplementation struct {
+	mutex *sync.Mutex
+	paths map[string]bool
+}
+
+// work directories cloned by this process, the ones removed from disk are dropped
+func NewCloneRegistry() cloneRegistryInterface {
+	return cloneRegistryImplementation{mutex: &sync.Mutex{}, paths: map[string]bool{}}
+}
+
+func (registry cloneRegistryImplementation) Add(path string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.paths[path] = true
+}
+
+// deletes the clone at path once its flow is done, paths this process did not clone are left alone
+func (registry cloneRegistryImplementation) Remove(path string) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if !registry.paths[path] {
+		return nil
+	}
+	delete(registry.paths, path)
+	return os.RemoveAll(path)
+}
+
+func (registry cloneRegistryImplementation) Paths() []string {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	var paths []string
+	for path := range registry.paths {
+		if _, err := os.Stat(path); err != nil {
+			delete(registry.paths, path)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func (registry cloneRegistryImplementation) Size() int64 {
+	var size int64
+	for _, path := range registry.Paths() {
+		_ = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				size += info.Size()
+			}
+			return nil
+		})
+	}
+	return size
+}
+
+// deletes every clone still on disk, used at shutdown for the pipelines that did not finish
+func (registry cloneRegistryImplementation) RemoveAll() error {
+	var failed error
+	for _, path := range registry.Paths() {
+		if err := os.RemoveAll(path); err != nil {
+			failed = err
+		}
+	}
+	return failed
+}
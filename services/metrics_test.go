@@ -0,0 +1,82 @@
+package services
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+)
+
+var _ = Describe("Metrics", func() {
+	It("replaces the owner, repo, numbers and file paths of GitHub endpoints", func() {
+		Expect(githubEndpoint("/repos/john/repo/pulls")).To(Equal("/repos/{owner}/{repo}/pulls"))
+		Expect(githubEndpoint("/api/v3/repos/john/repo/pulls/7/comments")).To(Equal("/repos/{owner}/{repo}/pulls/{number}/comments"))
+		Expect(githubEndpoint("/repos/john/repo/contents/src/.secrets.baseline")).To(Equal("/repos/{owner}/{repo}/contents/{path}"))
+		Expect(githubEndpoint("/repos/john/repo/collaborators/alice/permission")).To(Equal("/repos/{owner}/{repo}/collaborators/{user}/permission"))
+		Expect(githubEndpoint("/orgs/acme/repos")).To(Equal("/orgs/{org}/repos"))
+		Expect(githubEndpoint("/repos/john/repo/commits/0123456789abcdef0123456789abcdef01234567")).To(Equal("/repos/{owner}/{repo}/commits/{sha}"))
+	})
+
+	It("counts the GitHub calls sent through the rate limit transport", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(404)
+		}))
+		defer server.Close()
+		counter := GitHubAPIRequests.WithLabelValues("GET", "/repos/{owner}/{repo}", "404")
+		before := testutil.ToFloat64(counter)
+		client := &http.Client{Transport: NewRateLimitTransport("", nil)}
+		_, err := client.Get(server.URL + "/repos/john/repo")
+		Expect(err).To(BeNil())
+		Expect(testutil.ToFloat64(counter)).To(Equal(before + 1))
+	})
+
+	It("reports an empty job queue until it is built", func() {
+		previous := JobQueue
+		defer func() { JobQueue = previous }()
+		JobQueue = nil
+		expected := "# HELP secret_scanner_job_queue_depth Jobs waiting for a worker.\n# TYPE secret_scanner_job_queue_depth gauge\nsecret_scanner_job_queue_depth 0\n"
+		Expect(testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(expected), "secret_scanner_job_queue_depth")).To(Succeed())
+	})
+
+	It("reports the disk used by the clones still on disk", func() {
+		registry := NewCloneRegistry()
+		path, err := ioutil.TempDir("", "clone")
+		Expect(err).To(BeNil())
+		Expect(ioutil.WriteFile(path+"/a.py", []byte("12345"), 0644)).To(Succeed())
+		registry.Add(path)
+		registry.Add(path + "-missing")
+		Expect(registry.Paths()).To(Equal([]string{path}))
+		Expect(registry.Size()).To(Equal(int64(5)))
+		Expect(os.RemoveAll(path)).To(Succeed())
+		Expect(registry.Paths()).To(BeEmpty())
+	})
+
+	It("removes the clones left on disk", func() {
+		registry := NewCloneRegistry()
+		path, err := ioutil.TempDir("", "clone")
+		Expect(err).To(BeNil())
+		registry.Add(path)
+		Expect(registry.RemoveAll()).To(Succeed())
+		Expect(path).NotTo(BeADirectory())
+		Expect(registry.Paths()).To(BeEmpty())
+	})
+	It("removes a finished clone and leaves the unknown paths alone", func() {
+		registry := NewCloneRegistry()
+		path, err := ioutil.TempDir("", "clone")
+		Expect(err).To(BeNil())
+		other, err := ioutil.TempDir("", "other")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(other)
+		registry.Add(path)
+		Expect(registry.Remove(path)).To(Succeed())
+		Expect(path).NotTo(BeADirectory())
+		Expect(registry.Paths()).To(BeEmpty())
+		Expect(registry.Remove(other)).To(Succeed())
+		Expect(other).To(BeADirectory())
+	})
+})
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var conventionalCommitRegex = regexp.MustCompile(`^[a-z]+(\([\w\-./]+\))?!?: \S.*$`)
+
+type namingParams struct {
+	Owner     string
+	Repo      string
+	Action    string
+	RequestID string
+}
+
+// short random id added to branch names so concurrent requests on the same repo don't collide
+func NewRequestID() string {
+	buffer := make([]byte, 4)
+	if _, err := rand.Read(buffer); err != nil {
+		ZeroLogger.Error().Msgf("Error generating request id: %v", err)
+		return "00000000"
+	}
+	return hex.EncodeToString(buffer)
+}
+
+func namingTemplateFor(owner string) NamingTemplate {
+	naming := Config.Templates.Default
+	orgNaming, ok := Config.Templates.Orgs[owner]
+	if !ok {
+		return naming
+	}
+	if orgNaming.Branch != "" {
+		naming.Branch = orgNaming.Branch
+	}
+	if orgNaming.Commit != "" {
+		naming.Commit = orgNaming.Commit
+	}
+	return naming
+}
+
+func renderNamingTemplate(name string, source string, params namingParams) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, params); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+func BranchName(owner string, repo string, action string, requestID string) (string, error) {
+	params := namingParams{Owner: owner, Repo: repo, Action: action, RequestID: requestID}
+	branch, err := renderNamingTemplate("branch", namingTemplateFor(owner).Branch, params)
+	if err != nil {
+		return "", err
+	}
+	if err := ValidateBranchName(branch); err != nil {
+		return "", err
+	}
+	return branch, nil
+}
+
+// title of the pull or merge request opened for the action, the same on every provider
+func PullRequestTitle(action string) string {
+	return fmt.Sprintf("[Detect Secrets] %s Secret BaseLine File", action)
+}
+
+func CommitMessage(owner string, repo string, action string, requestID string) (string, error) {
+	params := namingParams{Owner: owner, Repo: repo, Action: action, RequestID: requestID}
+	message, err := renderNamingTemplate("commit", namingTemplateFor(owner).Commit, params)
+	if err != nil {
+		return "", err
+	}
+	subject := strings.SplitN(message, "\n", 2)[0]
+	if !conventionalCommitRegex.MatchString(subject) {
+		return "", NewServiceError(ErrCodeInvalidInput, fmt.Sprintf("commit message %q is not a conventional commit", subject), nil)
+	}
+	return message, nil
+}
+
+// follows the rules of git check-ref-format for a branch name
+func ValidateBranchName(branch string) error {
+	invalid := func(reason string) error {
+		return NewServiceError(ErrCodeInvalidInput, fmt.Sprintf("invalid branch name %q: %s", branch, reason), nil)
+	}
+	if branch == "" || branch == "@" {
+		return invalid("empty or '@'")
+	}
+	if strings.HasPrefix(branch, "/") || strings.HasSuffix(branch, "/") || strings.HasPrefix(branch, "-") {
+		return invalid("cannot begin with '/' or '-' or end with '/'")
+	}
+	if strings.HasSuffix(branch, ".") {
+		return invalid("cannot end with '.'")
+	}
+	for _, sequence := range []string{"..", "//", "@{"} {
+		if strings.Contains(branch, sequence) {
+			return invalid(fmt.Sprintf("cannot contain %q", sequence))
+		}
+	}
+	for _, char := range branch {
+		if char < 0x20 || char == 0x7f || strings.ContainsRune(" ~^:?*[\\", char) {
+			return invalid(fmt.Sprintf("cannot contain %q", char))
+		}
+	}
+	for _, component := range strings.Split(branch, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return invalid("components cannot begin with '.' or end with '.lock'")
+		}
+	}
+	return nil
+}
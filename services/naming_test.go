@@ -0,0 +1,73 @@
+package services
+
+import (
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Naming", func() {
+	Context("when no org template is configured", func() {
+		It("uses the default branch and commit templates", func() {
+			branch, err := BranchName("john", "repo", "update", "a1b2c3d4")
+			Expect(err).To(BeNil())
+			Expect(branch).To(Equal("secret_scanner_api/repo/update/a1b2c3d4/secrets_baseline_file"))
+
+			message, err := CommitMessage("john", "repo", "Update", "a1b2c3d4")
+			Expect(err).To(BeNil())
+			Expect(message).To(Equal("chore: Update secret baseline file"))
+		})
+	})
+
+	Context("when the org has its own templates", func() {
+		It("renders the org templates and falls back to defaults for missing ones", func() {
+			Config.Templates.Orgs = map[string]NamingTemplate{
+				"acme": {Branch: "security/{{.Owner}}-{{.Repo}}-{{.RequestID}}"},
+			}
+			branch, err := BranchName("acme", "repo", "create", "a1b2c3d4")
+			Expect(err).To(BeNil())
+			Expect(branch).To(Equal("security/acme-repo-a1b2c3d4"))
+
+			message, err := CommitMessage("acme", "repo", "Create", "a1b2c3d4")
+			Expect(err).To(BeNil())
+			Expect(message).To(Equal("chore: Create secret baseline file"))
+		})
+
+		It("rejects branch names that break git ref rules", func() {
+			Config.Templates.Orgs = map[string]NamingTemplate{
+				"acme": {Branch: "security/{{.Repo}}..{{.RequestID}}"},
+			}
+			_, err := BranchName("acme", "repo", "create", "a1b2c3d4")
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("rejects commit messages that are not conventional commits", func() {
+			Config.Templates.Orgs = map[string]NamingTemplate{
+				"acme": {Commit: "baseline for {{.Repo}}"},
+			}
+			_, err := CommitMessage("acme", "repo", "Create", "a1b2c3d4")
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("returns an error for unknown template fields", func() {
+			Config.Templates.Orgs = map[string]NamingTemplate{
+				"acme": {Branch: "security/{{.Unknown}}"},
+			}
+			_, err := BranchName("acme", "repo", "create", "a1b2c3d4")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("when validating branch names", func() {
+		It("follows git check-ref-format", func() {
+			Expect(ValidateBranchName("secret_scanner_api/repo/create/secrets_baseline_file")).To(BeNil())
+			for _, branch := range []string{"", "@", "/lead", "trail/", "a//b", "a/.hidden", "a/b.lock", "a b", "a~b", "a^b", "a:b", "a?b", "a*b", "a[b", "a\\b", "a@{b", "end."} {
+				Expect(ValidateBranchName(branch)).NotTo(BeNil(), branch)
+			}
+		})
+	})
+
+	It("generates short request ids", func() {
+		Expect(NewRequestID()).To(MatchRegexp(`^[0-9a-f]{8}$`))
+	})
+})
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"github.com/coreos/go-oidc"
+	"sync"
+)
+
+// checks the tokens of an issuer with go-oidc, which reads the discovery document and the
+// published keys. The discovery runs on the first token and again after it failed, so the
+// service starts while the issuer is unreachable
+type oidcVerifier struct {
+	issuer   string
+	audience string
+	mutex    *sync.Mutex
+	verifier *oidc.IDTokenVerifier
+}
+
+func newOIDCVerifier(issuer string, audience string) *oidcVerifier {
+	return &oidcVerifier{issuer: issuer, audience: audience, mutex: &sync.Mutex{}}
+}
+
+// claims of a token signed by the issuer for the audience and not expired
+func (verifier *oidcVerifier) Verify(token string) (map[string]interface{}, error) {
+	tokenVerifier, err := verifier.tokenVerifier()
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := tokenVerifier.Verify(context.Background(), token)
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (verifier *oidcVerifier) tokenVerifier() (*oidc.IDTokenVerifier, error) {
+	verifier.mutex.Lock()
+	defer verifier.mutex.Unlock()
+	if verifier.verifier != nil {
+		return verifier.verifier, nil
+	}
+	ctx := oidc.ClientContext(context.Background(), restClient("OIDC"))
+	provider, err := oidc.NewProvider(ctx, verifier.issuer)
+	if err != nil {
+		return nil, err
+	}
+	verifier.verifier = provider.Verifier(&oidc.Config{ClientID: verifier.audience})
+	return verifier.verifier, nil
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"github.com/google/go-github/v33/github"
+	"regexp"
+	"strings"
+)
+
+// narrows the repositories of an organization, empty fields match every repository
+type RepoFilter struct {
+	Languages       []string
+	Topics          []string
+	NameRegex       *regexp.Regexp
+	IncludeArchived bool
+}
+
+// reports whether the repo has one of the languages, one of the topics and a matching name
+func (filter RepoFilter) Matches(repository *github.Repository) bool {
+	if repository.GetArchived() && !filter.IncludeArchived {
+		return false
+	}
+	if filter.NameRegex != nil && !filter.NameRegex.MatchString(repository.GetName()) {
+		return false
+	}
+	if len(filter.Languages) > 0 && !containsFold(filter.Languages, repository.GetLanguage()) {
+		return false
+	}
+	if len(filter.Topics) > 0 {
+		for _, topic := range repository.Topics {
+			if containsFold(filter.Topics, topic) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// names of the organization repositories matching the filter
+func (gitService gitServiceImplementation) ListOrgRepos(org string, filter RepoFilter) ([]string, error) {
+	gitService.logger().Info().Msgf("Listing repositories of %s", org)
+	client := gitService.GetGitHubClient()
+	opts := &github.RepositoryListByOrgOptions{Sort: "full_name", ListOptions: github.ListOptions{PerPage: 100}}
+	var repos []string
+	for {
+		page, response, err := client.Repositories.ListByOrg(gitService.context(), org, opts)
+		if err != nil {
+			gitService.logger().Error().Msgf("Error listing repositories of %s: %v", org, err)
+			return nil, err
+		}
+		for _, repository := range page {
+			if filter.Matches(repository) {
+				repos = append(repos, repository.GetName())
+			}
+		}
+		if response.NextPage == 0 {
+			return repos, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
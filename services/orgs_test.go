@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+)
+
+var _ = Describe("Organizations", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		mux := http.NewServeMux()
+		server = httptest.NewServer(mux)
+		ThirdPartyContext = thirdPartyContextImpl{}
+		ThirdPartyOauth = thirdPartyOauthImpl{}
+		ThirdPartyGitHub = fakeGitHubClientFactory{baseURL: server.URL}
+		mux.HandleFunc("/orgs/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprint(w, `[{"name": "svc-old", "language": "Go", "archived": true}, {"name": "docs", "language": "Markdown"}]`)
+				return
+			}
+			w.Header().Set("Link", fmt.Sprintf(`<%s/orgs/acme/repos?page=2>; rel="next"`, server.URL))
+			fmt.Fprint(w, `[{"name": "svc-api", "language": "Go", "topics": ["payments"]}, {"name": "svc-web", "language": "TypeScript", "topics": ["frontend"]}]`)
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+		ThirdPartyGitHub = thirdPartyGitHubImpl{}
+	})
+
+	It("lists every page of repos, skipping archived ones by default", func() {
+		repos, err := gitServiceImplementation{}.ListOrgRepos("acme", RepoFilter{})
+		Expect(err).To(BeNil())
+		Expect(repos).To(Equal([]string{"svc-api", "svc-web", "docs"}))
+	})
+
+	It("filters by language, topic, name and archived state", func() {
+		repos, _ := gitServiceImplementation{}.ListOrgRepos("acme", RepoFilter{Languages: []string{"go"}, IncludeArchived: true})
+		Expect(repos).To(Equal([]string{"svc-api", "svc-old"}))
+		repos, _ = gitServiceImplementation{}.ListOrgRepos("acme", RepoFilter{Topics: []string{"frontend", "payments"}})
+		Expect(repos).To(Equal([]string{"svc-api", "svc-web"}))
+		repos, _ = gitServiceImplementation{}.ListOrgRepos("acme", RepoFilter{NameRegex: regexp.MustCompile(`^svc-`)})
+		Expect(repos).To(Equal([]string{"svc-api", "svc-web"}))
+	})
+
+	It("reports the rollout progress with counts per status", func() {
+		store := NewRolloutStore()
+		store.Start("r1", "acme", []string{"a", "b", "c"})
+		store.Update("r1", "a", RolloutCreated, "PR was Created !")
+		store.Update("r1", "b", RolloutSkipped, "")
+		report, ok := store.Report("r1")
+		Expect(ok).To(BeTrue())
+		Expect(report.Status).To(Equal(RolloutRunning))
+		Expect(report.Total).To(Equal(3))
+		Expect(report.Counts).To(Equal(map[string]int{RolloutCreated: 1, RolloutSkipped: 1, RolloutPending: 1}))
+		store.Finish("r1")
+		report, _ = store.Report("r1")
+		Expect(report.Status).To(Equal(RolloutDone))
+		_, ok = store.Report("missing")
+		Expect(ok).To(BeFalse())
+	})
+})
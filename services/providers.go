@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	GitHubProvider    = "github"
+	GitLabProvider    = "gitlab"
+	BitbucketProvider = "bitbucket"
+)
+
+// returned by OpenPullRequest when the pull request already existed and was updated instead
+var ErrPullRequestUpdated = errors.New("pull request already existed and was updated")
+
+// returns the git service for the provider named in a request, github when empty. host
+// selects one of the configured GitHub Enterprise Server instances, the calls to the service
+// are traced as children of the span in ctx
+func GitProvider(ctx context.Context, provider string, host string) (gitServiceInterface, error) {
+	if host != "" && provider != "" && provider != GitHubProvider {
+		return nil, NewServiceError(ErrCodeInvalidInput, fmt.Sprintf("host is only supported for the %s provider", GitHubProvider), nil)
+	}
+	switch provider {
+	case "", GitHubProvider:
+		return GitHubHostService(ctx, host)
+	case GitLabProvider:
+		return TraceGitService(ctx, GitLabServiceObject), nil
+	case BitbucketProvider:
+		return TraceGitService(ctx, BitbucketServiceObject), nil
+	}
+	return nil, NewServiceError(ErrCodeInvalidInput, fmt.Sprintf("unsupported git provider %q", provider), nil)
+}
+
+func GitHubHostService(ctx context.Context, host string) (gitHubServiceInterface, error) {
+	if host == "" || host == Config.GitHub.DefaultHost().GitHost {
+		return TraceGitHubService(ctx, GitServiceObject), nil
+	}
+	if _, ok := Config.GitHub.Hosts[host]; !ok {
+		return nil, NewServiceError(ErrCodeInvalidInput, fmt.Sprintf("unknown github host %q", host), nil)
+	}
+	return TraceGitHubService(ctx, gitServiceImplementation{Host: host}), nil
+}
+
+// web page of the pull or merge request number of owner/repo, the link saved in the history
+func PullRequestURL(provider string, host string, owner string, repo string, number int) string {
+	switch provider {
+	case GitLabProvider:
+		return fmt.Sprintf("%s/%s/%s/-/merge_requests/%d", strings.TrimSuffix(Config.GitLab.URL, "/"), owner, repo, number)
+	case BitbucketProvider:
+		return fmt.Sprintf("%s/projects/%s/repos/%s/pull-requests/%d", strings.TrimSuffix(Config.Bitbucket.URL, "/"), owner, repo, number)
+	}
+	if host == "" {
+		host = Config.GitHub.DefaultHost().GitHost
+	}
+	return fmt.Sprintf("https://%s/%s/%s/pull/%d", host, owner, repo, number)
+}
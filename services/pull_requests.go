@@ -0,0 +1,248 @@
+package services
+
+import (
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/go-github/v33/github"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SecretsCheckName = "detect-secrets"
+	// GitHub accepts at most 50 annotations per check run request
+	maxCheckRunAnnotations = 50
+	// prefix of the GitHub App installation tokens, the only ones allowed to create check runs
+	installationTokenPrefix = "ghs_"
+	// GitHub truncates longer commit status descriptions
+	maxStatusDescription = 140
+)
+
+// paths of the files added or modified by the pull request
+func (gitService gitServiceImplementation) PullRequestFiles(owner string, repo string, number int) ([]string, error) {
+	gitService.logger().Info().Msgf("Listing files changed by %s/%s#%d", owner, repo, number)
+	client := gitService.GetGitHubClient()
+	opts := &github.ListOptions{PerPage: 100}
+	var files []string
+	for {
+		page, response, err := client.PullRequests.ListFiles(gitService.context(), owner, repo, number, opts)
+		if err != nil {
+			gitService.logger().Error().Msgf("Error listing pull request files: %v", err)
+			return nil, err
+		}
+		for _, file := range page {
+			if file.GetStatus() != "removed" {
+				files = append(files, file.GetFilename())
+			}
+		}
+		if response.NextPage == 0 {
+			return files, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
+
+// secrets file pull request the bot left open in a repo
+type BaselinePullRequest struct {
+	Number     int
+	HeadOwner  string
+	HeadBranch string
+	BaseBranch string
+}
+
+// finds the open pull request the bot opened to create or update the secrets file, so new
+// findings are pushed to its branch instead of opening another one
+func (gitService gitServiceImplementation) OpenBaselinePullRequest(owner string, repo string) (BaselinePullRequest, bool, error) {
+	client := gitService.GetGitHubClient()
+	bot, _, err := client.Users.Get(gitService.context(), "")
+	if err != nil {
+		gitService.logger().Error().Msgf("Error reading the user of the token: %v", err)
+		return BaselinePullRequest{}, false, err
+	}
+	titles := map[string]bool{PullRequestTitle("Create"): true, PullRequestTitle("Update"): true}
+	opts := &github.PullRequestListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, response, err := client.PullRequests.List(gitService.context(), owner, repo, opts)
+		if err != nil {
+			gitService.logger().Error().Msgf("Error listing the open pull requests of %s/%s: %v", owner, repo, err)
+			return BaselinePullRequest{}, false, err
+		}
+		for _, pull := range page {
+			if pull.GetUser().GetLogin() == bot.GetLogin() && titles[pull.GetTitle()] {
+				return BaselinePullRequest{
+					Number:     pull.GetNumber(),
+					HeadOwner:  pull.GetHead().GetUser().GetLogin(),
+					HeadBranch: pull.GetHead().GetRef(),
+					BaseBranch: pull.GetBase().GetRef(),
+				}, true, nil
+			}
+		}
+		if response.NextPage == 0 {
+			return BaselinePullRequest{}, false, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
+
+// fetches the pull request head, which may live in a fork, and checks it out detached
+func (gitService gitServiceImplementation) CheckoutPullRequest(repoGit *git.Repository, number int, headSHA string) error {
+	gitService.logger().Info().Msgf("Checking out pull request #%d at %s", number, headSHA)
+	refSpec := config.RefSpec(fmt.Sprintf("refs/pull/%d/head:refs/remotes/origin/pull/%d", number, number))
+	err := repoGit.Fetch(&git.FetchOptions{RefSpecs: []config.RefSpec{refSpec}})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		gitService.logger().Error().Msgf("Error fetching pull request #%d: %v", number, err)
+		return err
+	}
+	workingBranch, err := ThirdPartyGitHub.Worktree(repoGit)
+	if err != nil {
+		return err
+	}
+	return ThirdPartyGitHub.Checkout(workingBranch, &git.CheckoutOptions{Hash: plumbing.NewHash(headSHA), Force: true})
+}
+
+// content of a file at the given ref, nil when the file does not exist there
+func (gitService gitServiceImplementation) FileContent(owner string, repo string, path string, ref string) ([]byte, error) {
+	client := gitService.GetGitHubClient()
+	file, _, response, err := client.Repositories.GetContents(gitService.context(), owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		gitService.logger().Error().Msgf("Error fetching %s from %s/%s at %s: %v", path, owner, repo, ref, err)
+		return nil, err
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+func secretAnnotations(unaudited SecretUpdateMap) []*github.CheckRunAnnotation {
+	filenames := make([]string, 0, len(unaudited))
+	for filename := range unaudited {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	var annotations []*github.CheckRunAnnotation
+	for _, filename := range filenames {
+		for _, secret := range unaudited[filename] {
+			line := 1
+			if number, ok := secret["line_number"].(float64); ok {
+				line = int(number)
+			}
+			message := fmt.Sprintf("Potential secret of type %v is not audited in %s, remove it or audit it in the baseline.",
+				secret["type"], Config.Scanner.SecretsFileName)
+			if isSecret, _ := secret["is_secret"].(bool); isSecret {
+				message = fmt.Sprintf("Secret of type %v is audited as real in %s, remove it from the code and rotate it.",
+					secret["type"], Config.Scanner.SecretsFileName)
+			}
+			annotations = append(annotations, &github.CheckRunAnnotation{
+				Path:            github.String(filename),
+				StartLine:       github.Int(line),
+				EndLine:         github.Int(line),
+				AnnotationLevel: github.String("failure"),
+				Title:           github.String(fmt.Sprintf("%v", secret["type"])),
+				Message:         github.String(message),
+			})
+		}
+	}
+	return annotations
+}
+
+// reports the unaudited and the real secrets on the head commit, the check passes only when there are none.
+// Check runs can only be created with a GitHub App installation token, with any other token
+// the result is reported as a commit status without the annotations
+func (gitService gitServiceImplementation) CreateCheckRun(owner string, repo string, headSHA string, unaudited SecretUpdateMap) error {
+	annotations := secretAnnotations(unaudited)
+	conclusion := "success"
+	title := "No unaudited secrets"
+	if len(annotations) > 0 {
+		conclusion = "failure"
+		title = fmt.Sprintf("%d unaudited secrets", len(annotations))
+	}
+	if !strings.HasPrefix(gitService.HostConfig().Token, installationTokenPrefix) {
+		return gitService.createCommitStatus(owner, repo, headSHA, conclusion, title)
+	}
+	summary := fmt.Sprintf("Secrets found in the changed files that are missing from %s, not audited or audited as real: %d.", Config.Scanner.SecretsFileName, len(annotations))
+	gitService.logger().Info().Msgf("Reporting check run on %s/%s@%s: %s", owner, repo, headSHA, title)
+
+	client := gitService.GetGitHubClient()
+	ctx := gitService.context()
+	batch := annotations
+	if len(batch) > maxCheckRunAnnotations {
+		batch = batch[:maxCheckRunAnnotations]
+	}
+	checkRun, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:        SecretsCheckName,
+		HeadSHA:     headSHA,
+		Status:      github.String("completed"),
+		Conclusion:  github.String(conclusion),
+		CompletedAt: &github.Timestamp{Time: time.Now()},
+		Output: &github.CheckRunOutput{
+			Title:       github.String(title),
+			Summary:     github.String(summary),
+			Annotations: batch,
+		},
+	})
+	if err != nil {
+		gitService.logger().Error().Msgf("Error creating check run: %v", err)
+		return err
+	}
+	for start := maxCheckRunAnnotations; start < len(annotations); start += maxCheckRunAnnotations {
+		end := start + maxCheckRunAnnotations
+		if end > len(annotations) {
+			end = len(annotations)
+		}
+		_, _, err = client.Checks.UpdateCheckRun(ctx, owner, repo, checkRun.GetID(), github.UpdateCheckRunOptions{
+			Name: SecretsCheckName,
+			Output: &github.CheckRunOutput{
+				Title:       github.String(title),
+				Summary:     github.String(summary),
+				Annotations: annotations[start:end],
+			},
+		})
+		if err != nil {
+			gitService.logger().Error().Msgf("Error adding annotations to check run: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// reports the check as the commit status of the head commit, state is success or failure
+func (gitService gitServiceImplementation) createCommitStatus(owner string, repo string, headSHA string, state string, title string) error {
+	gitService.logger().Info().Msgf("Reporting commit status on %s/%s@%s: %s", owner, repo, headSHA, title)
+	description := title
+	if state == "failure" {
+		description = fmt.Sprintf("%s, remove them or audit them in %s", title, Config.Scanner.SecretsFileName)
+	}
+	if len(description) > maxStatusDescription {
+		description = description[:maxStatusDescription]
+	}
+	client := gitService.GetGitHubClient()
+	_, _, err := client.Repositories.CreateStatus(gitService.context(), owner, repo, headSHA, &github.RepoStatus{
+		State:       github.String(state),
+		Context:     github.String(SecretsCheckName),
+		Description: github.String(description),
+	})
+	if err != nil {
+		gitService.logger().Error().Msgf("Error creating commit status: %v", err)
+	}
+	return err
+}
+
+// adds a comment to the conversation of an issue or pull request
+func (gitService gitServiceImplementation) CreateIssueComment(owner string, repo string, number int, body string) error {
+	client := gitService.GetGitHubClient()
+	_, _, err := client.Issues.CreateComment(gitService.context(), owner, repo, number, &github.IssueComment{Body: github.String(body)})
+	if err != nil {
+		gitService.logger().Error().Msgf("Error commenting on %s/%s#%d: %v", owner, repo, number, err)
+	}
+	return err
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/google/go-github/v33/github"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+// github client factory pointing every client at a fake API server
+type fakeGitHubClientFactory struct {
+	thirdPartyGitHubImpl
+	baseURL string
+}
+
+func (factory fakeGitHubClientFactory) NewClient(httpClient *http.Client) *github.Client {
+	client := github.NewClient(httpClient)
+	client.BaseURL, _ = url.Parse(factory.baseURL + "/")
+	return client
+}
+
+var _ = Describe("Pull requests", func() {
+	var mux *http.ServeMux
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		mux = http.NewServeMux()
+		server = httptest.NewServer(mux)
+		ThirdPartyContext = thirdPartyContextImpl{}
+		ThirdPartyOauth = thirdPartyOauthImpl{}
+		ThirdPartyGitHub = fakeGitHubClientFactory{baseURL: server.URL}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		ThirdPartyGitHub = thirdPartyGitHubImpl{}
+	})
+
+	It("lists the changed files of every page, skipping removed ones", func() {
+		mux.HandleFunc("/repos/john/repo/pulls/7/files", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprint(w, `[{"filename": "b.py", "status": "added"}]`)
+				return
+			}
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/john/repo/pulls/7/files?page=2>; rel="next"`, server.URL))
+			fmt.Fprint(w, `[{"filename": "a.py", "status": "modified"}, {"filename": "old.py", "status": "removed"}]`)
+		})
+		files, err := gitServiceImplementation{}.PullRequestFiles("john", "repo", 7)
+		Expect(err).To(BeNil())
+		Expect(files).To(Equal([]string{"a.py", "b.py"}))
+	})
+
+	It("finds the secrets file pull request the bot left open", func() {
+		mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"login": "bot"}`)
+		})
+		mux.HandleFunc("/repos/john/repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Query().Get("state")).To(Equal("open"))
+			fmt.Fprintf(w, `[{"number": 3, "title": %q, "user": {"login": "alice"}},
+				{"number": 4, "title": "Fix the build", "user": {"login": "bot"}},
+				{"number": 5, "title": %q, "user": {"login": "bot"}, "head": {"ref": "secrets", "user": {"login": "bot"}}, "base": {"ref": "main"}}]`,
+				PullRequestTitle("Create"), PullRequestTitle("Update"))
+		})
+		pull, found, err := gitServiceImplementation{}.OpenBaselinePullRequest("john", "repo")
+		Expect(err).To(BeNil())
+		Expect(found).To(BeTrue())
+		Expect(pull).To(Equal(BaselinePullRequest{Number: 5, HeadOwner: "bot", HeadBranch: "secrets", BaseBranch: "main"}))
+
+		mux.HandleFunc("/repos/john/other/pulls", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `[]`)
+		})
+		_, found, err = gitServiceImplementation{}.OpenBaselinePullRequest("john", "other")
+		Expect(err).To(BeNil())
+		Expect(found).To(BeFalse())
+	})
+
+	It("returns the file content at a ref and nil when it does not exist", func() {
+		mux.HandleFunc("/repos/john/repo/contents/"+Config.Scanner.SecretsFileName, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("ref") != "base" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `{"type": "file", "encoding": "base64", "content": "%s"}`, base64.StdEncoding.EncodeToString([]byte(`{"results": {}}`)))
+		})
+		content, err := gitServiceImplementation{}.FileContent("john", "repo", Config.Scanner.SecretsFileName, "base")
+		Expect(err).To(BeNil())
+		Expect(string(content)).To(Equal(`{"results": {}}`))
+
+		content, err = gitServiceImplementation{}.FileContent("john", "repo", Config.Scanner.SecretsFileName, "other")
+		Expect(err).To(BeNil())
+		Expect(content).To(BeNil())
+	})
+
+	Context("when reporting the check run", func() {
+		var created []github.CreateCheckRunOptions
+		var updated []github.UpdateCheckRunOptions
+		var statuses []github.RepoStatus
+
+		BeforeEach(func() {
+			created, updated, statuses = nil, nil, nil
+			Config.GitHub.Token = "ghs_installation"
+			mux.HandleFunc("/repos/john/repo/statuses/abc", func(w http.ResponseWriter, r *http.Request) {
+				var status github.RepoStatus
+				_ = json.NewDecoder(r.Body).Decode(&status)
+				statuses = append(statuses, status)
+				fmt.Fprint(w, `{"id": 1}`)
+			})
+			mux.HandleFunc("/repos/john/repo/check-runs", func(w http.ResponseWriter, r *http.Request) {
+				var opts github.CreateCheckRunOptions
+				_ = json.NewDecoder(r.Body).Decode(&opts)
+				created = append(created, opts)
+				fmt.Fprint(w, `{"id": 42}`)
+			})
+			mux.HandleFunc("/repos/john/repo/check-runs/42", func(w http.ResponseWriter, r *http.Request) {
+				var opts github.UpdateCheckRunOptions
+				_ = json.NewDecoder(r.Body).Decode(&opts)
+				updated = append(updated, opts)
+				fmt.Fprint(w, `{"id": 42}`)
+			})
+		})
+
+		It("passes when there are no unaudited secrets", func() {
+			Expect(gitServiceImplementation{}.CreateCheckRun("john", "repo", "abc", SecretUpdateMap{})).To(Succeed())
+			Expect(created).To(HaveLen(1))
+			Expect(created[0].HeadSHA).To(Equal("abc"))
+			Expect(created[0].GetConclusion()).To(Equal("success"))
+			Expect(created[0].Output.Annotations).To(BeEmpty())
+		})
+
+		It("fails with an annotation per secret, sent in batches of 50", func() {
+			unaudited := SecretUpdateMap{}
+			for line := 1; line <= 120; line++ {
+				unaudited["config.py"] = append(unaudited["config.py"], map[string]interface{}{
+					"type": "Secret Keyword", "hashed_secret": fmt.Sprintf("%d", line), "line_number": float64(line),
+				})
+			}
+			Expect(gitServiceImplementation{}.CreateCheckRun("john", "repo", "abc", unaudited)).To(Succeed())
+			Expect(created).To(HaveLen(1))
+			Expect(created[0].GetConclusion()).To(Equal("failure"))
+			Expect(created[0].Output.Annotations).To(HaveLen(50))
+			Expect(created[0].Output.Annotations[0].GetPath()).To(Equal("config.py"))
+			Expect(created[0].Output.Annotations[0].GetStartLine()).To(Equal(1))
+			Expect(updated).To(HaveLen(2))
+			Expect(updated[1].Output.Annotations).To(HaveLen(20))
+			Expect(statuses).To(BeEmpty())
+		})
+
+		It("falls back to a commit status without a GitHub App installation token", func() {
+			Config.GitHub.Token = "ghp_personal"
+			unaudited := SecretUpdateMap{"config.py": {{"type": "Secret Keyword", "hashed_secret": "a", "line_number": float64(3)}}}
+			Expect(gitServiceImplementation{}.CreateCheckRun("john", "repo", "abc", unaudited)).To(Succeed())
+			Expect(created).To(BeEmpty())
+			Expect(statuses).To(HaveLen(1))
+			Expect(statuses[0].GetState()).To(Equal("failure"))
+			Expect(statuses[0].GetContext()).To(Equal(SecretsCheckName))
+			Expect(statuses[0].GetDescription()).To(Equal("1 unaudited secrets, remove them or audit them in " + Config.Scanner.SecretsFileName))
+
+			Expect(gitServiceImplementation{}.CreateCheckRun("john", "repo", "abc", SecretUpdateMap{})).To(Succeed())
+			Expect(statuses[1].GetState()).To(Equal("success"))
+		})
+	})
+
+	It("requires the write permission of a GitHub user in the context to access the repo", func() {
+		mux.HandleFunc("/repos/john/repo", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"name": "repo"}`)
+		})
+		mux.HandleFunc("/repos/john/repo/collaborators/", func(w http.ResponseWriter, r *http.Request) {
+			permission := map[string]string{"/repos/john/repo/collaborators/maintainer/permission": "write"}[r.URL.Path]
+			if permission == "" {
+				permission = "read"
+			}
+			fmt.Fprintf(w, `{"permission": "%s"}`, permission)
+		})
+		commenter := func(user string) gitServiceInterface {
+			return gitServiceImplementation{}.withContext(WithIdentity(context.Background(), Identity{Name: user, Method: AuthMethodGitHub}))
+		}
+		Expect(commenter("maintainer").CheckUserAccessRepo("john", "repo")).To(Succeed())
+		err := commenter("visitor").CheckUserAccessRepo("john", "repo")
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("read permission"))
+		// the API clients are authorized by their role, not by their GitHub permission
+		client := gitServiceImplementation{}.withContext(WithIdentity(context.Background(), Identity{Name: "visitor", Method: AuthMethodAPIKey}))
+		Expect(client.CheckUserAccessRepo("john", "repo")).To(Succeed())
+	})
+
+	It("flags secrets missing from the baseline or not audited in it", func() {
+		baseline := []byte(`{"results": {"a.py": [{"hashed_secret": "audited", "is_secret": false}, {"hashed_secret": "pending"}]}}`)
+		scan := []byte(`{"results": {"a.py": [{"hashed_secret": "audited"}, {"hashed_secret": "pending"}, {"hashed_secret": "new"}]}}`)
+		unaudited, err := UnauditedSecrets(baseline, scan)
+		Expect(err).To(BeNil())
+		Expect(unaudited["a.py"]).To(HaveLen(2))
+		Expect(unaudited["a.py"][0]["hashed_secret"]).To(Equal("pending"))
+		Expect(unaudited["a.py"][1]["hashed_secret"]).To(Equal("new"))
+	})
+
+	It("flags secrets audited as real in the baseline", func() {
+		baseline := []byte(`{"results": {"a.py": [{"hashed_secret": "real", "is_secret": true}]}}`)
+		scan := []byte(`{"results": {"a.py": [{"hashed_secret": "real", "type": "AWS Access Key", "line_number": 3}]}}`)
+		unaudited, err := UnauditedSecrets(baseline, scan)
+		Expect(err).To(BeNil())
+		Expect(unaudited["a.py"]).To(HaveLen(1))
+		Expect(unaudited["a.py"][0]["is_secret"]).To(Equal(true))
+		annotations := secretAnnotations(unaudited)
+		Expect(annotations).To(HaveLen(1))
+		Expect(annotations[0].GetStartLine()).To(Equal(3))
+		Expect(annotations[0].GetMessage()).To(ContainSubstring("audited as real"))
+	})
+	Context("when opening the pull request", func() {
+		var created, edited bool
+
+		BeforeEach(func() {
+			created, edited = false, false
+			mux.HandleFunc("/repos/john/repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					Expect(r.URL.Query().Get("head")).To(Equal("bot:feature"))
+					fmt.Fprint(w, `[{"number": 9}]`)
+					return
+				}
+				if created {
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					fmt.Fprint(w, `{"message": "Validation Failed", "errors": [{"resource": "PullRequest", "code": "custom", "message": "A pull request already exists for bot:feature."}]}`)
+					return
+				}
+				created = true
+				fmt.Fprint(w, `{"number": 8}`)
+			})
+			mux.HandleFunc("/repos/john/repo/pulls/9", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal(http.MethodPatch))
+				edited = true
+				fmt.Fprint(w, `{"number": 9}`)
+			})
+		})
+
+		It("returns the number of the new pull request", func() {
+			number, err := gitServiceImplementation{}.OpenPullRequest("bot", "john", "repo", "feature", "main", "Create", "body")
+			Expect(err).To(BeNil())
+			Expect(number).To(Equal(8))
+		})
+
+		It("updates the open pull request of the branch instead of failing", func() {
+			created = true
+			number, err := gitServiceImplementation{}.OpenPullRequest("bot", "john", "repo", "feature", "main", "Update", "body")
+			Expect(err).To(Equal(ErrPullRequestUpdated))
+			Expect(number).To(Equal(9))
+			Expect(edited).To(BeTrue())
+		})
+
+		It("returns the other validation errors", func() {
+			mux.HandleFunc("/repos/john/other/pulls", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				fmt.Fprint(w, `{"message": "Validation Failed", "errors": [{"resource": "PullRequest", "field": "base", "code": "invalid"}]}`)
+			})
+			_, err := gitServiceImplementation{}.OpenPullRequest("bot", "john", "other", "feature", "main", "Create", "body")
+			Expect(err).NotTo(BeNil())
+			Expect(err).NotTo(Equal(ErrPullRequestUpdated))
+		})
+	})
+})
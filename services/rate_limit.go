@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// last quota reported by a GitHub host for the core REST resource, RetryAfter is set by
+// secondary rate limit responses
+type RateLimitState struct {
+	Limit      int       `json:"limit"`
+	Remaining  int       `json:"remaining"`
+	Reset      time.Time `json:"reset"`
+	RetryAfter time.Time `json:"retry_after"`
+}
+
+// time until a request can be sent without hitting the limit
+func (state RateLimitState) wait(now time.Time) time.Duration {
+	var wait time.Duration
+	if state.RetryAfter.After(now) {
+		wait = state.RetryAfter.Sub(now)
+	}
+	if state.Limit > 0 && state.Remaining <= 0 && state.Reset.After(now) && state.Reset.Sub(now) > wait {
+		wait = state.Reset.Sub(now)
+	}
+	return wait
+}
+
+type rateLimitStoreInterface interface {
+	Update(host string, header http.Header, statusCode int)
+	Wait(host string) time.Duration
+	JobDelay(host string) time.Duration
+	Snapshot() map[string]RateLimitState
+}
+
+type rateLimitStoreImplementation struct {
+	mutex  *sync.Mutex
+	states map[string]RateLimitState
+}
+
+// quota of every GitHub host keyed like Config.GitHub.Hosts, the empty key is the default host
+func NewRateLimitStore() rateLimitStoreInterface {
+	return rateLimitStoreImplementation{mutex: &sync.Mutex{}, states: map[string]RateLimitState{}}
+}
+
+func (store rateLimitStoreImplementation) Update(host string, header http.Header, statusCode int) {
+	now := time.Now()
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	state := store.states[host]
+	resource := header.Get("X-RateLimit-Resource")
+	if remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining")); err == nil && (resource == "" || resource == "core") {
+		state.Remaining = remaining
+		state.Limit, _ = strconv.Atoi(header.Get("X-RateLimit-Limit"))
+		if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			state.Reset = time.Unix(reset, 0)
+		}
+	}
+	if statusCode == http.StatusForbidden || statusCode == http.StatusTooManyRequests {
+		if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+			state.RetryAfter = now.Add(time.Duration(seconds) * time.Second)
+		}
+	}
+	store.states[host] = state
+	if host == "" {
+		host = Config.GitHub.DefaultHost().GitHost
+	}
+	GitHubRateLimitRemaining.WithLabelValues(host).Set(float64(state.Remaining))
+}
+
+func (store rateLimitStoreImplementation) Wait(host string) time.Duration {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.states[host].wait(time.Now())
+}
+
+// time a job on host should wait before starting so it does not spend the last
+// Config.GitHub.RateLimit.JobReserve requests, those are left to the API requests
+func (store rateLimitStoreImplementation) JobDelay(host string) time.Duration {
+	now := time.Now()
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	state := store.states[host]
+	wait := state.wait(now)
+	if state.Limit > 0 && state.Remaining < Config.GitHub.RateLimit.JobReserve && state.Reset.After(now) && state.Reset.Sub(now) > wait {
+		wait = state.Reset.Sub(now)
+	}
+	return wait
+}
+
+func (store rateLimitStoreImplementation) Snapshot() map[string]RateLimitState {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	snapshot := map[string]RateLimitState{}
+	for host, state := range store.states {
+		if host == "" {
+			host = Config.GitHub.DefaultHost().GitHost
+		}
+		snapshot[host] = state
+	}
+	return snapshot
+}
+
+// counts every call and records the quota of every response, holds requests back while the quota of the host is
+// exhausted, waiting for the reset or failing fast according to Config.GitHub.RateLimit.Mode
+type rateLimitTransport struct {
+	host string
+	base http.RoundTripper
+}
+
+func NewRateLimitTransport(host string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return rateLimitTransport{host: host, base: base}
+}
+
+func (transport rateLimitTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if wait := GitHubRateLimits.Wait(transport.host); wait > 0 {
+		maxWait := time.Duration(Config.GitHub.RateLimit.MaxWaitSeconds) * time.Second
+		if Config.GitHub.RateLimit.Mode == RateLimitFailFast || wait > maxWait {
+			return nil, NewServiceError(ErrCodeRateLimited, fmt.Sprintf("GitHub rate limit exhausted, retry in %s", wait.Round(time.Second)), nil)
+		}
+		Logger(request.Context()).Info().Msgf("GitHub rate limit exhausted, waiting %s before %s %s", wait.Round(time.Second), request.Method, request.URL.Path)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-request.Context().Done():
+			timer.Stop()
+			return nil, request.Context().Err()
+		}
+	}
+	response, err := transport.base.RoundTrip(request)
+	countGitHubRequest(request, response)
+	if err != nil {
+		return nil, err
+	}
+	GitHubRateLimits.Update(transport.host, response.Header, response.StatusCode)
+	return response, nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("Rate limits", func() {
+	var server *httptest.Server
+	var headers http.Header
+	var statusCode int
+	var calls int
+	var client *http.Client
+
+	BeforeEach(func() {
+		GitHubRateLimits = NewRateLimitStore()
+		Config.GitHub.RateLimit.Mode = RateLimitWait
+		headers, statusCode, calls = http.Header{}, 200, 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			for key := range headers {
+				w.Header().Set(key, headers.Get(key))
+			}
+			w.WriteHeader(statusCode)
+		}))
+		client = &http.Client{Transport: NewRateLimitTransport("", nil)}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		GitHubRateLimits = NewRateLimitStore()
+		Config.GitHub.RateLimit.Mode = RateLimitWait
+	})
+
+	rateLimitHeaders := func(remaining int, reset time.Time) {
+		headers.Set("X-RateLimit-Limit", "5000")
+		headers.Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
+		headers.Set("X-RateLimit-Reset", fmt.Sprintf("%d", reset.Unix()))
+	}
+
+	It("records the quota reported by every response", func() {
+		reset := time.Now().Add(time.Hour)
+		rateLimitHeaders(4321, reset)
+		_, err := client.Get(server.URL)
+		Expect(err).To(BeNil())
+		state := GitHubRateLimits.Snapshot()[Config.GitHub.GitHost]
+		Expect(state.Limit).To(Equal(5000))
+		Expect(state.Remaining).To(Equal(4321))
+		Expect(state.Reset.Unix()).To(Equal(reset.Unix()))
+		Expect(GitHubRateLimits.Wait("")).To(BeZero())
+	})
+
+	It("fails fast once the quota is exhausted until a later reset", func() {
+		rateLimitHeaders(0, time.Now().Add(time.Hour))
+		_, err := client.Get(server.URL)
+		Expect(err).To(BeNil())
+		_, err = client.Get(server.URL)
+		var serviceError *ServiceError
+		Expect(errors.As(err, &serviceError)).To(BeTrue())
+		Expect(serviceError.Code).To(Equal(ErrCodeRateLimited))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("waits for the Retry-After of a secondary rate limit when configured to wait", func() {
+		statusCode = 403
+		headers.Set("Retry-After", "1")
+		_, err := client.Get(server.URL)
+		Expect(err).To(BeNil())
+		Expect(GitHubRateLimits.Wait("")).To(BeNumerically(">", 0))
+
+		statusCode = 200
+		headers.Del("Retry-After")
+		started := time.Now()
+		_, err = client.Get(server.URL)
+		Expect(err).To(BeNil())
+		Expect(time.Since(started)).To(BeNumerically(">=", 500*time.Millisecond))
+		Expect(calls).To(Equal(2))
+
+		Config.GitHub.RateLimit.Mode = RateLimitFailFast
+		statusCode = 429
+		headers.Set("Retry-After", "1")
+		_, _ = client.Get(server.URL)
+		_, err = client.Get(server.URL)
+		Expect(ClassifyError(err, ErrCodeInternal)).To(Equal(ErrCodeRateLimited))
+	})
+
+	It("delays jobs while the quota is below the reserve", func() {
+		reset := time.Now().Add(time.Minute)
+		rateLimitHeaders(Config.GitHub.RateLimit.JobReserve+1, reset)
+		GitHubRateLimits.Update("", headers, 200)
+		Expect(GitHubRateLimits.JobDelay("")).To(BeZero())
+		rateLimitHeaders(Config.GitHub.RateLimit.JobReserve-1, reset)
+		GitHubRateLimits.Update("", headers, 200)
+		Expect(GitHubRateLimits.JobDelay("")).To(BeNumerically(">", 50*time.Second))
+		Expect(GitHubRateLimits.JobDelay("ghe.example.com")).To(BeZero())
+	})
+})
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// sends payload as json and decodes a successful json response into result, used by
+// the providers that are called through their REST API directly. The call is traced as a
+// child of the span in ctx and ends when ctx is cancelled, reads are retried like the other
+// GitHub, GitLab and Bitbucket calls
+func jsonRequest(ctx context.Context, api string, method string, requestURL string, header http.Header, payload interface{}, result interface{}) (int, error) {
+	var data []byte
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return 0, err
+		}
+	}
+	if method != http.MethodGet {
+		return sendJSONRequest(ctx, api, method, requestURL, header, data, result)
+	}
+	var status int
+	err := Retry(ctx, fmt.Sprintf("%s read", api), func() (err error) {
+		status, err = sendJSONRequest(ctx, api, method, requestURL, header, data, result)
+		return err
+	})
+	return status, err
+}
+
+func sendJSONRequest(ctx context.Context, api string, method string, requestURL string, header http.Header, data []byte, result interface{}) (int, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	request, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+	if err != nil {
+		return 0, err
+	}
+	for key := range header {
+		request.Header.Set(key, header.Get(key))
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	response, err := restClient(api).Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return response.StatusCode, err
+	}
+	if response.StatusCode >= 300 {
+		return response.StatusCode, &HTTPStatusError{Method: method, Path: request.URL.Path, StatusCode: response.StatusCode, Body: strings.TrimSpace(string(content))}
+	}
+	if result != nil && len(content) > 0 {
+		if err := json.Unmarshal(content, result); err != nil {
+			return response.StatusCode, err
+		}
+	}
+	return response.StatusCode, nil
+}
+
+// client of the REST calls to api, every call opens a client span named after it
+func restClient(api string) *http.Client {
+	return &http.Client{Transport: tracingTransport{base: http.DefaultTransport, api: api, endpoint: restEndpoint}}
+}
+
+// path of a REST call with its ids, projects and repos replaced by placeholders
+func restEndpoint(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		switch {
+		case i > 0 && (segments[i-1] == "projects" || segments[i-1] == "repos"):
+			segments[i] = "{" + strings.TrimSuffix(segments[i-1], "s") + "}"
+		case githubNumber.MatchString(segment):
+			segments[i] = "{number}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/go-git/go-git/v5/plumbing"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/google/go-github/v33/github"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+// whether err is a transient failure worth another attempt: 5xx responses, dropped or reset
+// connections, timeouts and unexpected go-git transport errors
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var githubError *github.ErrorResponse
+	if errors.As(err, &githubError) && githubError.Response != nil {
+		return githubError.Response.StatusCode >= 500
+	}
+	var statusError *HTTPStatusError
+	if errors.As(err, &statusError) {
+		return statusError.StatusCode >= 500
+	}
+	var transportError *githttp.Err
+	if errors.As(err, &transportError) {
+		return transportError.StatusCode() >= 500
+	}
+	// go-git does not unwrap its transport errors, only the http ones carry a status
+	var unexpectedError *plumbing.UnexpectedError
+	if errors.As(err, &unexpectedError) {
+		if errors.As(unexpectedError.Err, &transportError) {
+			return transportError.StatusCode() >= 500
+		}
+		return true
+	}
+	var netError net.Error
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netError) && netError.Timeout()) {
+		return true
+	}
+	return false
+}
+
+// delay before the attempt after the given one, exponential in the attempt with full jitter
+func retryDelay(attempt int) time.Duration {
+	ceiling := Config.Timeouts.RetryMaxDelay
+	if attempt < 32 {
+		ceiling = Config.Timeouts.RetryBaseDelay << uint(attempt-1)
+	}
+	if ceiling <= 0 || ceiling > Config.Timeouts.RetryMaxDelay {
+		ceiling = Config.Timeouts.RetryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// runs call up to timeouts.retry_attempts times while it fails with a retryable error, every
+// attempt is logged with the logger of ctx and counted under operation. The wait between
+// attempts ends early with the error of ctx when it is cancelled
+func Retry(ctx context.Context, operation string, call func() error) error {
+	return retry(ctx, operation, Config.Timeouts.RetryAttempts, IsRetryable, call)
+}
+
+// runs call until it succeeds, fails with an error retryable does not accept, or attempts
+// calls were made. Attempts below one keep calling until ctx is done
+func retry(ctx context.Context, operation string, attempts int, retryable func(error) bool, call func() error) error {
+	var err error
+	for attempt := 1; attempts < 1 || attempt <= attempts; attempt++ {
+		RetryAttemptCounts.WithLabelValues(operation).Inc()
+		err = call()
+		if err == nil {
+			if attempt > 1 {
+				Logger(ctx).Info().Msgf("%s succeeded on attempt %d", operation, attempt)
+			}
+			return nil
+		}
+		if !retryable(err) {
+			return err
+		}
+		if attempt == attempts {
+			break
+		}
+		delay := retryDelay(attempt)
+		Logger(ctx).Warn().Msgf("%s failed on attempt %d, retrying in %s: %v", operation, attempt, delay.Round(time.Millisecond), err)
+		if waitErr := sleepContext(ctx, delay); waitErr != nil {
+			break
+		}
+	}
+	RetryExhaustedCounts.WithLabelValues(operation).Inc()
+	Logger(ctx).Error().Msgf("%s gave up: %v", operation, err)
+	return err
+}
+
+// waits for delay, or until ctx is done
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	if ctx == nil {
+		time.Sleep(delay)
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/google/go-github/v33/github"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var _ = Describe("Retry", func() {
+	BeforeEach(func() {
+		Config.Timeouts.RetryBaseDelay = time.Millisecond
+	})
+
+	It("classifies transient failures as retryable", func() {
+		gitResponse := func(statusCode int) error {
+			request := &http.Request{URL: &url.URL{Scheme: "https", Host: "github.com"}}
+			return plumbing.NewUnexpectedError(&githttp.Err{Response: &http.Response{StatusCode: statusCode, Request: request}})
+		}
+		Expect(IsRetryable(&github.ErrorResponse{Response: &http.Response{StatusCode: 502}})).To(BeTrue())
+		Expect(IsRetryable(&github.ErrorResponse{Response: &http.Response{StatusCode: 422}})).To(BeFalse())
+		Expect(IsRetryable(&HTTPStatusError{StatusCode: 503})).To(BeTrue())
+		Expect(IsRetryable(fmt.Errorf("push: %w", syscall.ECONNRESET))).To(BeTrue())
+		Expect(IsRetryable(gitResponse(500))).To(BeTrue())
+		Expect(IsRetryable(gitResponse(400))).To(BeFalse())
+		Expect(IsRetryable(errors.New("reference not found"))).To(BeFalse())
+	})
+
+	It("retries until the call succeeds and counts every attempt", func() {
+		calls := 0
+		err := Retry(context.Background(), "test success", func() error {
+			calls++
+			if calls < 3 {
+				return &HTTPStatusError{Method: "POST", Path: "/forks", StatusCode: 502}
+			}
+			return nil
+		})
+		Expect(err).To(BeNil())
+		Expect(calls).To(Equal(3))
+		Expect(testutil.ToFloat64(RetryAttemptCounts.WithLabelValues("test success"))).To(Equal(3.0))
+	})
+
+	It("gives up on permanent errors and after the last attempt", func() {
+		calls := 0
+		err := Retry(context.Background(), "test permanent", func() error {
+			calls++
+			return &HTTPStatusError{StatusCode: 404}
+		})
+		Expect(ClassifyError(err, ErrCodeInternal)).To(Equal(ErrCodeNotFound))
+		Expect(calls).To(Equal(1))
+
+		calls = 0
+		err = Retry(context.Background(), "test exhausted", func() error {
+			calls++
+			return syscall.ECONNRESET
+		})
+		Expect(err).To(Equal(syscall.ECONNRESET))
+		Expect(calls).To(Equal(Config.Timeouts.RetryAttempts))
+		Expect(testutil.ToFloat64(RetryExhaustedCounts.WithLabelValues("test exhausted"))).To(Equal(1.0))
+	})
+
+	It("stops waiting for the next attempt once the context is cancelled", func() {
+		Config.Timeouts.RetryBaseDelay = time.Hour
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := Retry(ctx, "test cancelled", func() error {
+			calls++
+			cancel()
+			return syscall.ECONNRESET
+		})
+		Expect(err).To(Equal(syscall.ECONNRESET))
+		Expect(calls).To(Equal(1))
+	})
+})
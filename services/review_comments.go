@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/google/go-github/v33/github"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// hidden marker identifying the review comments of the bot and the secret they are about
+var secretMarkerRegex = regexp.MustCompile(`<!-- detect-secrets hashed_secret=(\w+) -->`)
+
+const reviewThreadsQuery = `query($owner: String!, $repo: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes { id isResolved comments(first: 1) { nodes { body } } }
+      }
+    }
+  }
+}`
+
+const resolveThreadMutation = `mutation($threadId: ID!) { resolveReviewThread(input: {threadId: $threadId}) { thread { id } } }`
+const unresolveThreadMutation = `mutation($threadId: ID!) { unresolveReviewThread(input: {threadId: $threadId}) { thread { id } } }`
+
+func secretReviewComment(secret map[string]interface{}) string {
+	return fmt.Sprintf("**Potential secret detected** (%v)\n\n"+
+		"This line adds a secret that is not audited as a false positive in `%s`. Remove it from the code and rotate the credential, "+
+		"or if it is a false positive audit it in the baseline.\n\n"+
+		"<!-- detect-secrets hashed_secret=%v -->", secret["type"], Config.Scanner.SecretsFileName, secret["hashed_secret"])
+}
+
+// review threads are only exposed through the GraphQL API, the request goes through the REST
+// client so it uses the same host and token, "../graphql" resolves to /graphql on github.com
+// and to /api/graphql on GitHub Enterprise Server
+func (gitService gitServiceImplementation) graphQL(query string, variables map[string]interface{}, result interface{}) error {
+	client := gitService.GetGitHubClient()
+	request, err := client.NewRequest("POST", "../graphql", graphQLRequestParams{Query: query, Variables: variables})
+	if err != nil {
+		return err
+	}
+	var raw json.RawMessage
+	if _, err := client.Do(gitService.context(), request, &raw); err != nil {
+		return err
+	}
+	var failure struct {
+		Errors []graphQLErrorParams `json:"errors"`
+	}
+	if err := json.Unmarshal(raw, &failure); err == nil && len(failure.Errors) > 0 {
+		return errors.New(failure.Errors[0].Message)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// review threads opened by the bot, keyed by the hashed secret they are about
+func (gitService gitServiceImplementation) secretReviewThreads(owner string, repo string, number int) (map[string]reviewThreadParams, error) {
+	threads := map[string]reviewThreadParams{}
+	variables := map[string]interface{}{"owner": owner, "repo": repo, "number": number, "cursor": nil}
+	for {
+		var response reviewThreadsResponseParams
+		if err := gitService.graphQL(reviewThreadsQuery, variables, &response); err != nil {
+			return nil, err
+		}
+		page := response.Data.Repository.PullRequest.ReviewThreads
+		for _, thread := range page.Nodes {
+			if len(thread.Comments.Nodes) == 0 {
+				continue
+			}
+			if match := secretMarkerRegex.FindStringSubmatch(thread.Comments.Nodes[0].Body); match != nil {
+				threads[match[1]] = thread
+			}
+		}
+		if !page.PageInfo.HasNextPage {
+			return threads, nil
+		}
+		variables["cursor"] = page.PageInfo.EndCursor
+	}
+}
+
+// comments once per hashed secret on the line introducing it, reopens the thread when a
+// removed secret comes back and resolves the threads of secrets removed or audited since
+func (gitService gitServiceImplementation) SyncSecretReviewComments(owner string, repo string, number int, headSHA string, unaudited SecretUpdateMap) error {
+	threads, err := gitService.secretReviewThreads(owner, repo, number)
+	if err != nil {
+		gitService.logger().Error().Msgf("Error listing review threads of %s/%s#%d: %v", owner, repo, number, err)
+		return err
+	}
+	client := gitService.GetGitHubClient()
+	ctx := gitService.context()
+	current := map[string]bool{}
+
+	filenames := make([]string, 0, len(unaudited))
+	for filename := range unaudited {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		for _, secret := range unaudited[filename] {
+			hash := fmt.Sprintf("%v", secret["hashed_secret"])
+			if current[hash] {
+				continue
+			}
+			current[hash] = true
+			if thread, ok := threads[hash]; ok {
+				if thread.IsResolved {
+					gitService.logger().Info().Msgf("Secret %s is back in %s/%s#%d, reopening its thread", hash, owner, repo, number)
+					if err := gitService.graphQL(unresolveThreadMutation, map[string]interface{}{"threadId": thread.ID}, nil); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			line := 1
+			if number, ok := secret["line_number"].(float64); ok {
+				line = int(number)
+			}
+			_, _, err := client.PullRequests.CreateComment(ctx, owner, repo, number, &github.PullRequestComment{
+				Body:     github.String(secretReviewComment(secret)),
+				CommitID: github.String(headSHA),
+				Path:     github.String(filename),
+				Line:     github.Int(line),
+				Side:     github.String("RIGHT"),
+			})
+			if err != nil {
+				// lines outside of the diff cannot be commented, the check run still reports them
+				if lineOutsideDiff(err) {
+					gitService.logger().Info().Msgf("Line %s:%d is not part of the diff of %s/%s#%d", filename, line, owner, repo, number)
+					continue
+				}
+				gitService.logger().Error().Msgf("Error commenting on %s/%s#%d: %v", owner, repo, number, err)
+				return err
+			}
+		}
+	}
+
+	for hash, thread := range threads {
+		if current[hash] || thread.IsResolved {
+			continue
+		}
+		gitService.logger().Info().Msgf("Secret %s was removed or audited in %s/%s#%d, resolving its thread", hash, owner, repo, number)
+		if err := gitService.graphQL(resolveThreadMutation, map[string]interface{}{"threadId": thread.ID}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GitHub answers 422 with an error on the line of the review thread when it is not part of the diff
+func lineOutsideDiff(err error) bool {
+	var githubError *github.ErrorResponse
+	if !errors.As(err, &githubError) || githubError.Response == nil || githubError.Response.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	messages := []string{githubError.Message}
+	for _, detail := range githubError.Errors {
+		messages = append(messages, detail.Field+" "+detail.Message)
+	}
+	for _, message := range messages {
+		if strings.Contains(message, "line must be part of the diff") || strings.Contains(message, "line could not be resolved") {
+			return true
+		}
+	}
+	return false
+}
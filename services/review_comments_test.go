@@ -0,0 +1,118 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/google/go-github/v33/github"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Review comments", func() {
+	var mux *http.ServeMux
+	var server *httptest.Server
+	var threads string
+	var graphQLError string
+	var comments []github.PullRequestComment
+	var mutations []graphQLRequestParams
+	unaudited := SecretUpdateMap{"config.py": {
+		{"type": "Secret Keyword", "hashed_secret": "new", "line_number": float64(4)},
+		{"type": "Secret Keyword", "hashed_secret": "back", "line_number": float64(9)},
+		{"type": "Secret Keyword", "hashed_secret": "open", "line_number": float64(12)},
+	}}
+	thread := func(id string, resolved bool, hash string) string {
+		return fmt.Sprintf(`{"id": "%s", "isResolved": %t, "comments": {"nodes": [{"body": "text\n\n<!-- detect-secrets hashed_secret=%s -->"}]}}`, id, resolved, hash)
+	}
+
+	BeforeEach(func() {
+		comments, mutations, graphQLError = nil, nil, ""
+		threads = strings.Join([]string{
+			thread("T1", true, "back"), thread("T2", false, "open"), thread("T3", false, "removed"),
+			`{"id": "T4", "isResolved": false, "comments": {"nodes": [{"body": "a human review"}]}}`,
+		}, ",")
+		mux = http.NewServeMux()
+		server = httptest.NewServer(mux)
+		ThirdPartyContext = thirdPartyContextImpl{}
+		ThirdPartyOauth = thirdPartyOauthImpl{}
+		ThirdPartyGitHub = fakeGitHubClientFactory{baseURL: server.URL + "/api"}
+		mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+			var request graphQLRequestParams
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			if graphQLError != "" {
+				fmt.Fprintf(w, `{"errors": [{"message": "%s"}]}`, graphQLError)
+				return
+			}
+			if strings.HasPrefix(request.Query, "mutation") {
+				mutations = append(mutations, request)
+				fmt.Fprint(w, `{"data": {}}`)
+				return
+			}
+			fmt.Fprintf(w, `{"data": {"repository": {"pullRequest": {"reviewThreads": {"pageInfo": {"hasNextPage": false}, "nodes": [%s]}}}}}`, threads)
+		})
+		mux.HandleFunc("/api/repos/john/repo/pulls/7/comments", func(w http.ResponseWriter, r *http.Request) {
+			var comment github.PullRequestComment
+			_ = json.NewDecoder(r.Body).Decode(&comment)
+			comments = append(comments, comment)
+			fmt.Fprint(w, `{"id": 1}`)
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+		ThirdPartyGitHub = thirdPartyGitHubImpl{}
+	})
+
+	It("comments once per new secret and keeps threads in sync with the unaudited secrets", func() {
+		Expect(gitServiceImplementation{}.SyncSecretReviewComments("john", "repo", 7, "head", unaudited)).To(Succeed())
+		Expect(comments).To(HaveLen(1))
+		Expect(comments[0].GetPath()).To(Equal("config.py"))
+		Expect(comments[0].GetLine()).To(Equal(4))
+		Expect(comments[0].GetSide()).To(Equal("RIGHT"))
+		Expect(comments[0].GetCommitID()).To(Equal("head"))
+		Expect(comments[0].GetBody()).To(ContainSubstring("Secret Keyword"))
+		Expect(comments[0].GetBody()).To(ContainSubstring("<!-- detect-secrets hashed_secret=new -->"))
+
+		Expect(mutations).To(HaveLen(2))
+		Expect(mutations[0].Query).To(ContainSubstring("unresolveReviewThread"))
+		Expect(mutations[0].Variables["threadId"]).To(Equal("T1"))
+		Expect(mutations[1].Query).To(ContainSubstring("resolveReviewThread"))
+		Expect(mutations[1].Variables["threadId"]).To(Equal("T3"))
+	})
+
+	It("skips lines that are not part of the diff", func() {
+		mux.HandleFunc("/api/repos/john/repo/pulls/8/comments", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message": "pull_request_review_thread.line must be part of the diff"}`)
+		})
+		Expect(gitServiceImplementation{}.SyncSecretReviewComments("john", "repo", 8, "head", unaudited)).To(Succeed())
+	})
+
+	It("skips lines that cannot be resolved in the diff", func() {
+		mux.HandleFunc("/api/repos/john/repo/pulls/8/comments", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message": "Validation Failed", "errors": [{"resource": "PullRequestReviewComment", "code": "custom", "field": "pull_request_review_thread.line", "message": "could not be resolved"}]}`)
+		})
+		Expect(gitServiceImplementation{}.SyncSecretReviewComments("john", "repo", 8, "head", unaudited)).To(Succeed())
+	})
+
+	It("returns the other validation errors", func() {
+		mux.HandleFunc("/api/repos/john/repo/pulls/8/comments", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message": "Validation Failed", "errors": [{"resource": "PullRequestReviewComment", "code": "invalid", "field": "commit_id"}]}`)
+		})
+		err := gitServiceImplementation{}.SyncSecretReviewComments("john", "repo", 8, "head", unaudited)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("422"))
+	})
+
+	It("returns GraphQL errors", func() {
+		graphQLError = "Resource not accessible by integration"
+		err := gitServiceImplementation{}.SyncSecretReviewComments("john", "repo", 7, "head", unaudited)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Resource not accessible"))
+	})
+})
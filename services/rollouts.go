@@ -0,0 +1,88 @@
+package services
+
+import (
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"time"
+)
+
+const (
+	RolloutPending = "pending"
+	RolloutRunning = "running"
+	RolloutCreated = "created"
+	RolloutSkipped = "skipped"
+	RolloutFailed  = "failed"
+	RolloutDone    = "done"
+)
+
+type RolloutRepo struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// progress of the baseline creation across the repositories of an organization
+type RolloutReport struct {
+	ID        string         `json:"id"`
+	Org       string         `json:"org"`
+	Status    string         `json:"status"`
+	StartedAt time.Time      `json:"started_at"`
+	Total     int            `json:"total"`
+	Counts    map[string]int `json:"counts"`
+	Repos     []RolloutRepo  `json:"repos"`
+}
+
+// tracks the organization rollouts so their progress can be reported while they run and a
+// rollout resumed after a restart knows which repos are finished
+type rolloutStoreInterface interface {
+	Start(id string, org string, repos []string)
+	Update(id string, repo string, status string, message string)
+	Finish(id string)
+	Report(id string) (RolloutReport, bool)
+}
+
+// keeps the rollouts in the history database, a rollout that cannot be saved is logged and
+// reported as missing
+type rolloutStoreImplementation struct{}
+
+func NewRolloutStore() rolloutStoreInterface {
+	return rolloutStoreImplementation{}
+}
+
+func (store rolloutStoreImplementation) Start(id string, org string, repos []string) {
+	report := RolloutReport{ID: id, Org: org, Status: RolloutRunning, StartedAt: time.Now(), Total: len(repos)}
+	for _, repo := range repos {
+		report.Repos = append(report.Repos, RolloutRepo{Name: repo, Status: RolloutPending})
+	}
+	if err := History.SaveRollout(report); err != nil {
+		ZeroLogger.Error().Msgf("Error saving rollout %s of %s: %v", id, org, err)
+	}
+}
+
+func (store rolloutStoreImplementation) Update(id string, repo string, status string, message string) {
+	if err := History.UpdateRolloutRepo(id, repo, status, message); err != nil {
+		ZeroLogger.Error().Msgf("Error saving the %s status of %s in rollout %s: %v", status, repo, id, err)
+	}
+}
+
+func (store rolloutStoreImplementation) Finish(id string) {
+	if err := History.FinishRollout(id); err != nil {
+		ZeroLogger.Error().Msgf("Error finishing rollout %s: %v", id, err)
+	}
+}
+
+// the rollout with the number of repositories in each status
+func (store rolloutStoreImplementation) Report(id string) (RolloutReport, bool) {
+	report, ok, err := History.Rollout(id)
+	if err != nil {
+		ZeroLogger.Error().Msgf("Error reading rollout %s: %v", id, err)
+		return RolloutReport{}, false
+	}
+	if !ok {
+		return RolloutReport{}, false
+	}
+	report.Counts = map[string]int{}
+	for _, repo := range report.Repos {
+		report.Counts[repo.Status]++
+	}
+	return report, true
+}
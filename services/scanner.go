@@ -0,0 +1,21 @@
+package services
+
+import (
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"os/exec"
+)
+
+type thirdPartyScannerInterface interface {
+	Scan(path string, files ...string) ([]byte, error)
+}
+
+type thirdPartyScannerImpl struct{}
+
+// runs detect-secrets in the clone and returns the baseline it prints, only the given
+// files are scanned when there are any
+func (scanner thirdPartyScannerImpl) Scan(path string, files ...string) ([]byte, error) {
+	ZeroLogger.Info().Msgf("Scanning %s for secrets", path)
+	command := exec.Command(Config.Scanner.Binary, append([]string{"scan"}, files...)...)
+	command.Dir = path
+	return command.Output()
+}
@@ -5,29 +5,63 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
 	"golang.org/x/oauth2"
-	//. "github.com/eliezer-borde-globant/EBGoProject/utils"
 	"github.com/google/go-github/v33/github"
 	"net/http"
+	"time"
 )
 
 var (
-	GitServiceObject gitServiceInterface = gitServiceImplementation{}
+	GitServiceObject gitHubServiceInterface = gitServiceImplementation{}
+	GitLabServiceObject gitServiceInterface = gitLabServiceImplementation{}
+	BitbucketServiceObject gitServiceInterface = bitbucketServiceImplementation{}
 	ThirdPartyContext thirdPartyContextInterface = thirdPartyContextImpl{}
 	ThirdPartyOauth thirdPartyOauthInterface = thirdPartyOauthImpl{}
 	ThirdPartyGitHub thirdPartyGitHubInterface = thirdPartyGitHubImpl{}
+	ThirdPartyScanner thirdPartyScannerInterface = thirdPartyScannerImpl{}
+	GitHubRateLimits rateLimitStoreInterface = NewRateLimitStore()
+	Clones cloneRegistryInterface = NewCloneRegistry()
+	WebhookDeliveries deliveryStoreInterface = NewDeliveryStore(24 * time.Hour)
+	OrgRollouts rolloutStoreInterface = NewRolloutStore()
 )
 
+// built by main from the configuration, a queue started here would keep its workers running
+// once replaced and a database opened here would be left open
+var (
+	History historyStoreInterface
+	JobQueue jobQueueInterface
+	Health healthCheckerInterface
+	Auth authenticatorInterface
+	IdempotencyKeys idempotencyStoreInterface
+)
+
+// provider neutral operations used by the create and update flows
 type gitServiceInterface interface {
+	CheckUserAccessRepo(owner string, repo string) error
+	CloneRepo(owner string, repo string) (*git.Repository, string, error)
+	CreateBranchRepo(repoGit *git.Repository, owner string, repoName string, action string, requestID string) (string, string, error)
+	CheckoutBranch(repoGit *git.Repository, branch string) error
+	CreateSecretFile(path string, secretFile string) error
+	EditSecretFile(path string, secretsChanges SecretUpdateMap) error
+	WriteAuditFile(path string, decisions []AuditDecision) error
+	CommitAndPush(owner string, originalOwner string, repo string, action string, requestID string, repoGit *git.Repository) error
+	OpenPullRequest(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string) (int, error)
+	ForkRepo(owner string, repo string) (forkedOwner interface{}, gitURL interface{}, err error)
+	CheckForkedRepo(owner string, repo string) error
+}
+
+type gitHubServiceInterface interface {
+	gitServiceInterface
 	GetGitHubClient() *github.Client
-	CheckUserAccessRepo(owner string, repo string) (*github.Repository, error)
-	//CloneRepo(owner string, repo string) (*git.Repository, string, error)
-	CreateBranchRepo(repoGit *git.Repository, repoName string, action string) (string, string, error)
-	//CreateSecretFile(path string, secretFile string) error
-	//EditSecretFile(path string, secretsChanges SecretUpdateMap ) error
-	//CreateCommitAndPr(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string, repoGit *git.Repository) error
-	//ForkRepo(owner string, repo string) (forkedOwner interface{}, gitURL interface{}, err error)
-	//CheckForkedRepo(url string) error
+	PullRequestFiles(owner string, repo string, number int) ([]string, error)
+	CheckoutPullRequest(repoGit *git.Repository, number int, headSHA string) error
+	OpenBaselinePullRequest(owner string, repo string) (BaselinePullRequest, bool, error)
+	FileContent(owner string, repo string, path string, ref string) ([]byte, error)
+	CreateCheckRun(owner string, repo string, headSHA string, unaudited SecretUpdateMap) error
+	SyncSecretReviewComments(owner string, repo string, number int, headSHA string, unaudited SecretUpdateMap) error
+	CreateIssueComment(owner string, repo string, number int, body string) error
+	ListOrgRepos(org string, filter RepoFilter) ([]string, error)
 }
 
 type thirdPartyContextInterface interface {
@@ -41,14 +75,25 @@ type thirdPartyOauthInterface interface {
 
 type thirdPartyGitHubInterface interface {
 	NewClient(*http.Client) *github.Client
+	NewEnterpriseClient(string, string, *http.Client) (*github.Client, error)
 	Get(*github.Client, context.Context, string, string) (*github.Repository, *github.Response, error)
 	Head(*git.Repository) (*plumbing.Reference, error)
 	Worktree(*git.Repository) (*git.Worktree, error)
 	Fetch(*git.Repository) error
+	Checkout(*git.Worktree, *git.CheckoutOptions) error
 }
 
 
-type gitServiceImplementation struct {}
+// git operations on the local clone, shared by every provider
+type gitWorkspaceImplementation struct {
+	ctx context.Context
+}
+type gitServiceImplementation struct {
+	gitWorkspaceImplementation
+	Host string
+}
+type gitLabServiceImplementation struct { gitWorkspaceImplementation }
+type bitbucketServiceImplementation struct { gitWorkspaceImplementation }
 type thirdPartyContextImpl struct {}
 type thirdPartyOauthImpl struct {}
 type thirdPartyGitHubImpl struct {}
@@ -70,6 +115,10 @@ func (service thirdPartyGitHubImpl) NewClient(httpClient *http.Client) *github.C
 	return github.NewClient(httpClient)
 }
 
+func (service thirdPartyGitHubImpl) NewEnterpriseClient(baseURL string, uploadURL string, httpClient *http.Client) (*github.Client, error) {
+	return github.NewEnterpriseClient(baseURL, uploadURL, httpClient)
+}
+
 func (service thirdPartyGitHubImpl) Get(client *github.Client, ctx context.Context, owner string, repo string) (*github.Repository, *github.Response, error) {
 	return client.Repositories.Get(ctx, owner, repo)
 }
@@ -86,4 +135,8 @@ func (service thirdPartyGitHubImpl) Fetch(repoGit *git.Repository) error {
 	return repoGit.Fetch(&git.FetchOptions{
 		RefSpecs: []config.RefSpec{"refs/*:refs/*", "HEAD:refs/heads/HEAD"},
 	})
+}
+
+func (service thirdPartyGitHubImpl) Checkout(workingBranch *git.Worktree, opts *git.CheckoutOptions) error {
+	return workingBranch.Checkout(opts)
 }
\ No newline at end of file
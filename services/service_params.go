@@ -6,3 +6,100 @@ type forkResponseParams struct {
 	} `json:"owner"`
 	GitURL string `json:"git_url"`
 }
+
+type gitLabProjectParams struct {
+	ID                int    `json:"id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	HTTPURLToRepo     string `json:"http_url_to_repo"`
+	ImportStatus      string `json:"import_status"`
+	Namespace         struct {
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+}
+
+type gitLabUserParams struct {
+	Username string `json:"username"`
+}
+
+type gitLabMergeRequestParams struct {
+	IID             int    `json:"iid,omitempty"`
+	SourceBranch    string `json:"source_branch"`
+	TargetBranch    string `json:"target_branch"`
+	SourceProjectID int    `json:"source_project_id,omitempty"`
+	TargetProjectID int    `json:"target_project_id"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+}
+
+type bitbucketRepositoryParams struct {
+	Slug    string `json:"slug"`
+	State   string `json:"state"`
+	Project struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	Links struct {
+		Clone []struct {
+			Href string `json:"href"`
+			Name string `json:"name"`
+		} `json:"clone"`
+	} `json:"links"`
+}
+
+type bitbucketRefParams struct {
+	ID         string `json:"id"`
+	Repository struct {
+		Slug    string `json:"slug"`
+		Project struct {
+			Key string `json:"key"`
+		} `json:"project"`
+	} `json:"repository"`
+}
+
+type bitbucketPullRequestParams struct {
+	ID          int                `json:"id,omitempty"`
+	Version     int                `json:"version"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	FromRef     *bitbucketRefParams `json:"fromRef,omitempty"`
+	ToRef       *bitbucketRefParams `json:"toRef,omitempty"`
+}
+
+type bitbucketPullRequestPageParams struct {
+	Values []bitbucketPullRequestParams `json:"values"`
+}
+
+type graphQLRequestParams struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLErrorParams struct {
+	Message string `json:"message"`
+}
+
+type reviewThreadParams struct {
+	ID         string `json:"id"`
+	IsResolved bool   `json:"isResolved"`
+	Comments   struct {
+		Nodes []struct {
+			Body string `json:"body"`
+		} `json:"nodes"`
+	} `json:"comments"`
+}
+
+type reviewThreadsResponseParams struct {
+	Data struct {
+		Repository struct {
+			PullRequest struct {
+				ReviewThreads struct {
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+					Nodes []reviewThreadParams `json:"nodes"`
+				} `json:"reviewThreads"`
+			} `json:"pullRequest"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []graphQLErrorParams `json:"errors"`
+}
@@ -1,278 +1,497 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	. "github.com/eliezer-borde-globant/EBGoProject/utils"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/google/go-github/v33/github"
+	"github.com/rs/zerolog"
 	"golang.org/x/oauth2"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
 	"strings"
+	"time"
 )
 
 
+
+// github.com unless the service was created for a GitHub Enterprise Server host
+func (gitService gitServiceImplementation) HostConfig() GitHubHost {
+	if host, ok := Config.GitHub.Hosts[gitService.Host]; ok {
+		return host
+	}
+	return Config.GitHub.DefaultHost()
+}
+
+// REST API url of the calls made without the client
+func (gitService gitServiceImplementation) apiURL(path string) string {
+	baseURL := gitService.HostConfig().BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.github.com/"
+	}
+	apiURL, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/" + path)
+	if err != nil {
+		gitService.logger().Error().Msgf("Invalid GitHub base url %s: %v", baseURL, err)
+		return ""
+	}
+	return apiURL.String()
+}
+
+// context of the API calls, the span of the traced method calling the service when there is one
+func (workspace gitWorkspaceImplementation) context() context.Context {
+	if workspace.ctx != nil {
+		return workspace.ctx
+	}
+	return ThirdPartyContext.Background()
+}
+
+// logger of the request the service was called for
+func (workspace gitWorkspaceImplementation) logger() *zerolog.Logger {
+	return Logger(workspace.ctx)
+}
+
+func (gitService gitServiceImplementation) withContext(ctx context.Context) gitServiceInterface {
+	gitService.ctx = ctx
+	return gitService
+}
+
+// client for the calls made without the GitHub client, sharing its rate limit handling and spans
+func (gitService gitServiceImplementation) httpClient() *http.Client {
+	return &http.Client{Transport: NewTracingTransport(NewRateLimitTransport(gitService.Host, nil))}
+}
+
+// sends a request without body to an apiURL under the context of the service, the token
+// goes in the Authorization header so it never shows up in the url of an error
+func (gitService gitServiceImplementation) apiRequest(method string, requestURL string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(gitService.context(), method, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("token %s", gitService.HostConfig().Token))
+	if method == http.MethodPost {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return gitService.httpClient().Do(request)
+}
+
 func (gitService gitServiceImplementation) GetGitHubClient() *github.Client {
+	host := gitService.HostConfig()
 	ctx := ThirdPartyContext.Background()
 	ts := ThirdPartyOauth.StaticTokenSource(
-		&oauth2.Token{AccessToken: GitHubToken},
+		&oauth2.Token{AccessToken: host.Token},
 	)
 
 	tc := ThirdPartyOauth.NewClient(ctx, ts)
-	return ThirdPartyGitHub.NewClient(tc)
+	tc = &http.Client{Transport: NewTracingTransport(NewRateLimitTransport(gitService.Host, tc.Transport)), Timeout: tc.Timeout}
+	if host.BaseURL == "" {
+		return ThirdPartyGitHub.NewClient(tc)
+	}
+	client, err := ThirdPartyGitHub.NewEnterpriseClient(host.BaseURL, host.UploadURL, tc)
+	if err != nil {
+		gitService.logger().Error().Msgf("Error creating GitHub Enterprise client for %s: %v", host.BaseURL, err)
+		return ThirdPartyGitHub.NewClient(tc)
+	}
+	return client
 }
 
-func (gitService gitServiceImplementation) CheckUserAccessRepo(owner string, repo string) (*github.Repository, error) {
-	ZeroLogger.Info().Msgf("check user has access to %s/%s", owner, repo)
-	ctx := ThirdPartyContext.Background()
-	client := GitServiceObject.GetGitHubClient()
-	repoInfo, _, err := ThirdPartyGitHub.Get(client, ctx, owner, repo)
+// checks the token can read the repo and, for a GitHub user identity in the context, that the
+// user can push to it, the permission needed to audit its secrets
+func (gitService gitServiceImplementation) CheckUserAccessRepo(owner string, repo string) error {
+	gitService.logger().Info().Msgf("check user has access to %s/%s", owner, repo)
+	ctx := gitService.context()
+	client := gitService.GetGitHubClient()
+	_, _, err := ThirdPartyGitHub.Get(client, ctx, owner, repo)
 	if err != nil {
-		ZeroLogger.Error().Msgf("Error fetching repo: %v", err)
-		return nil, err
+		gitService.logger().Error().Msgf("Error fetching repo: %v", err)
+		return err
+	}
+	identity, ok := IdentityFrom(ctx)
+	if !ok || identity.Method != AuthMethodGitHub {
+		return nil
 	}
-	return repoInfo, nil
+	permission, _, err := client.Repositories.GetPermissionLevel(ctx, owner, repo, identity.Name)
+	if err != nil {
+		gitService.logger().Error().Msgf("Error getting the permission of %s on %s/%s: %v", identity.Name, owner, repo, err)
+		return err
+	}
+	switch permission.GetPermission() {
+	case "admin", "write":
+		return nil
+	}
+	return NewServiceError(ErrCodeForbidden, fmt.Sprintf("%s has %s permission on %s/%s, write is required", identity.Name, permission.GetPermission(), owner, repo), nil)
+}
+
+
+func (gitService gitServiceImplementation) CloneRepo(owner string, repo string) (*git.Repository, string, error) {
+	host := gitService.HostConfig()
+	return gitService.CloneFromURL(owner, repo, fmt.Sprintf("https://%s@%s/%s/%s", host.Token, host.GitHost, owner, repo))
 }
 
-//func (gitService gitServiceImplementation) CloneRepo(owner string, repo string) (*git.Repository, string, error) {
-//	path := fmt.Sprintf("/tmp/%s-%s", owner, repo)
-//
-//	ZeroLogger.Info().Msgf("Creating folder to clone %s", path)
-//	if _, err := os.Stat(path); !os.IsNotExist(err) {
-//		err := os.RemoveAll(path)
-//		if err != nil {
-//			ZeroLogger.Fatal().Msgf("Error path to clone repo from %s/%s, error: %v", owner, repo, err)
-//			return nil, "", err
-//		}
-//	}
-//	ZeroLogger.Info().Msg("Starting to clone Repo")
-//	repoInfo, err := git.PlainClone(path, false, &git.CloneOptions{
-//		URL:      fmt.Sprintf("https://%s@github.com/%s/%s", GitHubToken, owner, repo),
-//		Progress: os.Stdout,
-//	})
-//	if err != nil {
-//		ZeroLogger.Fatal().Msgf("Error Cloning repo from %s/%s, error: %v", owner, repo, err)
-//		return nil, "", err
-//	}
-//	ZeroLogger.Info().Msgf("Repo was cloned")
-//	return repoInfo, path, nil
-//}
-//
-func (gitService gitServiceImplementation) CreateBranchRepo(repoGit *git.Repository, repoName string, action string) (string, string, error) {
-	ZeroLogger.Info().Msgf("Creating Branch to update secret file in repo %s", repoName)
+// clones into a new directory under scanner.work_dir, so concurrent flows on the same repo
+// never share a tree. The flow removes it with Clones.Remove once it is done
+func (workspace gitWorkspaceImplementation) CloneFromURL(owner string, repo string, cloneURL string) (*git.Repository, string, error) {
+	path, err := ioutil.TempDir(Config.Scanner.WorkDir, fmt.Sprintf("%s-%s-", owner, repo))
+	if err != nil {
+		workspace.logger().Error().Msgf("Error creating folder to clone repo from %s/%s, error: %v", owner, repo, err)
+		return nil, "", err
+	}
+	workspace.logger().Info().Msgf("Created folder %s to clone", path)
+	Clones.Add(path)
+	workspace.logger().Info().Msg("Starting to clone Repo")
+	repoInfo, err := git.PlainClone(path, false, &git.CloneOptions{
+		URL:      cloneURL,
+		Progress: os.Stdout,
+	})
+	if err != nil {
+		workspace.logger().Error().Msgf("Error Cloning repo from %s/%s, error: %v", owner, repo, err)
+		Clones.Remove(path)
+		return nil, "", err
+	}
+	workspace.logger().Info().Msgf("Repo was cloned")
+	return repoInfo, path, nil
+}
+
+func (workspace gitWorkspaceImplementation) CreateBranchRepo(repoGit *git.Repository, owner string, repoName string, action string, requestID string) (string, string, error) {
+	workspace.logger().Info().Msgf("Creating Branch to update secret file in repo %s", repoName)
 	headRef, err := ThirdPartyGitHub.Head(repoGit)
 	if err != nil {
-		ZeroLogger.Error().Msgf("Error Creating Branch to update secret file in repo %s, error: %v", repoName, err)
+		workspace.logger().Error().Msgf("Error Creating Branch to update secret file in repo %s, error: %v", repoName, err)
 		return "", "", err
 	}
 	headBranchName := strings.ReplaceAll(headRef.Name().String(), "refs/heads/", "")
-	fmt.Println(headBranchName)
-	branch := fmt.Sprintf("secret_scanner_api/%s/%s/secrets_baseline_file", repoName, action)
-	fmt.Println(branch)
+	branch, err := BranchName(owner, repoName, action, requestID)
+	if err != nil {
+		workspace.logger().Error().Msgf("Error naming Branch in repo %s, error: %v", repoName, err)
+		return "", "", err
+	}
+	workspace.logger().Debug().Msgf("Branch %s will be created from %s", branch, headBranchName)
 	workingBranch, err := ThirdPartyGitHub.Worktree(repoGit)
 	if err != nil {
-		ZeroLogger.Error().Msgf("Error Creating Branch to update secret file in repo %s, error: %v", repoName, err)
+		workspace.logger().Error().Msgf("Error Creating Branch to update secret file in repo %s, error: %v", repoName, err)
 		return "", "", err
 	}
-	fmt.Println(workingBranch)
-	ZeroLogger.Info().Msgf("Fetching all Branches from %s", repoName)
+	workspace.logger().Info().Msgf("Fetching all Branches from %s", repoName)
 	err = ThirdPartyGitHub.Fetch(repoGit)
 	if err != nil {
-		ZeroLogger.Error().Msgf("Error fetching remote Branches from repo %s, error: %v", repoName, err)
+		workspace.logger().Error().Msgf("Error fetching remote Branches from repo %s, error: %v", repoName, err)
 		return "", "", err
 	}
-	//ZeroLogger.Info().Msgf("Checking if the branch %s exists in %s", branch, repoName)
-	//err = workingBranch.Checkout(&git.CheckoutOptions{
-	//	Branch: plumbing.NewBranchReferenceName(branch),
-	//	Force:  true,
-	//})
-	//if err == nil {
-	//	ZeroLogger.Info().Msgf("Branch %s already exists in %s, Checking out...", branch, repoName)
-	//} else {
-	//	ZeroLogger.Info().Msgf("Creating new branch %s in %s", branch, repoName)
-	//	err = workingBranch.Checkout(&git.CheckoutOptions{
-	//		Hash:   headRef.Hash(),
-	//		Branch: plumbing.NewBranchReferenceName(branch),
-	//		Create: true,
-	//	})
-	//	if err != nil {
-	//		ZeroLogger.Fatal().Msgf("Error Creating Branch to update secret file in repo %s, error: %v", repoName, err)
-	//		return "", "", err
-	//	}
-	//	ZeroLogger.Info().Msgf("Branch created in (%s) with the name (%s)", repoName, branch)
-	//}
-	//return branch, headBranchName, err
-	return "", "", nil
+	workspace.logger().Info().Msgf("Checking if the branch %s exists in %s", branch, repoName)
+	err = ThirdPartyGitHub.Checkout(workingBranch, &git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Force:  true,
+	})
+	if err == nil {
+		workspace.logger().Info().Msgf("Branch %s already exists in %s, Checking out...", branch, repoName)
+	} else {
+		workspace.logger().Info().Msgf("Creating new branch %s in %s", branch, repoName)
+		err = ThirdPartyGitHub.Checkout(workingBranch, &git.CheckoutOptions{
+			Hash:   headRef.Hash(),
+			Branch: plumbing.NewBranchReferenceName(branch),
+			Create: true,
+		})
+		if err != nil {
+			workspace.logger().Error().Msgf("Error Creating Branch to update secret file in repo %s, error: %v", repoName, err)
+			return "", "", err
+		}
+		workspace.logger().Info().Msgf("Branch created in (%s) with the name (%s)", repoName, branch)
+	}
+	return branch, headBranchName, nil
+}
+
+// checks out branch as it is on the remote, to add commits to the branch of an open pull request
+func (workspace gitWorkspaceImplementation) CheckoutBranch(repoGit *git.Repository, branch string) error {
+	workspace.logger().Info().Msgf("Checking out the existing branch %s", branch)
+	workingBranch, err := ThirdPartyGitHub.Worktree(repoGit)
+	if err != nil {
+		return err
+	}
+	err = ThirdPartyGitHub.Fetch(repoGit)
+	if err != nil {
+		workspace.logger().Error().Msgf("Error fetching remote Branches, error: %v", err)
+		return err
+	}
+	err = ThirdPartyGitHub.Checkout(workingBranch, &git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Force:  true,
+	})
+	if err != nil {
+		workspace.logger().Error().Msgf("Error checking out the branch %s, error: %v", branch, err)
+	}
+	return err
+}
+
+func (workspace gitWorkspaceImplementation) CreateSecretFile(path string, secretFile string) error {
+	workspace.logger().Info().Msg(fmt.Sprintf("Creating Path %s to add %s file ", path, Config.Scanner.SecretsFileName))
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		workspace.logger().Error().Msgf("Error Creating Path %s, error: %v", path, err)
+		return err
+	}
+	path = fmt.Sprintf("%s/%s", path, Config.Scanner.SecretsFileName)
+	err := ioutil.WriteFile(path, []byte(secretFile), 0644)
+	workspace.logger().Info().Msgf("File was created with the content at path: '%s'", path)
+	if err != nil {
+		workspace.logger().Error().Msgf("Error creating %s file in, %s error: %v", Config.Scanner.SecretsFileName, path, err)
+		return err
+	}
+	return nil
 }
 
-//func (gitService gitServiceImplementation) CreateSecretFile(path string, secretFile string) error {
-//	ZeroLogger.Info().Msg(fmt.Sprintf("Creating Path %s to add %s file ", path, SecretsFileName))
-//	if _, err := os.Stat(path); os.IsNotExist(err) {
-//		ZeroLogger.Fatal().Msgf("Error Creating Path %s, error: %v", path, err)
-//		return err
-//	}
-//	path = fmt.Sprintf("%s/%s", path, SecretsFileName)
-//	err := ioutil.WriteFile(path, []byte(secretFile), 0644)
-//	ZeroLogger.Info().Msgf("File was created with the content at path: '%s'", path)
-//	if err != nil {
-//		ZeroLogger.Fatal().Msgf("Error creating %s file in, %s error: %v", SecretsFileName, path, err)
-//		return err
-//	}
-//	return nil
-//}
-//
-//func (gitService gitServiceImplementation) EditSecretFile(path string, secretsChanges SecretUpdateMap) error {
-//	ZeroLogger.Info().Msgf("Starting to edit the secret file at path: '%s'", path)
-//	path = fmt.Sprintf("%s/%s", path, SecretsFileName)
-//	dat, err := ioutil.ReadFile(path)
-//	if err != nil {
-//		return err
-//	}
-//	var fileStruct map[string]interface{}
-//	err = json.Unmarshal(dat, &fileStruct)
-//	if err != nil {
-//		return err
-//	}
-//	results, ok := fileStruct["results"].(map[string]interface{})
-//	if !ok {
-//		err := errors.New("could not parse the result data in secret file, please check the data")
-//		ZeroLogger.Fatal().Msgf("Error: %v", err)
-//		return err
-//	}
-//	for filename, secretData := range secretsChanges {
-//		_, ok := results[filename]
-//		if !ok {
-//			continue
-//		}
-//		for _, secret := range secretData {
-//			fileData := results[filename]
-//			fileSecrets := reflect.ValueOf(fileData)
-//			for i := 0; i < fileSecrets.Len(); i++ {
-//				value := fileSecrets.Index(i)
-//				secrets := value.Interface().(map[string]interface{})
-//				if secret["hashed_secret"] == secrets["hashed_secret"] && secret["line_number"] == secrets["line_number"] {
-//					secrets["is_secret"] = secret["is_secret"]
-//					value.Set(reflect.ValueOf(secrets))
-//				}
-//			}
-//			results[filename] = fileSecrets.Interface()
-//		}
-//	}
-//	fileStruct["results"] = results
-//	file, parseError := json.MarshalIndent(fileStruct, "", "  ")
-//	if parseError != nil {
-//		ZeroLogger.Fatal().Msgf("Cannot indent content of the file : %v", parseError)
-//		return parseError
-//	}
-//	writeFileError := ioutil.WriteFile(path, file, 0644)
-//	if writeFileError != nil {
-//		ZeroLogger.Fatal().Msgf("Error writing file: %v", parseError)
-//		return parseError
-//	}
-//	return nil
-//}
-//
-//func (gitService gitServiceImplementation) CreateCommitAndPr(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string, repoGit *git.Repository) error {
-//	ZeroLogger.Info().Msg("Getting current branch")
-//	ZeroLogger.Info().Msgf("Head Branch: %s", headBranch)
-//	ZeroLogger.Info().Msgf("Current Branch: %s", currentBranch)
-//	workingBranch, err := repoGit.Worktree()
-//	if err != nil {
-//		ZeroLogger.Info().Msgf("Error getting current branch '%s/%s'", owner, repo)
-//		return err
-//	}
-//	ZeroLogger.Info().Msgf("Adding %s file to new branch ", SecretsFileName)
-//	_, err = workingBranch.Add(SecretsFileName)
-//	if err != nil {
-//		return err
-//	}
-//	ZeroLogger.Info().Msgf("%s was added to stage ", SecretsFileName)
-//	ZeroLogger.Info().Msg("Committing Changes")
-//	commit, err := workingBranch.Commit(fmt.Sprintf("chore: %s secret baseline file", action), &git.CommitOptions{
-//		Author: &object.Signature{
-//			Name: owner,
-//			When: time.Now(),
-//		},
-//	})
-//	if err != nil {
-//		ZeroLogger.Fatal().Msgf("Error Committing changes: %v", err)
-//		return err
-//	}
-//	ZeroLogger.Info().Msg("Changes were committed")
-//	_, err = repoGit.CommitObject(commit)
-//	if err != nil {
-//		ZeroLogger.Fatal().Msgf("Error Committing: %v", err)
-//		return err
-//	}
-//	ZeroLogger.Info().Msgf("Commit created in '%s/%s'", owner, repo)
-//
-//	ZeroLogger.Info().Msg("Pushing changes to remote")
-//	obj := &git.PushOptions{}
-//	err = repoGit.Push(obj)
-//	if err != nil {
-//		return err
-//	}
-//	ZeroLogger.Info().Msgf("Branch was pushed '%s/%s'", owner, repo)
-//	githubClient := GitServiceObject.GetGitHubClient()
-//	newPR := &github.NewPullRequest{
-//		Title:               github.String(fmt.Sprintf("[Detect Secrets] %s Secret BaseLine File", action)),
-//		Head:                github.String(fmt.Sprintf("%s:%s", owner, currentBranch)),
-//		Base:                github.String(headBranch),
-//		Body:                github.String(description),
-//		MaintainerCanModify: github.Bool(true),
-//	}
-//
-//	_, _, err = githubClient.PullRequests.Create(context.Background(), originalOwner, repo, newPR)
-//	if err != nil {
-//		ZeroLogger.Info().Msgf("PR success Updated! '%s/%s'", owner, repo)
-//		return err
-//	}
-//	ZeroLogger.Info().Msgf("PR success Created! '%s/%s'", owner, repo)
-//	return nil
-//}
-//
-//func (gitService gitServiceImplementation) ForkRepo(owner string, repo string) (forkedOwner interface{}, gitURL interface{}, err error) {
-//	ZeroLogger.Info().Msgf("Forking repo from '%s/%s'", owner, repo)
-//	forkURL := fmt.Sprintf("https://%s@api.github.com/repos/%s/%s/forks", GitHubToken, owner, repo)
-//	resp, errPost := http.PostForm(forkURL, url.Values{})
-//
-//	if errPost != nil {
-//		ZeroLogger.Fatal().Msgf("Error forking Repo from '%s/%s' ", owner, repo)
-//		return "", "", errPost
-//	}
-//
-//	body, errBody := ioutil.ReadAll(resp.Body)
-//
-//	if errBody != nil {
-//		ZeroLogger.Fatal().Msgf("Error forking Repo from '%s/%s' ", owner, repo)
-//		return "", "", errBody
-//	}
-//
-//	ZeroLogger.Info().Msgf("Status Code after forking the repo: %d", resp.StatusCode)
-//	formatBody := string(body)
-//
-//	var result = forkResponseParams{}
-//
-//	parseError := json.Unmarshal([]byte(formatBody), &result)
-//	if parseError != nil {
-//		ZeroLogger.Fatal().Msgf("Contents seems to be incorrect in the JSON received: %v", parseError)
-//		return nil, nil, parseError
-//	}
-//	ZeroLogger.Info().Msgf("User who forked: %s", result.Owner.Login)
-//
-//	return result.Owner.Login, result.GitURL, err
-//}
-//
-//func (gitService gitServiceImplementation) CheckForkedRepo(getURL string) error{
-//	ZeroLogger.Info().Msgf("Checking if repo was forked properly")
-//	for {
-//		response, err := http.Get(getURL)
-//		if response.StatusCode == 200 {
-//			ZeroLogger.Info().Msgf("Repo has been forked successfully")
-//			break
-//		}
-//		if err != nil {
-//			ZeroLogger.Fatal().Msgf("Error: %v", err)
-//			return err
-//		}
-//	}
-//	return  nil
-//}
\ No newline at end of file
+func (workspace gitWorkspaceImplementation) EditSecretFile(path string, secretsChanges SecretUpdateMap) error {
+	workspace.logger().Info().Msgf("Starting to edit the secret file at path: '%s'", path)
+	path = fmt.Sprintf("%s/%s", path, Config.Scanner.SecretsFileName)
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var fileStruct map[string]interface{}
+	err = json.Unmarshal(dat, &fileStruct)
+	if err != nil {
+		return err
+	}
+	results, ok := fileStruct["results"].(map[string]interface{})
+	if !ok {
+		err := NewServiceError(ErrCodeInvalidInput, "could not parse the result data in secret file, please check the data", nil)
+		workspace.logger().Error().Msgf("Error: %v", err)
+		return err
+	}
+	for filename, secretData := range secretsChanges {
+		_, ok := results[filename]
+		if !ok {
+			continue
+		}
+		for _, secret := range secretData {
+			fileData := results[filename]
+			fileSecrets := reflect.ValueOf(fileData)
+			for i := 0; i < fileSecrets.Len(); i++ {
+				value := fileSecrets.Index(i)
+				secrets := value.Interface().(map[string]interface{})
+				if secret["hashed_secret"] == secrets["hashed_secret"] && secret["line_number"] == secrets["line_number"] {
+					secrets["is_secret"] = secret["is_secret"]
+					value.Set(reflect.ValueOf(secrets))
+				}
+			}
+			results[filename] = fileSecrets.Interface()
+		}
+	}
+	fileStruct["results"] = results
+	file, parseError := json.MarshalIndent(fileStruct, "", "  ")
+	if parseError != nil {
+		workspace.logger().Error().Msgf("Cannot indent content of the file : %v", parseError)
+		return parseError
+	}
+	writeFileError := ioutil.WriteFile(path, file, 0644)
+	if writeFileError != nil {
+		workspace.logger().Error().Msgf("Error writing file: %v", writeFileError)
+		return writeFileError
+	}
+	return nil
+}
+
+// adds the secrets file, and the audit file when there is one, to the current branch, commits it and pushes it to the fork
+func (workspace gitWorkspaceImplementation) CommitAndPush(owner string, originalOwner string, repo string, action string, requestID string, repoGit *git.Repository) error {
+	defer ObservePipelineStep("commit", time.Now())
+	commitMessage, err := CommitMessage(originalOwner, repo, action, requestID)
+	if err != nil {
+		workspace.logger().Error().Msgf("Error building commit message for '%s/%s': %v", owner, repo, err)
+		return err
+	}
+	workingBranch, err := repoGit.Worktree()
+	if err != nil {
+		workspace.logger().Info().Msgf("Error getting current branch '%s/%s'", owner, repo)
+		return err
+	}
+	workspace.logger().Info().Msgf("Adding %s file to new branch ", Config.Scanner.SecretsFileName)
+	_, err = workingBranch.Add(Config.Scanner.SecretsFileName)
+	if err != nil {
+		return err
+	}
+	workspace.logger().Info().Msgf("%s was added to stage ", Config.Scanner.SecretsFileName)
+	if _, err := workingBranch.Filesystem.Stat(AuditFileName); err == nil {
+		if _, err := workingBranch.Add(AuditFileName); err != nil {
+			return err
+		}
+		workspace.logger().Info().Msgf("%s was added to stage ", AuditFileName)
+	}
+	workspace.logger().Info().Msg("Committing Changes")
+	commit, err := workingBranch.Commit(commitMessage, &git.CommitOptions{
+		Author: &object.Signature{
+			Name: owner,
+			When: time.Now(),
+		},
+	})
+	if err != nil {
+		workspace.logger().Error().Msgf("Error Committing changes: %v", err)
+		return err
+	}
+	workspace.logger().Info().Msg("Changes were committed")
+	_, err = repoGit.CommitObject(commit)
+	if err != nil {
+		workspace.logger().Error().Msgf("Error Committing: %v", err)
+		return err
+	}
+	workspace.logger().Info().Msgf("Commit created in '%s/%s'", owner, repo)
+
+	workspace.logger().Info().Msg("Pushing changes to remote")
+	obj := &git.PushOptions{}
+	err = Retry(workspace.context(), "push", func() error {
+		// an attempt that failed after updating the remote leaves nothing to push
+		if err := repoGit.Push(obj); err != nil && err != git.NoErrAlreadyUpToDate {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	workspace.logger().Info().Msgf("Branch was pushed '%s/%s'", owner, repo)
+	return nil
+}
+
+// opens the pull request from the pushed branch of the fork and returns its number
+func (gitService gitServiceImplementation) OpenPullRequest(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string) (int, error) {
+	gitService.logger().Info().Msgf("Head Branch: %s", headBranch)
+	gitService.logger().Info().Msgf("Current Branch: %s", currentBranch)
+	githubClient := gitService.GetGitHubClient()
+	newPR := &github.NewPullRequest{
+		Title:               github.String(PullRequestTitle(action)),
+		Head:                github.String(fmt.Sprintf("%s:%s", owner, currentBranch)),
+		Base:                github.String(headBranch),
+		Body:                github.String(description),
+		MaintainerCanModify: github.Bool(true),
+	}
+
+	pullRequestStarted := time.Now()
+	var pullRequest *github.PullRequest
+	err := Retry(gitService.context(), "pull request", func() (err error) {
+		pullRequest, _, err = githubClient.PullRequests.Create(gitService.context(), originalOwner, repo, newPR)
+		return err
+	})
+	ObservePipelineStep("pull_request", pullRequestStarted)
+	var githubError *github.ErrorResponse
+	if errors.As(err, &githubError) && pullRequestExists(githubError) {
+		number, err := gitService.updatePullRequest(githubClient, originalOwner, repo, newPR)
+		if err != nil {
+			gitService.logger().Error().Msgf("Error updating the existing PR in '%s/%s': %v", originalOwner, repo, err)
+			return 0, err
+		}
+		gitService.logger().Info().Msgf("PR success Updated! '%s/%s'", owner, repo)
+		return number, ErrPullRequestUpdated
+	}
+	if err != nil {
+		gitService.logger().Error().Msgf("Error creating PR in '%s/%s': %v", originalOwner, repo, err)
+		return 0, err
+	}
+	gitService.logger().Info().Msgf("PR success Created! '%s/%s'", owner, repo)
+	return pullRequest.GetNumber(), nil
+}
+
+// GitHub answers 422 with this message when the head branch already has an open pull request
+func pullRequestExists(githubError *github.ErrorResponse) bool {
+	if githubError.Response == nil || githubError.Response.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	for _, detail := range githubError.Errors {
+		if strings.Contains(strings.ToLower(detail.Message), "a pull request already exists") {
+			return true
+		}
+	}
+	return false
+}
+
+// updates the title and body of the open pull request from the head of newPR and returns its number
+func (gitService gitServiceImplementation) updatePullRequest(githubClient *github.Client, originalOwner string, repo string, newPR *github.NewPullRequest) (int, error) {
+	options := &github.PullRequestListOptions{State: "open", Head: newPR.GetHead(), Base: newPR.GetBase()}
+	open, _, err := githubClient.PullRequests.List(gitService.context(), originalOwner, repo, options)
+	if err != nil {
+		return 0, err
+	}
+	if len(open) == 0 {
+		return 0, NewServiceError(ErrCodeConflict, fmt.Sprintf("pull request from %s could not be created or found", newPR.GetHead()), nil)
+	}
+	update := &github.PullRequest{Title: newPR.Title, Body: newPR.Body}
+	_, _, err = githubClient.PullRequests.Edit(gitService.context(), originalOwner, repo, open[0].GetNumber(), update)
+	if err != nil {
+		return 0, err
+	}
+	return open[0].GetNumber(), nil
+}
+
+func (gitService gitServiceImplementation) ForkRepo(owner string, repo string) (forkedOwner interface{}, gitURL interface{}, err error) {
+	gitService.logger().Info().Msgf("Forking repo from '%s/%s'", owner, repo)
+	forkURL := gitService.apiURL(fmt.Sprintf("repos/%s/%s/forks", owner, repo))
+	resp, errPost := gitService.apiRequest(http.MethodPost, forkURL)
+
+	if errPost != nil {
+		gitService.logger().Error().Msgf("Error forking Repo from '%s/%s' ", owner, repo)
+		return "", "", errPost
+	}
+	defer resp.Body.Close()
+
+	body, errBody := ioutil.ReadAll(resp.Body)
+
+	if errBody != nil {
+		gitService.logger().Error().Msgf("Error forking Repo from '%s/%s' ", owner, repo)
+		return "", "", errBody
+	}
+
+	gitService.logger().Info().Msgf("Status Code after forking the repo: %d", resp.StatusCode)
+	formatBody := string(body)
+	if resp.StatusCode >= 300 {
+		gitService.logger().Error().Msgf("Error forking Repo from '%s/%s': %s", owner, repo, formatBody)
+		return "", "", &HTTPStatusError{Method: http.MethodPost, Path: resp.Request.URL.Path, StatusCode: resp.StatusCode, Body: formatBody}
+	}
+
+	var result = forkResponseParams{}
+
+	parseError := json.Unmarshal([]byte(formatBody), &result)
+	if parseError != nil {
+		gitService.logger().Error().Msgf("Contents seems to be incorrect in the JSON received: %v", parseError)
+		return nil, nil, parseError
+	}
+	gitService.logger().Info().Msgf("User who forked: %s", result.Owner.Login)
+
+	return result.Owner.Login, result.GitURL, err
+}
+
+// GitHub creates forks asynchronously, polls the fork with backoff until it answers or
+// Config.Timeouts.ForkReadyTimeout has passed
+func (gitService gitServiceImplementation) CheckForkedRepo(owner string, repo string) error {
+	gitService.logger().Info().Msgf("Checking if repo was forked properly")
+	getURL := gitService.apiURL(fmt.Sprintf("repos/%s/%s", owner, repo))
+	ctx, cancel := context.WithTimeout(gitService.context(), Config.Timeouts.ForkReadyTimeout)
+	defer cancel()
+	gitService.ctx = ctx
+	forkPending := func(err error) bool {
+		var statusError *HTTPStatusError
+		return (errors.As(err, &statusError) && statusError.StatusCode == http.StatusNotFound) || IsRetryable(err)
+	}
+	err := retry(ctx, "fork check", 0, forkPending, func() error {
+		response, err := gitService.apiRequest(http.MethodGet, getURL)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+		if response.StatusCode >= 300 {
+			return &HTTPStatusError{Method: http.MethodGet, Path: response.Request.URL.Path, StatusCode: response.StatusCode}
+		}
+		return nil
+	})
+	if err != nil {
+		gitService.logger().Error().Msgf("Error: %v", err)
+		if ctx.Err() != nil {
+			return NewServiceError(ErrCodeTimeout, fmt.Sprintf("fork %s/%s was not ready after %s", owner, repo, Config.Timeouts.ForkReadyTimeout), err)
+		}
+		return err
+	}
+	gitService.logger().Info().Msgf("Repo has been forked successfully")
+	return nil
+}
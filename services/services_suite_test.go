@@ -3,10 +3,20 @@ package services_test
 import (
 	"testing"
 
+	"github.com/eliezer-borde-globant/EBGoProject/config"
+	"github.com/eliezer-borde-globant/EBGoProject/services"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+// every spec starts with the default configuration and an empty history
+var _ = BeforeEach(func() {
+	services.Config = config.Defaults()
+	history, err := services.OpenHistory("sqlite3", ":memory:")
+	Expect(err).To(BeNil())
+	services.History = history
+})
+
 func TestServices(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Services Suite")
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/google/go-github/v33/github"
@@ -11,7 +12,9 @@ import (
 	. "github.com/onsi/gomega"
 	"golang.org/x/oauth2"
 	"net/http"
+	"net/http/httptest"
 	"strings"
+	"time"
 )
 
 type contextMock struct {
@@ -25,10 +28,12 @@ type oAuthMock struct {
 
 type gitServiceMock struct {
 	GithubNewClientHandler func(*http.Client) *github.Client
+	GithubNewEnterpriseClientHandler func(string, string, *http.Client) (*github.Client, error)
 	GetRepoInfoHandler func(*github.Client, context.Context, string, string) (*github.Repository, *github.Response, error)
 	HeadHandler func(*git.Repository) (*plumbing.Reference, error)
 	WorktreeHandler func(*git.Repository) (*git.Worktree, error)
 	FetchHandler func(repoGit *git.Repository) error
+	CheckoutHandler func(*git.Worktree, *git.CheckoutOptions) error
 }
 
 func (mock contextMock) Background() context.Context {
@@ -49,6 +54,11 @@ func (mock gitServiceMock) NewClient(client *http.Client) *github.Client {
 	return mock.GithubNewClientHandler(client)
 }
 
+// client for GitHub Enterprise Server
+func (mock gitServiceMock) NewEnterpriseClient(baseURL string, uploadURL string, client *http.Client) (*github.Client, error) {
+	return mock.GithubNewEnterpriseClientHandler(baseURL, uploadURL, client)
+}
+
 // get git repo info
 func (mock gitServiceMock) Get(client *github.Client, ctx context.Context, owner string, repo string) (*github.Repository, *github.Response, error) {
 	return mock.GetRepoInfoHandler(client, ctx, owner, repo)
@@ -68,6 +78,11 @@ func (mock gitServiceMock) Fetch(repo *git.Repository) error {
 	return mock.FetchHandler(repo)
 }
 
+// checkout or create branch
+func (mock gitServiceMock) Checkout(workingBranch *git.Worktree, opts *git.CheckoutOptions) error {
+	return mock.CheckoutHandler(workingBranch, opts)
+}
+
 var _ = Describe("Services", func() {
 	Context(" when trying to create new github client", func() {
 		It("verifies user by token and returns new github client", func() {
@@ -96,8 +111,85 @@ var _ = Describe("Services", func() {
 		})
 	})
 
+	Context("when the request targets a GitHub Enterprise Server host", func() {
+		BeforeEach(func() {
+			Config.GitHub.Hosts = map[string]GitHubHost{
+				"ghe.acme.com": {BaseURL: "https://ghe.acme.com/api/v3/", UploadURL: "https://ghe.acme.com/api/uploads/", GitHost: "ghe.acme.com", Token: "ghe-token"},
+			}
+		})
+
+		It("creates an enterprise client with the host urls and token", func() {
+			oAuthObj := oAuthMock{}
+			gitServiceObj := gitServiceMock{}
+			var token string
+			var urls []string
+			oAuthObj.StaticTokenSourceHandler = func(t *oauth2.Token) oauth2.TokenSource {
+				token = t.AccessToken
+				return nil
+			}
+			oAuthObj.OauthNewClientHandler = func(context.Context, oauth2.TokenSource) *http.Client {
+				return new(http.Client)
+			}
+			gitServiceObj.GithubNewEnterpriseClientHandler = func(baseURL string, uploadURL string, client *http.Client) (*github.Client, error) {
+				urls = []string{baseURL, uploadURL}
+				return new(github.Client), nil
+			}
+			ThirdPartyOauth = oAuthObj
+			ThirdPartyGitHub = gitServiceObj
+			service, err := GitProvider(context.Background(), "", "ghe.acme.com")
+			Expect(err).To(BeNil())
+			client := service.(gitHubServiceInterface).GetGitHubClient()
+			Expect(client).To(Equal(new(github.Client)))
+			Expect(token).To(Equal("ghe-token"))
+			Expect(urls).To(Equal([]string{"https://ghe.acme.com/api/v3/", "https://ghe.acme.com/api/uploads/"}))
+		})
+
+		It("builds api urls from the enterprise base url", func() {
+			service := gitServiceImplementation{Host: "ghe.acme.com"}
+			Expect(service.apiURL("repos/john/repo")).To(Equal("https://ghe.acme.com/api/v3/repos/john/repo"))
+			Expect(gitServiceImplementation{}.apiURL("repos/john/repo")).To(HavePrefix("https://"))
+			Expect(gitServiceImplementation{}.apiURL("repos/john/repo")).To(HaveSuffix("api.github.com/repos/john/repo"))
+		})
+
+		It("polls a new fork with backoff until it answers or the deadline passes", func() {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Authorization")).To(Equal("token ghe-token"))
+				calls++
+				if calls < 3 || strings.Contains(r.URL.Path, "missing") {
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+			Config.GitHub.Hosts["ghe.acme.com"] = GitHubHost{BaseURL: server.URL + "/", GitHost: "ghe.acme.com", Token: "ghe-token"}
+			Config.Timeouts.RetryBaseDelay, Config.Timeouts.ForkReadyTimeout = time.Millisecond, 100*time.Millisecond
+			service := gitServiceImplementation{Host: "ghe.acme.com"}.withContext(context.Background())
+
+			Expect(service.CheckForkedRepo("bot", "repo")).To(BeNil())
+			Expect(calls).To(Equal(3))
+			err := service.CheckForkedRepo("bot", "missing")
+			Expect(ClassifyError(err, ErrCodeInternal)).To(Equal(ErrCodeTimeout))
+		})
+
+		It("uses github.com for an empty host and rejects unknown hosts", func() {
+			service, err := GitProvider(context.Background(), "github", "")
+			Expect(err).To(BeNil())
+			Expect(service).To(Equal(TraceGitHubService(context.Background(), GitServiceObject)))
+			_, err = GitProvider(context.Background(), "github", "ghe.unknown.com")
+			Expect(err).NotTo(BeNil())
+			_, err = GitProvider(context.Background(), "gitlab", "ghe.acme.com")
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("links the pull request page of each provider", func() {
+			Expect(PullRequestURL("github", "", "john", "repo", 4)).To(Equal("https://github.com/john/repo/pull/4"))
+			Expect(PullRequestURL("github", "ghe.acme.com", "john", "repo", 4)).To(Equal("https://ghe.acme.com/john/repo/pull/4"))
+			Expect(PullRequestURL("gitlab", "", "john", "repo", 4)).To(Equal(Config.GitLab.URL + "/john/repo/-/merge_requests/4"))
+		})
+	})
+
 	Context("when given user access token", func(){
-		It("returns repo info", func(){
+		It("returns no error when the repo can be fetched", func(){
 			gitServiceObj := gitServiceMock{}
 
 			gitServiceObj.GithubNewClientHandler = func(*http.Client) *github.Client {
@@ -108,9 +200,8 @@ var _ = Describe("Services", func() {
 			}
 
 			ThirdPartyGitHub = gitServiceObj
-			result, err:= GitServiceObject.CheckUserAccessRepo("john", "repo")
+			err:= GitServiceObject.CheckUserAccessRepo("john", "repo")
 			Expect(err).To(BeNil())
-			Expect(result).To(Equal(new(github.Repository)))
 		})
 
 		It("returns error and exits method when problem occurs", func(){
@@ -124,9 +215,8 @@ var _ = Describe("Services", func() {
 			}
 
 			ThirdPartyGitHub = gitServiceObj
-			result, err:= GitServiceObject.CheckUserAccessRepo("john", "repo")
+			err:= GitServiceObject.CheckUserAccessRepo("john", "repo")
 			Expect(strings.Contains(fmt.Sprintf("%v",err), "error")).To(BeTrue())
-			Expect(result).To(BeNil())
 		})
 	})
 
@@ -143,13 +233,44 @@ var _ = Describe("Services", func() {
 			gitServiceObj.FetchHandler = func(repo *git.Repository) error {
 				return nil
 			}
+			gitServiceObj.CheckoutHandler = func(*git.Worktree, *git.CheckoutOptions) error {
+				return nil
+			}
 			ThirdPartyGitHub = gitServiceObj
-			branch, head, err:= GitServiceObject.CreateBranchRepo(new(git.Repository), "repo", "create")
+			branch, head, err:= GitServiceObject.CreateBranchRepo(new(git.Repository), "john", "repo", "create", "a1b2c3d4")
 			Expect(err).To(BeNil())
-			Expect(branch).To(Equal(""))
+			Expect(branch).To(Equal("secret_scanner_api/repo/create/a1b2c3d4/secrets_baseline_file"))
 			Expect(head).To(Equal(""))
 		})
 
+		It("creates the branch from head when it does not exist yet", func(){
+			gitServiceObj := gitServiceMock{}
+			var checkouts []*git.CheckoutOptions
+
+			gitServiceObj.HeadHandler = func(*git.Repository) (*plumbing.Reference, error) {
+				return plumbing.NewHashReference("refs/heads/main", plumbing.ZeroHash), nil
+			}
+			gitServiceObj.WorktreeHandler = func(*git.Repository) (*git.Worktree, error) {
+				return new(git.Worktree), nil
+			}
+			gitServiceObj.FetchHandler = func(repo *git.Repository) error {
+				return nil
+			}
+			gitServiceObj.CheckoutHandler = func(_ *git.Worktree, opts *git.CheckoutOptions) error {
+				checkouts = append(checkouts, opts)
+				if !opts.Create {
+					return errors.New("reference not found")
+				}
+				return nil
+			}
+			ThirdPartyGitHub = gitServiceObj
+			branch, head, err:= GitServiceObject.CreateBranchRepo(new(git.Repository), "john", "repo", "create", "a1b2c3d4")
+			Expect(err).To(BeNil())
+			Expect(head).To(Equal("main"))
+			Expect(checkouts).To(HaveLen(2))
+			Expect(checkouts[1].Branch.Short()).To(Equal(branch))
+		})
+
 		It("returns error when problem occurs in fetching head", func(){
 			gitServiceObj := gitServiceMock{}
 
@@ -158,7 +279,7 @@ var _ = Describe("Services", func() {
 			}
 
 			ThirdPartyGitHub = gitServiceObj
-			branch, head, err:= GitServiceObject.CreateBranchRepo(new(git.Repository), "repo", "create")
+			branch, head, err:= GitServiceObject.CreateBranchRepo(new(git.Repository), "john", "repo", "create", "a1b2c3d4")
 			Expect(strings.Contains(fmt.Sprintf("%v",err), "error")).To(BeTrue())
 			Expect(branch).To(Equal(""))
 			Expect(head).To(Equal(""))
@@ -176,7 +297,7 @@ var _ = Describe("Services", func() {
 			}
 
 			ThirdPartyGitHub = gitServiceObj
-			branch, head, err:= GitServiceObject.CreateBranchRepo(new(git.Repository), "repo", "create")
+			branch, head, err:= GitServiceObject.CreateBranchRepo(new(git.Repository), "john", "repo", "create", "a1b2c3d4")
 			Expect(strings.Contains(fmt.Sprintf("%v",err), "error fetching working branch")).To(BeTrue())
 			Expect(branch).To(Equal(""))
 			Expect(head).To(Equal(""))
@@ -190,12 +311,16 @@ var _ = Describe("Services", func() {
 			}
 
 			gitServiceObj.WorktreeHandler = func(*git.Repository) (*git.Worktree, error) {
-				return nil, errors.New("error fetching all branches")
+				return new(git.Worktree), nil
+			}
+
+			gitServiceObj.FetchHandler = func(*git.Repository) error {
+				return errors.New("error fetching all branches")
 			}
 
 			ThirdPartyGitHub = gitServiceObj
-			branch, head, err:= GitServiceObject.CreateBranchRepo(new(git.Repository), "repo", "create")
-			Expect(strings.Contains(fmt.Sprintf("%v",err), "error fetching working branch")).To(BeTrue())
+			branch, head, err:= GitServiceObject.CreateBranchRepo(new(git.Repository), "john", "repo", "create", "a1b2c3d4")
+			Expect(strings.Contains(fmt.Sprintf("%v",err), "error fetching all branches")).To(BeTrue())
 			Expect(branch).To(Equal(""))
 			Expect(head).To(Equal(""))
 		})
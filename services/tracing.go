@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	"github.com/go-git/go-git/v5"
+	"github.com/google/go-github/v33/github"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"os"
+)
+
+// follows the tracer provider installed by InitTracing, spans are dropped until then
+var Tracer = otel.Tracer("github.com/eliezer-borde-globant/EBGoProject")
+
+// installs the tracer provider exporting to exporter and the W3C trace context propagator,
+// the OTLP exporter reads its endpoint and headers from the OTEL_EXPORTER_OTLP_* variables.
+// The returned function flushes the spans left
+func InitTracing(ctx context.Context, exporter string, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", TracingExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case TracingExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TracingExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// ends span marking it as failed when err is set
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func repoAttributes(owner string, repo string) trace.SpanStartOption {
+	return trace.WithAttributes(attribute.String("repo.owner", owner), attribute.String("repo.name", repo))
+}
+
+// implemented by the services that can send their API calls under the span of the method
+type contextualGitService interface {
+	withContext(ctx context.Context) gitServiceInterface
+}
+
+// opens a span for every method of service, child of the span in ctx
+type tracedGitService struct {
+	ctx     context.Context
+	service gitServiceInterface
+}
+
+func TraceGitService(ctx context.Context, service gitServiceInterface) gitServiceInterface {
+	return tracedGitService{ctx: ctx, service: service}
+}
+
+func (traced tracedGitService) start(method string, options ...trace.SpanStartOption) (gitServiceInterface, trace.Span) {
+	ctx, span := Tracer.Start(traced.ctx, "GitService."+method, options...)
+	if contextual, ok := traced.service.(contextualGitService); ok {
+		return contextual.withContext(ctx), span
+	}
+	return traced.service, span
+}
+
+func (traced tracedGitService) CheckUserAccessRepo(owner string, repo string) error {
+	service, span := traced.start("CheckUserAccessRepo", repoAttributes(owner, repo))
+	err := service.CheckUserAccessRepo(owner, repo)
+	EndSpan(span, err)
+	return err
+}
+
+func (traced tracedGitService) CloneRepo(owner string, repo string) (*git.Repository, string, error) {
+	service, span := traced.start("CloneRepo", repoAttributes(owner, repo))
+	repoGit, path, err := service.CloneRepo(owner, repo)
+	EndSpan(span, err)
+	return repoGit, path, err
+}
+
+func (traced tracedGitService) CreateBranchRepo(repoGit *git.Repository, owner string, repoName string, action string, requestID string) (string, string, error) {
+	service, span := traced.start("CreateBranchRepo", repoAttributes(owner, repoName))
+	branch, headBranch, err := service.CreateBranchRepo(repoGit, owner, repoName, action, requestID)
+	EndSpan(span, err)
+	return branch, headBranch, err
+}
+
+func (traced tracedGitService) CheckoutBranch(repoGit *git.Repository, branch string) error {
+	service, span := traced.start("CheckoutBranch", trace.WithAttributes(attribute.String("branch", branch)))
+	err := service.CheckoutBranch(repoGit, branch)
+	EndSpan(span, err)
+	return err
+}
+
+func (traced tracedGitService) CreateSecretFile(path string, secretFile string) error {
+	service, span := traced.start("CreateSecretFile")
+	err := service.CreateSecretFile(path, secretFile)
+	EndSpan(span, err)
+	return err
+}
+
+func (traced tracedGitService) EditSecretFile(path string, secretsChanges SecretUpdateMap) error {
+	service, span := traced.start("EditSecretFile")
+	err := service.EditSecretFile(path, secretsChanges)
+	EndSpan(span, err)
+	return err
+}
+
+func (traced tracedGitService) WriteAuditFile(path string, decisions []AuditDecision) error {
+	service, span := traced.start("WriteAuditFile")
+	err := service.WriteAuditFile(path, decisions)
+	EndSpan(span, err)
+	return err
+}
+
+func (traced tracedGitService) CommitAndPush(owner string, originalOwner string, repo string, action string, requestID string, repoGit *git.Repository) error {
+	service, span := traced.start("CommitAndPush", repoAttributes(originalOwner, repo))
+	err := service.CommitAndPush(owner, originalOwner, repo, action, requestID, repoGit)
+	EndSpan(span, err)
+	return err
+}
+
+func (traced tracedGitService) OpenPullRequest(owner string, originalOwner string, repo string, currentBranch string, headBranch string, action string, description string) (int, error) {
+	service, span := traced.start("OpenPullRequest", repoAttributes(originalOwner, repo))
+	number, err := service.OpenPullRequest(owner, originalOwner, repo, currentBranch, headBranch, action, description)
+	// an existing pull request that was updated is not a failure of the call
+	if errors.Is(err, ErrPullRequestUpdated) {
+		span.End()
+		return number, err
+	}
+	EndSpan(span, err)
+	return number, err
+}
+
+func (traced tracedGitService) ForkRepo(owner string, repo string) (interface{}, interface{}, error) {
+	service, span := traced.start("ForkRepo", repoAttributes(owner, repo))
+	forkedOwner, gitURL, err := service.ForkRepo(owner, repo)
+	EndSpan(span, err)
+	return forkedOwner, gitURL, err
+}
+
+func (traced tracedGitService) CheckForkedRepo(owner string, repo string) error {
+	service, span := traced.start("CheckForkedRepo", repoAttributes(owner, repo))
+	err := service.CheckForkedRepo(owner, repo)
+	EndSpan(span, err)
+	return err
+}
+
+// tracedGitService with the GitHub only methods
+type tracedGitHubService struct {
+	tracedGitService
+	service gitHubServiceInterface
+}
+
+func TraceGitHubService(ctx context.Context, service gitHubServiceInterface) gitHubServiceInterface {
+	return tracedGitHubService{tracedGitService: tracedGitService{ctx: ctx, service: service}, service: service}
+}
+
+func (traced tracedGitHubService) start(method string, options ...trace.SpanStartOption) (gitHubServiceInterface, trace.Span) {
+	ctx, span := Tracer.Start(traced.ctx, "GitService."+method, options...)
+	if contextual, ok := traced.service.(contextualGitService); ok {
+		return contextual.withContext(ctx).(gitHubServiceInterface), span
+	}
+	return traced.service, span
+}
+
+// the client is used by the caller after the method returns, it is bound to the caller span
+func (traced tracedGitHubService) GetGitHubClient() *github.Client {
+	if contextual, ok := traced.service.(contextualGitService); ok {
+		return contextual.withContext(traced.ctx).(gitHubServiceInterface).GetGitHubClient()
+	}
+	return traced.service.GetGitHubClient()
+}
+
+func (traced tracedGitHubService) PullRequestFiles(owner string, repo string, number int) ([]string, error) {
+	service, span := traced.start("PullRequestFiles", repoAttributes(owner, repo), trace.WithAttributes(attribute.Int("pull_request.number", number)))
+	files, err := service.PullRequestFiles(owner, repo, number)
+	EndSpan(span, err)
+	return files, err
+}
+
+func (traced tracedGitHubService) CheckoutPullRequest(repoGit *git.Repository, number int, headSHA string) error {
+	service, span := traced.start("CheckoutPullRequest", trace.WithAttributes(attribute.Int("pull_request.number", number)))
+	err := service.CheckoutPullRequest(repoGit, number, headSHA)
+	EndSpan(span, err)
+	return err
+}
+
+func (traced tracedGitHubService) OpenBaselinePullRequest(owner string, repo string) (BaselinePullRequest, bool, error) {
+	service, span := traced.start("OpenBaselinePullRequest", repoAttributes(owner, repo))
+	pull, found, err := service.OpenBaselinePullRequest(owner, repo)
+	EndSpan(span, err)
+	return pull, found, err
+}
+
+func (traced tracedGitHubService) FileContent(owner string, repo string, path string, ref string) ([]byte, error) {
+	service, span := traced.start("FileContent", repoAttributes(owner, repo))
+	content, err := service.FileContent(owner, repo, path, ref)
+	EndSpan(span, err)
+	return content, err
+}
+
+func (traced tracedGitHubService) CreateCheckRun(owner string, repo string, headSHA string, unaudited SecretUpdateMap) error {
+	service, span := traced.start("CreateCheckRun", repoAttributes(owner, repo))
+	err := service.CreateCheckRun(owner, repo, headSHA, unaudited)
+	EndSpan(span, err)
+	return err
+}
+
+func (traced tracedGitHubService) SyncSecretReviewComments(owner string, repo string, number int, headSHA string, unaudited SecretUpdateMap) error {
+	service, span := traced.start("SyncSecretReviewComments", repoAttributes(owner, repo), trace.WithAttributes(attribute.Int("pull_request.number", number)))
+	err := service.SyncSecretReviewComments(owner, repo, number, headSHA, unaudited)
+	EndSpan(span, err)
+	return err
+}
+
+func (traced tracedGitHubService) CreateIssueComment(owner string, repo string, number int, body string) error {
+	service, span := traced.start("CreateIssueComment", repoAttributes(owner, repo), trace.WithAttributes(attribute.Int("pull_request.number", number)))
+	err := service.CreateIssueComment(owner, repo, number, body)
+	EndSpan(span, err)
+	return err
+}
+
+func (traced tracedGitHubService) ListOrgRepos(org string, filter RepoFilter) ([]string, error) {
+	service, span := traced.start("ListOrgRepos", trace.WithAttributes(attribute.String("org", org)))
+	repos, err := service.ListOrgRepos(org, filter)
+	EndSpan(span, err)
+	return repos, err
+}
+
+// opens a client span for every call to api, child of the span in the request context
+type tracingTransport struct {
+	base     http.RoundTripper
+	api      string
+	endpoint func(path string) string
+}
+
+func NewTracingTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return tracingTransport{base: base, api: "GitHub", endpoint: githubEndpoint}
+}
+
+func (transport tracingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	endpoint := transport.endpoint(request.URL.EscapedPath())
+	ctx, span := Tracer.Start(request.Context(), fmt.Sprintf("%s %s %s", transport.api, request.Method, endpoint),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPMethodKey.String(request.Method), semconv.HTTPHostKey.String(request.URL.Host), attribute.String("http.route", endpoint)))
+	response, err := transport.base.RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		EndSpan(span, err)
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(response.StatusCode))
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(response.StatusCode))
+	span.End()
+	return response, nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	. "github.com/eliezer-borde-globant/EBGoProject/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Tracing", func() {
+	var recorder *tracetest.SpanRecorder
+	var previousTracer trace.Tracer
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		previousTracer = Tracer
+		Tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	})
+
+	AfterEach(func() {
+		Tracer = previousTracer
+	})
+
+	It("opens a span per git service call under the span of the caller", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(404)
+		}))
+		defer server.Close()
+		Config.GitLab.URL = server.URL
+
+		ctx, parent := Tracer.Start(context.Background(), "request")
+		err := TraceGitService(ctx, gitLabServiceImplementation{}).CheckUserAccessRepo("john", "repo")
+		parent.End()
+		Expect(err).NotTo(BeNil())
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(3))
+		Expect(spans[0].Name()).To(Equal("GitLab GET /api/v4/projects/{project}"))
+		Expect(spans[0].Parent().SpanID()).To(Equal(spans[1].SpanContext().SpanID()))
+		Expect(spans[1].Name()).To(Equal("GitService.CheckUserAccessRepo"))
+		Expect(spans[1].Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+		Expect(spans[1].Status().Code).To(Equal(codes.Error))
+	})
+
+	It("opens a client span per GitHub call named after the endpoint", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		}))
+		defer server.Close()
+
+		ctx, parent := Tracer.Start(context.Background(), "GitService.FileContent")
+		request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/repos/john/repo/contents/.secrets.baseline", nil)
+		client := &http.Client{Transport: NewTracingTransport(nil)}
+		_, err := client.Do(request)
+		parent.End()
+		Expect(err).To(BeNil())
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name()).To(Equal("GitHub GET /repos/{owner}/{repo}/contents/{path}"))
+		Expect(spans[0].SpanKind()).To(Equal(trace.SpanKindClient))
+		Expect(spans[0].Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+		Expect(spans[0].Status().Code).To(Equal(codes.Unset))
+	})
+
+	It("rejects unknown exporters", func() {
+		_, err := InitTracing(context.Background(), "zipkin", "secret-scanner")
+		Expect(err).NotTo(BeNil())
+		shutdown, err := InitTracing(context.Background(), TracingExporterNone, "secret-scanner")
+		Expect(err).To(BeNil())
+		Expect(shutdown(context.Background())).To(Succeed())
+	})
+})
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checks the X-Hub-Signature-256 header against the HMAC of the body, deliveries are
+// rejected when no secret is configured
+func VerifyGitHubSignature(secret string, body []byte, signature string) bool {
+	if secret == "" || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// remembers webhook delivery ids so redeliveries are not processed twice
+type deliveryStoreInterface interface {
+	Seen(deliveryID string) bool
+	Forget(deliveryID string)
+}
+
+type deliveryStoreImplementation struct {
+	mutex      *sync.Mutex
+	deliveries map[string]time.Time
+	retention  time.Duration
+}
+
+func NewDeliveryStore(retention time.Duration) deliveryStoreInterface {
+	return deliveryStoreImplementation{
+		mutex:      &sync.Mutex{},
+		deliveries: map[string]time.Time{},
+		retention:  retention,
+	}
+}
+
+// records the delivery and reports whether it had already been recorded
+func (store deliveryStoreImplementation) Seen(deliveryID string) bool {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	now := time.Now()
+	for id, receivedAt := range store.deliveries {
+		if now.Sub(receivedAt) > store.retention {
+			delete(store.deliveries, id)
+		}
+	}
+	if _, ok := store.deliveries[deliveryID]; ok {
+		return true
+	}
+	store.deliveries[deliveryID] = now
+	return false
+}
+
+func (store deliveryStoreImplementation) Forget(deliveryID string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.deliveries, deliveryID)
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func signBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+var _ = Describe("Webhooks", func() {
+	Context("when verifying the delivery signature", func() {
+		body := []byte(`{"ref":"refs/heads/main"}`)
+
+		It("accepts the HMAC of the body", func() {
+			Expect(VerifyGitHubSignature("secret", body, signBody("secret", body))).To(BeTrue())
+		})
+
+		It("rejects other secrets, malformed headers and a missing secret", func() {
+			Expect(VerifyGitHubSignature("secret", body, signBody("other", body))).To(BeFalse())
+			Expect(VerifyGitHubSignature("secret", body, "sha1=abc")).To(BeFalse())
+			Expect(VerifyGitHubSignature("secret", body, "sha256=zz")).To(BeFalse())
+			Expect(VerifyGitHubSignature("", body, signBody("", body))).To(BeFalse())
+		})
+	})
+
+	Context("when deduplicating deliveries", func() {
+		It("reports a delivery as seen only the second time", func() {
+			store := NewDeliveryStore(time.Hour)
+			Expect(store.Seen("delivery-1")).To(BeFalse())
+			Expect(store.Seen("delivery-1")).To(BeTrue())
+			Expect(store.Seen("delivery-2")).To(BeFalse())
+		})
+
+		It("forgets deliveries on request and after the retention", func() {
+			store := NewDeliveryStore(time.Hour)
+			store.Seen("delivery-1")
+			store.Forget("delivery-1")
+			Expect(store.Seen("delivery-1")).To(BeFalse())
+
+			expiring := NewDeliveryStore(time.Nanosecond)
+			expiring.Seen("delivery-1")
+			time.Sleep(time.Millisecond)
+			Expect(expiring.Seen("delivery-1")).To(BeFalse())
+		})
+	})
+
+	Context("when queueing jobs", func() {
+		It("runs enqueued jobs in the workers", func() {
+			queue := NewJobQueue(2, 1)
+			done := make(chan string, 2)
+			Expect(queue.Enqueue(Job{ID: "1", Run: func(context.Context) error { done <- "1"; return nil }})).To(BeNil())
+			Expect(queue.Enqueue(Job{ID: "2", Run: func(context.Context) error { done <- "2"; return errors.New("failed") }})).To(BeNil())
+			Eventually(done).Should(Receive(Equal("1")))
+			Eventually(done).Should(Receive(Equal("2")))
+		})
+
+		It("rejects jobs when the queue is full", func() {
+			queue := NewJobQueue(1, 0)
+			Expect(queue.Enqueue(Job{ID: "1"})).To(BeNil())
+			Expect(queue.Depth()).To(Equal(1))
+			Expect(queue.Enqueue(Job{ID: "2"})).To(Equal(ErrQueueFull))
+		})
+
+		It("returns the waiting jobs and rejects new ones on shutdown", func() {
+			queue := NewJobQueue(2, 0)
+			Expect(queue.Enqueue(Job{ID: "1", Kind: "refresh_baseline"})).To(BeNil())
+			jobs, err := queue.Shutdown(context.Background())
+			Expect(err).To(BeNil())
+			Expect(jobs).To(HaveLen(1))
+			Expect(jobs[0].ID).To(Equal("1"))
+			Expect(jobs[0].Interrupted).To(BeFalse())
+			Expect(queue.Closed()).To(BeTrue())
+			Expect(queue.Enqueue(Job{ID: "2"})).To(Equal(ErrQueueClosed))
+		})
+
+		It("waits for the running jobs on shutdown", func() {
+			queue := NewJobQueue(1, 1)
+			started, release := make(chan bool), make(chan bool)
+			Expect(queue.Enqueue(Job{ID: "1", Run: func(context.Context) error {
+				started <- true
+				<-release
+				return nil
+			}})).To(BeNil())
+			Eventually(started).Should(Receive())
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				close(release)
+			}()
+			jobs, err := queue.Shutdown(context.Background())
+			Expect(err).To(BeNil())
+			Expect(jobs).To(BeEmpty())
+		})
+
+		It("interrupts the jobs still running when the shutdown times out", func() {
+			queue := NewJobQueue(1, 1)
+			started, cancelled := make(chan bool), make(chan bool, 1)
+			Expect(queue.Enqueue(Job{ID: "1", Kind: "check_pull_request", Run: func(ctx context.Context) error {
+				started <- true
+				<-ctx.Done()
+				cancelled <- true
+				return ctx.Err()
+			}})).To(BeNil())
+			Eventually(started).Should(Receive())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			jobs, err := queue.Shutdown(ctx)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+			Expect(jobs).To(HaveLen(1))
+			Expect(jobs[0].ID).To(Equal("1"))
+			Expect(jobs[0].Interrupted).To(BeTrue())
+			Expect(cancelled).To(Receive())
+		})
+
+		It("saves the unfinished jobs for the next start", func() {
+			dir, err := ioutil.TempDir("", "jobs")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "pending-jobs.json")
+			Expect(SavePendingJobs(path, nil)).To(Succeed())
+			Expect(path).NotTo(BeAnExistingFile())
+
+			saved := []Job{{ID: "1", Name: "refresh", Kind: "refresh_baseline", Params: json.RawMessage(`{"owner":"john","repo":"repo"}`), Interrupted: true}}
+			Expect(SavePendingJobs(path, saved)).To(Succeed())
+			jobs, err := LoadPendingJobs(path)
+			Expect(err).To(BeNil())
+			Expect(jobs).To(HaveLen(1))
+			Expect(jobs[0].ID).To(Equal("1"))
+			Expect(jobs[0].Kind).To(Equal("refresh_baseline"))
+			Expect(string(jobs[0].Params)).To(MatchJSON(`{"owner":"john","repo":"repo"}`))
+			Expect(jobs[0].Interrupted).To(BeTrue())
+			Expect(path).To(BeAnExistingFile())
+
+			Expect(SavePendingJobs(path, nil)).To(Succeed())
+			Expect(path).NotTo(BeAnExistingFile())
+			jobs, err = LoadPendingJobs(path)
+			Expect(err).To(BeNil())
+			Expect(jobs).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,45 @@
+package utils
+
+// roles of the API clients, each one can do everything the previous one can
+const (
+	RoleViewer  = "viewer"
+	RoleAuditor = "auditor"
+	RoleAdmin   = "admin"
+)
+
+// org allow-list entry matching every org
+const AllOrgs = "*"
+
+var roleRanks = map[string]int{RoleViewer: 1, RoleAuditor: 2, RoleAdmin: 3}
+
+func ValidRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+// granted is role or one above it
+func RoleIncludes(granted string, role string) bool {
+	return ValidRole(role) && roleRanks[granted] >= roleRanks[role]
+}
+
+// role and orgs granted to an API key or to the members of an OIDC group
+type RoleGrant struct {
+	Role string   `json:"role" yaml:"role"`
+	Orgs []string `json:"orgs" yaml:"orgs"`
+}
+
+// static credential of a client, Name identifies the client in the logs and the history
+type APIKey struct {
+	Name      string `json:"name" yaml:"name"`
+	Key       string `json:"key" yaml:"key"`
+	RoleGrant `yaml:",inline"`
+}
+
+// issuer trusted for bearer tokens, the name of the caller is read from UsernameClaim and
+// the grants from the groups listed in GroupsClaim
+type OIDCProvider struct {
+	Issuer        string               `json:"issuer" yaml:"issuer"`
+	Audience      string               `json:"audience" yaml:"audience"`
+	UsernameClaim string               `json:"username_claim" yaml:"username_claim"`
+	GroupsClaim   string               `json:"groups_claim" yaml:"groups_claim"`
+	Groups        map[string]RoleGrant `json:"groups" yaml:"groups"`
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"github.com/rs/zerolog"
+	"os"
+	"strings"
+)
+
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+// where the spans of the requests and jobs are exported
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+type requestIDKey struct{}
+
+// replaces ZeroLogger by one writing at level in format, json or console
+func ConfigureLogger(level string, format string) error {
+	parsedLevel, err := zerolog.ParseLevel(strings.ToLower(level))
+	if err != nil {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	switch strings.ToLower(format) {
+	case "", LogFormatJSON:
+		ZeroLogger = zerolog.New(os.Stdout).Level(parsedLevel).With().Timestamp().Logger()
+	case LogFormatConsole:
+		ZeroLogger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).Level(parsedLevel).With().Timestamp().Logger()
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	return nil
+}
+
+// adds requestID to ctx with a child of ZeroLogger tagging every line with it
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	logger := ZeroLogger.With().Str("request_id", requestID).Logger()
+	return logger.WithContext(context.WithValue(ctx, requestIDKey{}, requestID))
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// adds fields to the logger of ctx, a pair of arguments per field
+func WithLogFields(ctx context.Context, fields ...string) context.Context {
+	logContext := Logger(ctx).With()
+	for i := 0; i+1 < len(fields); i += 2 {
+		logContext = logContext.Str(fields[i], fields[i+1])
+	}
+	logger := logContext.Logger()
+	return logger.WithContext(ctx)
+}
+
+// logger of ctx, ZeroLogger when ctx has none
+func Logger(ctx context.Context) *zerolog.Logger {
+	if ctx != nil {
+		if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+			return logger
+		}
+	}
+	return &ZeroLogger
+}
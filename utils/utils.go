@@ -5,15 +5,36 @@ import (
 	"os"
 )
 
-
 var (
-	GitHubToken = os.Getenv("GITHUB_TOKEN")
 	ZeroLogger = zerolog.New(os.Stdout).With().Timestamp().Logger()
 )
 
 const (
-	SecretsFileName = ".secrets.baseline"
+	AuditFileName = ".secrets.audit.json"
+)
+
+// what a GitHub request does once the quota is exhausted, waits longer than
+// github.rate_limit.max_wait_seconds always fail fast
+const (
+	RateLimitWait = "wait"
+	RateLimitFailFast = "fail"
 )
 
 // map defined to read the parse json, used while trying to edit the secrets file
-type SecretUpdateMap map[string][]map[string]interface{}
\ No newline at end of file
+type SecretUpdateMap map[string][]map[string]interface{}
+
+// api and git endpoints of a GitHub instance, BaseURL and UploadURL are only set for
+// GitHub Enterprise Server
+type GitHubHost struct {
+	BaseURL   string `json:"base_url" yaml:"base_url"`
+	UploadURL string `json:"upload_url" yaml:"upload_url"`
+	GitHost   string `json:"git_host" yaml:"git_host"`
+	Token     string `json:"token" yaml:"token"`
+}
+
+// text/template sources used to name the baseline branch and its commit, both receive
+// Owner, Repo, Action and RequestID
+type NamingTemplate struct {
+	Branch string `json:"branch" yaml:"branch"`
+	Commit string `json:"commit" yaml:"commit"`
+}